@@ -15,9 +15,9 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/pfsense/openvpn/connections": {
+        "/api/health/lab": {
             "get": {
-                "description": "Retrieves information about all OpenVPN connections",
+                "description": "Probes the TCP services of every configured lab host, and LDAP and DNS SRV records of the domain controllers",
                 "consumes": [
                     "application/json"
                 ],
@@ -25,34 +25,33 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "PFSENSE"
+                    "HEALTH"
                 ],
-                "summary": "Get all OpenVPN connections",
+                "summary": "Get lab service health",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/pfsense.PfsenseOpenVPNConnection"
-                            }
+                            "$ref": "#/definitions/health.LabHealth"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "429": {
+                        "description": "Too Many Requests",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/response.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/api/pve/reset": {
+        "/api/instances": {
             "get": {
-                "description": "Retrieves the timestamp of the last lab reset",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieves the lab instances of the current user, or every instance for admins",
                 "consumes": [
                     "application/json"
                 ],
@@ -60,32 +59,58 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "PVE"
+                    "INSTANCES"
                 ],
-                "summary": "Get last reset time",
+                "summary": "Get lab instances",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "integer"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/instance.Instance"
                             }
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
                         }
                     }
                 }
             },
             "post": {
-                "description": "Resets all VMs to their latest snapshots",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Creates a private lab instance by linked-cloning the templates into a dedicated pool and VLAN. Provisioning continues in the background; poll the instance until it is ready.",
                 "consumes": [
                     "application/json"
                 ],
@@ -93,34 +118,217 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "PVE"
+                    "INSTANCES"
+                ],
+                "summary": "Create a lab instance",
+                "parameters": [
+                    {
+                        "description": "Owner of the instance, admins only",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.CreateInstanceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/instance.Instance"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/instances/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieves a lab instance owned by the current user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "INSTANCES"
+                ],
+                "summary": "Get a lab instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Reset the lab",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/instance.Instance"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Stops and destroys the VMs of an instance and deletes its pool in the background",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "INSTANCES"
+                ],
+                "summary": "Delete a lab instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/instance.Instance"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/api/pve/vms": {
+        "/api/notifications/deliveries": {
             "get": {
-                "description": "Retrieves information about all virtual machines",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieves the recent notification deliveries and their outcome, newest first",
                 "consumes": [
                     "application/json"
                 ],
@@ -128,34 +336,48 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "PVE"
+                    "NOTIFICATIONS"
                 ],
-                "summary": "Get all VMs",
+                "summary": "Get notification deliveries",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/proxmox.VMInfo"
+                                "$ref": "#/definitions/notify.Delivery"
                             }
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/api/pve/vms/reset": {
+        "/api/notifications/test": {
             "post": {
-                "description": "Resets all virtual machines",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Sends a test event to every notifier. The outcome shows up in the delivery log.",
                 "consumes": [
                     "application/json"
                 ],
@@ -163,34 +385,75 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "PVE"
+                    "NOTIFICATIONS"
                 ],
-                "summary": "Reset all VMs",
+                "summary": "Send a test notification",
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/notify.Event"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/pfsense/health": {
+            "get": {
+                "description": "Retrieves system, service, gateway and interface status summarised as healthy, degraded or down",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PFSENSE"
+                ],
+                "summary": "Get pfSense health",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/proxmox.VMOperationResult"
-                            }
+                            "$ref": "#/definitions/platform.GatewayHealth"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "429": {
+                        "description": "Too Many Requests",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/response.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/api/pve/vms/start": {
-            "post": {
-                "description": "Starts all virtual machines",
+        "/api/pfsense/leases": {
+            "get": {
+                "description": "Retrieves the DHCP server leases and the ARP table of pfSense",
                 "consumes": [
                     "application/json"
                 ],
@@ -198,34 +461,40 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "PVE"
+                    "PFSENSE"
                 ],
-                "summary": "Start all VMs",
+                "summary": "Get DHCP leases and ARP table",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/proxmox.VMOperationResult"
-                            }
+                            "$ref": "#/definitions/platform.LeaseTable"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/api/pve/vms/stop": {
-            "post": {
-                "description": "Stops all virtual machines",
+        "/api/pfsense/modes": {
+            "get": {
+                "description": "Retrieves the available network modes, their firewall rules and the active mode",
                 "consumes": [
                     "application/json"
                 ],
@@ -233,50 +502,2111 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "PVE"
+                    "PFSENSE"
                 ],
-                "summary": "Stop all VMs",
+                "summary": "Get network modes",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/proxmox.VMOperationResult"
-                            }
+                            "$ref": "#/definitions/platform.NetworkModes"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "pfsense.PfsenseOpenVPNConnection": {
-            "type": "object",
-            "properties": {
-                "common_name": {
-                    "type": "string"
-                },
-                "connect_time_unix": {
-                    "type": "integer"
-                },
-                "id": {
-                    "type": "integer"
-                }
-            }
         },
-        "proxmox.VMInfo": {
-            "type": "object",
+        "/api/pfsense/modes/{mode}": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Enables the firewall rules of a network mode (normal, exam or isolated) and applies the changes",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PFSENSE"
+                ],
+                "summary": "Switch network mode",
+                "parameters": [
+                    {
+                        "enum": [
+                            "normal",
+                            "exam",
+                            "isolated"
+                        ],
+                        "type": "string",
+                        "description": "Network mode",
+                        "name": "mode",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/platform.NetworkModes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/pfsense/openvpn/connections": {
+            "get": {
+                "description": "Retrieves information about all OpenVPN connections",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PFSENSE"
+                ],
+                "summary": "Get all OpenVPN connections",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/platform.VPNConnection"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/pve/jobs": {
+            "get": {
+                "description": "Retrieves the recent start, shutdown and reset jobs, newest first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PVE"
+                ],
+                "summary": "Get lab jobs",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/lab.Job"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/pve/jobs/{id}": {
+            "get": {
+                "description": "Retrieves the progress and outcome of a start, shutdown or reset job",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PVE"
+                ],
+                "summary": "Get a lab job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/lab.Job"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/pve/nodes": {
+            "get": {
+                "description": "Retrieves the CPU, memory, uptime, load and version of every Proxmox node",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PVE"
+                ],
+                "summary": "Get nodes",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/platform.NodeStatus"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/pve/reset": {
+            "get": {
+                "description": "Retrieves the timestamp of the last lab reset",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PVE"
+                ],
+                "summary": "Get last reset time",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "integer"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Starts a reset job that rolls all VMs back to their latest snapshots, starts stopped VMs in boot order and waits for guest agents and lab services",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PVE"
+                ],
+                "summary": "Reset the lab",
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/lab.Job"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/pve/storage": {
+            "get": {
+                "description": "Retrieves the usage of every storage. Storage holding VM disks and snapshots carries a warning once it crosses STORAGE_WARNING_PERCENT.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PVE"
+                ],
+                "summary": "Get storage usage",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/platform.StorageInfo"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/pve/vms": {
+            "get": {
+                "description": "Retrieves information about all virtual machines, including their MAC addresses, current lab IP and guest agent details",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PVE"
+                ],
+                "summary": "Get all VMs",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/platform.VMInfo"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/pve/vms/reset": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Resets all virtual machines",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PVE"
+                ],
+                "summary": "Reset all VMs",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/platform.VMOperationResult"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/pve/vms/resume": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Resumes all suspended virtual machines",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PVE"
+                ],
+                "summary": "Resume all VMs",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/platform.VMOperationResult"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/pve/vms/shutdown": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Starts a job that shuts the running virtual machines down through ACPI in reverse boot order. VMs still running after the timeout are stopped unless force is false.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PVE"
+                ],
+                "summary": "Shut down all VMs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ACPI shutdown timeout such as 90s, defaults to LAB_SHUTDOWN_TIMEOUT and must be shorter than LAB_JOB_TIMEOUT",
+                        "name": "timeout",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Stop VMs that do not shut down in time (default true)",
+                        "name": "force",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/lab.Job"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/pve/vms/start": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Starts a job that starts the stopped virtual machines boot group by boot group, honouring the group delays and readiness waits",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PVE"
+                ],
+                "summary": "Start all VMs",
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/lab.Job"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/pve/vms/stop": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Hard stops all virtual machines, like pulling the power cord. Prefer /api/pve/vms/shutdown.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PVE"
+                ],
+                "summary": "Stop all VMs",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/platform.VMOperationResult"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/pve/vms/suspend": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Suspends all virtual machines, keeping their memory in place",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PVE"
+                ],
+                "summary": "Suspend all VMs",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/platform.VMOperationResult"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/pve/vms/{vmid}/console": {
+            "get": {
+                "description": "Upgrades to a websocket relaying the VNC stream of a session opened through POST. The session can be used once.",
+                "tags": [
+                    "CONSOLE"
+                ],
+                "summary": "Connect to a VM console",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "VM ID",
+                        "name": "vmid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Console session ID",
+                        "name": "session",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Opens a VNC console session for a running VM of the shared lab, for admins and the holder of the current reservation, or of an instance of the user. Connect noVNC to the returned websocket URL within 10 seconds and use the returned password.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "CONSOLE"
+                ],
+                "summary": "Open a VM console",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "VM ID",
+                        "name": "vmid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/console.Session"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/pve/vms/{vmid}/metrics": {
+            "get": {
+                "description": "Retrieves the CPU, memory, disk I/O and network history of a virtual machine from the Proxmox RRD data",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PVE"
+                ],
+                "summary": "Get VM metrics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "VM ID",
+                        "name": "vmid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "hour, day or week (default hour)",
+                        "name": "timeframe",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "avg or max (default avg)",
+                        "name": "cf",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/platform.VMMetrics"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/reservations": {
+            "get": {
+                "description": "Retrieves every reservation of the lab, ordered by start time",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RESERVATIONS"
+                ],
+                "summary": "Get reservations",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/reservation.Reservation"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Reserves the lab for a time slot. While it lasts, the holder may start, stop and reset the lab. Admins may book for another user configured in AUTH_USERS.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RESERVATIONS"
+                ],
+                "summary": "Book the lab",
+                "parameters": [
+                    {
+                        "description": "Time slot",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.ReservationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/reservation.Reservation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/reservations/calendar.ics": {
+            "get": {
+                "description": "Exports the reservations as an iCalendar feed that calendar applications can subscribe to",
+                "produces": [
+                    "text/calendar"
+                ],
+                "tags": [
+                    "RESERVATIONS"
+                ],
+                "summary": "Get the reservation calendar",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/reservations/current": {
+            "get": {
+                "description": "Retrieves the reservation holding the lab right now",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RESERVATIONS"
+                ],
+                "summary": "Get the current reservation",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/reservation.Reservation"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/reservations/{id}": {
+            "get": {
+                "description": "Retrieves a reservation",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RESERVATIONS"
+                ],
+                "summary": "Get a reservation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Reservation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/reservation.Reservation"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Moves or renames a reservation of the current user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RESERVATIONS"
+                ],
+                "summary": "Change a reservation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Reservation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Time slot",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.ReservationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/reservation.Reservation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Cancels a reservation of the current user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RESERVATIONS"
+                ],
+                "summary": "Cancel a reservation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Reservation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Reports that the process is up. It does not contact Proxmox or pfSense.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PROBES"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.ProbeStatus"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Reports whether Proxmox and pfSense are reachable. The configuration is validated on startup, so a running server always has a valid one. Fails while the server shuts down.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PROBES"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.ProbeStatus"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/controllers.ProbeStatus"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "console.Session": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "password": {
+                    "description": "noVNC 连接时使用的 VNC 密码",
+                    "type": "string"
+                },
+                "url": {
+                    "description": "websocket 路径",
+                    "type": "string"
+                },
+                "user": {
+                    "type": "string"
+                },
+                "vmid": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.CreateInstanceRequest": {
+            "type": "object",
+            "properties": {
+                "owner": {
+                    "description": "仅管理员可为其他用户创建",
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.ProbeStatus": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "description": "每项检查的结果, ok 或错误信息",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "controllers.ReservationRequest": {
+            "type": "object",
+            "properties": {
+                "auto_reset": {
+                    "type": "boolean"
+                },
+                "end": {
+                    "description": "Unix 时间戳",
+                    "type": "integer"
+                },
+                "start": {
+                    "description": "Unix 时间戳",
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "user": {
+                    "description": "仅管理员可为 AUTH_USERS 中的其他用户预约",
+                    "type": "string"
+                }
+            }
+        },
+        "health.HostHealth": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "type": "string"
+                },
+                "domain": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "probes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/health.ProbeResult"
+                    }
+                },
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "health.LabHealth": {
+            "type": "object",
+            "properties": {
+                "checked_at": {
+                    "type": "integer"
+                },
+                "hosts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/health.HostHealth"
+                    }
+                },
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "health.ProbeResult": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string"
+                },
+                "latency_ms": {
+                    "type": "integer"
+                },
+                "name": {
+                    "description": "如 tcp/445, ldap, dns-srv",
+                    "type": "string"
+                },
+                "ok": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "instance.Instance": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "pool": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "vlan": {
+                    "type": "integer"
+                },
+                "vms": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/platform.VMInfo"
+                    }
+                }
+            }
+        },
+        "lab.Job": {
+            "type": "object",
+            "properties": {
+                "finished_at": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "step": {
+                    "type": "string"
+                },
+                "vms": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/lab.VMResult"
+                    }
+                }
+            }
+        },
+        "lab.VMResult": {
+            "type": "object",
+            "properties": {
+                "agent": {
+                    "description": "最终的 guest agent 状态",
+                    "type": "string"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "group": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "power": {
+                    "type": "string"
+                },
+                "rollback": {
+                    "description": "ok 或 failed, 仅限重置任务",
+                    "type": "string"
+                },
+                "services": {
+                    "description": "服务健康状态, 仅限配置在 LAB_HOSTS 中的主机",
+                    "type": "string"
+                },
+                "vmid": {
+                    "type": "string"
+                }
+            }
+        },
+        "notify.Delivery": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "event_id": {
+                    "type": "string"
+                },
+                "event_type": {
+                    "type": "string"
+                },
+                "notifier": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "time": {
+                    "description": "最后一次尝试的 Unix 时间戳",
+                    "type": "integer"
+                }
+            }
+        },
+        "notify.Event": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "id": {
+                    "type": "string"
+                },
+                "severity": {
+                    "type": "string"
+                },
+                "summary": {
+                    "type": "string"
+                },
+                "time": {
+                    "description": "Unix 时间戳",
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "platform.ARPEntry": {
+            "type": "object",
+            "properties": {
+                "expires": {
+                    "type": "string"
+                },
+                "hostname": {
+                    "type": "string"
+                },
+                "interface": {
+                    "type": "string"
+                },
+                "ip_address": {
+                    "type": "string"
+                },
+                "mac_address": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "platform.DHCPLease": {
+            "type": "object",
+            "properties": {
+                "active_status": {
+                    "type": "string"
+                },
+                "descr": {
+                    "type": "string"
+                },
+                "ends": {
+                    "type": "string"
+                },
+                "hostname": {
+                    "type": "string"
+                },
+                "if": {
+                    "type": "string"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "mac": {
+                    "type": "string"
+                },
+                "online_status": {
+                    "type": "string"
+                },
+                "starts": {
+                    "type": "string"
+                }
+            }
+        },
+        "platform.FirewallRule": {
+            "type": "object",
+            "properties": {
+                "descr": {
+                    "type": "string"
+                },
+                "disabled": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "interface": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "tracker": {
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "platform.GatewayHealth": {
+            "type": "object",
+            "properties": {
+                "gateways": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/platform.GatewayStatus"
+                    }
+                },
+                "interfaces": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/platform.InterfaceStatus"
+                    }
+                },
+                "reasons": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "services": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/platform.ServiceStatus"
+                    }
+                },
+                "state": {
+                    "type": "string"
+                },
+                "system": {
+                    "$ref": "#/definitions/platform.SystemStatus"
+                }
+            }
+        },
+        "platform.GatewayStatus": {
+            "type": "object",
+            "properties": {
+                "delay": {
+                    "type": "string"
+                },
+                "loss": {
+                    "type": "string"
+                },
+                "monitorip": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "substatus": {
+                    "type": "string"
+                }
+            }
+        },
+        "platform.InterfaceStatus": {
+            "type": "object",
+            "properties": {
+                "descr": {
+                    "type": "string"
+                },
+                "enable": {
+                    "type": "boolean"
+                },
+                "hwif": {
+                    "type": "string"
+                },
+                "inbytes": {
+                    "description": "总流入量 (字节)",
+                    "type": "integer"
+                },
+                "inerrs": {
+                    "type": "integer"
+                },
+                "inpkts": {
+                    "type": "integer"
+                },
+                "ipaddr": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "outbytes": {
+                    "description": "总流出量 (字节)",
+                    "type": "integer"
+                },
+                "outerrs": {
+                    "type": "integer"
+                },
+                "outpkts": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "platform.LeaseTable": {
+            "type": "object",
+            "properties": {
+                "arp": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/platform.ARPEntry"
+                    }
+                },
+                "leases": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/platform.DHCPLease"
+                    }
+                }
+            }
+        },
+        "platform.MetricPoint": {
+            "type": "object",
+            "properties": {
+                "cpu": {
+                    "description": "CPU 使用率 (0-1)",
+                    "type": "number"
+                },
+                "diskread": {
+                    "description": "磁盘读取速率 (字节/秒)",
+                    "type": "number"
+                },
+                "diskwrite": {
+                    "description": "磁盘写入速率 (字节/秒)",
+                    "type": "number"
+                },
+                "maxcpu": {
+                    "description": "CPU 数量",
+                    "type": "number"
+                },
+                "maxmem": {
+                    "description": "最大内存 (字节)",
+                    "type": "number"
+                },
+                "mem": {
+                    "description": "内存使用 (字节)",
+                    "type": "number"
+                },
+                "netin": {
+                    "description": "网络流入速率 (字节/秒)",
+                    "type": "number"
+                },
+                "netout": {
+                    "description": "网络流出速率 (字节/秒)",
+                    "type": "number"
+                },
+                "time": {
+                    "description": "Unix 时间戳",
+                    "type": "integer"
+                }
+            }
+        },
+        "platform.NetworkMode": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "rules": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/platform.FirewallRule"
+                    }
+                }
+            }
+        },
+        "platform.NetworkModes": {
+            "type": "object",
+            "properties": {
+                "current": {
+                    "type": "string"
+                },
+                "modes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/platform.NetworkMode"
+                    }
+                }
+            }
+        },
+        "platform.NodeStatus": {
+            "type": "object",
+            "properties": {
+                "cpu": {
+                    "description": "CPU 使用率 (0-1)",
+                    "type": "number"
+                },
+                "cpus": {
+                    "description": "CPU 数量",
+                    "type": "integer"
+                },
+                "kernel": {
+                    "type": "string"
+                },
+                "loadavg": {
+                    "description": "1, 5, 15 分钟平均负载",
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "maxmem": {
+                    "description": "总内存 (字节)",
+                    "type": "integer"
+                },
+                "mem": {
+                    "description": "已用内存 (字节)",
+                    "type": "integer"
+                },
+                "node": {
+                    "type": "string"
+                },
+                "pve_version": {
+                    "description": "例如 pve-manager/8.2.4/faa83925c9641325",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "uptime": {
+                    "description": "运行时间 (秒)",
+                    "type": "integer"
+                }
+            }
+        },
+        "platform.ServiceStatus": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "服务是否正在运行",
+                    "type": "boolean"
+                }
+            }
+        },
+        "platform.StorageInfo": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "avail": {
+                    "description": "可用 (字节)",
+                    "type": "integer"
+                },
+                "content": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "node": {
+                    "type": "string"
+                },
+                "shared": {
+                    "type": "boolean"
+                },
+                "storage": {
+                    "type": "string"
+                },
+                "total": {
+                    "description": "总容量 (字节)",
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "used": {
+                    "description": "已用 (字节)",
+                    "type": "integer"
+                },
+                "used_fraction": {
+                    "description": "使用率 (0-1)",
+                    "type": "number"
+                },
+                "warning": {
+                    "type": "string"
+                }
+            }
+        },
+        "platform.SystemStatus": {
+            "type": "object",
+            "properties": {
+                "cpu_count": {
+                    "type": "integer"
+                },
+                "cpu_load_avg": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "cpu_usage": {
+                    "description": "CPU 使用率 (%)",
+                    "type": "number"
+                },
+                "disk_usage": {
+                    "description": "磁盘使用率 (%)",
+                    "type": "number"
+                },
+                "mem_usage": {
+                    "description": "内存使用率 (%)",
+                    "type": "number"
+                },
+                "swap_usage": {
+                    "description": "交换分区使用率 (%)",
+                    "type": "number"
+                },
+                "temp_c": {
+                    "type": "number"
+                },
+                "uptime": {
+                    "type": "string"
+                }
+            }
+        },
+        "platform.VMInfo": {
+            "type": "object",
             "properties": {
+                "agent_status": {
+                    "description": "QEMU guest agent 状态",
+                    "type": "string"
+                },
                 "cpu": {
                     "description": "当前 CPU 使用率",
                     "type": "number"
@@ -297,9 +2627,31 @@ const docTemplate = `{
                     "description": "总磁盘写入量 (字节)",
                     "type": "integer"
                 },
+                "hostname": {
+                    "description": "QEMU guest agent 报告的主机名",
+                    "type": "string"
+                },
                 "id": {
                     "type": "string"
                 },
+                "ips": {
+                    "description": "QEMU guest agent 报告的 IP",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "lab_ip": {
+                    "description": "从 pfSense DHCP/ARP 表中查到的 IP",
+                    "type": "string"
+                },
+                "macs": {
+                    "description": "网卡 MAC 地址",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "maxdisk": {
                     "description": "根磁盘大小 (字节)",
                     "type": "integer"
@@ -326,6 +2678,10 @@ const docTemplate = `{
                 "node": {
                     "type": "string"
                 },
+                "os": {
+                    "description": "QEMU guest agent 报告的操作系统",
+                    "type": "string"
+                },
                 "status": {
                     "type": "string"
                 },
@@ -335,7 +2691,30 @@ const docTemplate = `{
                 }
             }
         },
-        "proxmox.VMOperationResult": {
+        "platform.VMMetrics": {
+            "type": "object",
+            "properties": {
+                "consolidation": {
+                    "type": "string"
+                },
+                "node": {
+                    "type": "string"
+                },
+                "points": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/platform.MetricPoint"
+                    }
+                },
+                "timeframe": {
+                    "type": "string"
+                },
+                "vmid": {
+                    "type": "string"
+                }
+            }
+        },
+        "platform.VMOperationResult": {
             "type": "object",
             "properties": {
                 "message": {
@@ -348,6 +2727,80 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "platform.VPNConnection": {
+            "type": "object",
+            "properties": {
+                "common_name": {
+                    "type": "string"
+                },
+                "connect_time_unix": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "reservation.Reservation": {
+            "type": "object",
+            "properties": {
+                "auto_reset": {
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "integer"
+                },
+                "end": {
+                    "description": "Unix 时间戳, 不含",
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "reset_job": {
+                    "description": "预约开始时自动重置的任务 ID",
+                    "type": "string"
+                },
+                "start": {
+                    "description": "Unix 时间戳",
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "user": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "upstream_error"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "proxmox request failed with status 500"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "source": {
+                    "description": "出错的上游: proxmox 或 pfsense",
+                    "type": "string",
+                    "example": "proxmox"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "BearerAuth": {
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
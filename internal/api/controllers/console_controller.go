@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/api/response"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/auth"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/console"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/errdefs"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/instance"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/reservation"
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/net/websocket"
+)
+
+// ConsoleController handles the VM console endpoints
+type ConsoleController struct {
+	hypervisor   platform.Hypervisor
+	consoles     *console.Manager
+	instances    *instance.Manager
+	reservations *reservation.Store
+}
+
+// NewConsoleController creates a new console controller. Consoles are available for the shared lab,
+// to admins and the holder of the current reservation, and for the instances of the user.
+func NewConsoleController(hypervisor platform.Hypervisor, consoles *console.Manager, instances *instance.Manager, reservations *reservation.Store) *ConsoleController {
+	return &ConsoleController{
+		hypervisor:   hypervisor,
+		consoles:     consoles,
+		instances:    instances,
+		reservations: reservations,
+	}
+}
+
+// OpenConsole handles POST /api/pve/vms/{vmid}/console
+// @Summary Open a VM console
+// @Description Opens a VNC console session for a running VM of the shared lab, for admins and the holder of the current reservation, or of an instance of the user. Connect noVNC to the returned websocket URL within 10 seconds and use the returned password.
+// @Tags CONSOLE
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param vmid path string true "VM ID"
+// @Success 201 {object} console.Session
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Router /api/pve/vms/{vmid}/console [post]
+func (c *ConsoleController) OpenConsole(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFromContext(r.Context())
+	vm, shared, err := c.findVM(r.Context(), chi.URLParam(r, "vmid"), user)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	if shared && !c.reservations.IsHolder(user) {
+		response.Forbidden(w, r, "the lab is not reserved by you right now")
+		return
+	}
+	if vm.Status != "running" {
+		response.Conflict(w, r, fmt.Sprintf("VM %s is %s", vm.ID, vm.Status))
+		return
+	}
+
+	session, err := c.consoles.Open(r.Context(), vm, user.Name)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusCreated, session)
+}
+
+// findVM looks a VM up in the shared lab, then in the instances, and reports whether it belongs to the shared lab.
+// VMs of instances owned by someone else are reported as missing.
+func (c *ConsoleController) findVM(ctx context.Context, vmID string, user *auth.User) (*platform.VMInfo, bool, error) {
+	vm, err := c.hypervisor.FindVM(ctx, vmID)
+	if !errors.Is(err, errdefs.ErrNotFound) || !c.instances.Enabled() {
+		return vm, err == nil, err
+	}
+
+	inst, vm, err := c.instances.FindVM(ctx, vmID)
+	if err != nil {
+		return nil, false, err
+	}
+	if !user.IsAdmin() && inst.Owner != user.Name {
+		return nil, false, fmt.Errorf("VM %s: %w", vmID, errdefs.ErrNotFound)
+	}
+	return vm, false, nil
+}
+
+// ConnectConsole handles GET /api/pve/vms/{vmid}/console
+// @Summary Connect to a VM console
+// @Description Upgrades to a websocket relaying the VNC stream of a session opened through POST. The session can be used once.
+// @Tags CONSOLE
+// @Param vmid path string true "VM ID"
+// @Param session query string true "Console session ID"
+// @Success 101
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/pve/vms/{vmid}/console [get]
+func (c *ConsoleController) ConnectConsole(w http.ResponseWriter, r *http.Request) {
+	session, err := c.consoles.Take(r.URL.Query().Get("session"), chi.URLParam(r, "vmid"))
+	if err != nil {
+		response.NotFound(w, r, err.Error())
+		return
+	}
+
+	server := websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			// Browsers always send an Origin, which has to be the dashboard itself
+			origin, err := websocket.Origin(config, r)
+			if err != nil || (origin != nil && origin.Host != r.Host) {
+				return fmt.Errorf("cross-origin console connection refused")
+			}
+			// noVNC asks for the binary subprotocol
+			for _, protocol := range config.Protocol {
+				if protocol == "binary" {
+					config.Protocol = []string{"binary"}
+					return nil
+				}
+			}
+			config.Protocol = nil
+			return nil
+		},
+		Handler: func(conn *websocket.Conn) {
+			c.consoles.Relay(conn, session)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/api/response"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/health"
+)
+
+// HealthController handles the lab service health endpoints
+type HealthController struct {
+	checker health.Prober
+}
+
+// NewHealthController creates a new health controller
+func NewHealthController(checker health.Prober) *HealthController {
+	return &HealthController{
+		checker: checker,
+	}
+}
+
+// GetLabHealth handles GET /api/health/lab
+// @Summary Get lab service health
+// @Description Probes the TCP services of every configured lab host, and LDAP and DNS SRV records of the domain controllers
+// @Tags HEALTH
+// @Accept json
+// @Produce json
+// @Success 200 {object} health.LabHealth
+// @Failure 429 {object} response.ErrorResponse
+// @Router /api/health/lab [get]
+func (c *HealthController) GetLabHealth(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, c.checker.Check(r.Context()))
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/api/response"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/auth"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/instance"
+	"github.com/go-chi/chi/v5"
+)
+
+// InstanceController handles the per-user lab instance endpoints
+type InstanceController struct {
+	instances *instance.Manager
+}
+
+// NewInstanceController creates a new instance controller
+func NewInstanceController(instances *instance.Manager) *InstanceController {
+	return &InstanceController{
+		instances: instances,
+	}
+}
+
+// CreateInstanceRequest is the body of POST /api/instances
+type CreateInstanceRequest struct {
+	Owner string `json:"owner,omitempty"` // 仅管理员可为其他用户创建
+}
+
+// GetInstances handles GET /api/instances
+// @Summary Get lab instances
+// @Description Retrieves the lab instances of the current user, or every instance for admins
+// @Tags INSTANCES
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} instance.Instance
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Router /api/instances [get]
+func (c *InstanceController) GetInstances(w http.ResponseWriter, r *http.Request) {
+	instances, err := c.instances.List(r.Context())
+	if err != nil {
+		c.error(w, r, err)
+		return
+	}
+
+	user := auth.UserFromContext(r.Context())
+	visible := []instance.Instance{}
+	for _, inst := range instances {
+		if user.IsAdmin() || inst.Owner == user.Name {
+			visible = append(visible, inst)
+		}
+	}
+	response.JSON(w, http.StatusOK, visible)
+}
+
+// GetInstance handles GET /api/instances/{id}
+// @Summary Get a lab instance
+// @Description Retrieves a lab instance owned by the current user
+// @Tags INSTANCES
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Instance ID"
+// @Success 200 {object} instance.Instance
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Router /api/instances/{id} [get]
+func (c *InstanceController) GetInstance(w http.ResponseWriter, r *http.Request) {
+	inst, ok := c.owned(w, r)
+	if !ok {
+		return
+	}
+	response.JSON(w, http.StatusOK, inst)
+}
+
+// CreateInstance handles POST /api/instances
+// @Summary Create a lab instance
+// @Description Creates a private lab instance by linked-cloning the templates into a dedicated pool and VLAN. Provisioning continues in the background; poll the instance until it is ready.
+// @Tags INSTANCES
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateInstanceRequest false "Owner of the instance, admins only"
+// @Success 202 {object} instance.Instance
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Router /api/instances [post]
+func (c *InstanceController) CreateInstance(w http.ResponseWriter, r *http.Request) {
+	var req CreateInstanceRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.BadRequest(w, r, "invalid request body")
+			return
+		}
+	}
+
+	user := auth.UserFromContext(r.Context())
+	owner := user.Name
+	if req.Owner != "" && req.Owner != user.Name {
+		if !user.IsAdmin() {
+			response.Forbidden(w, r, "only admins can create instances for other users")
+			return
+		}
+		owner = req.Owner
+	}
+
+	inst, err := c.instances.Create(r.Context(), owner, !user.IsAdmin())
+	if err != nil {
+		c.error(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusAccepted, inst)
+}
+
+// DeleteInstance handles DELETE /api/instances/{id}
+// @Summary Delete a lab instance
+// @Description Stops and destroys the VMs of an instance and deletes its pool in the background
+// @Tags INSTANCES
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Instance ID"
+// @Success 202 {object} instance.Instance
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Router /api/instances/{id} [delete]
+func (c *InstanceController) DeleteInstance(w http.ResponseWriter, r *http.Request) {
+	inst, ok := c.owned(w, r)
+	if !ok {
+		return
+	}
+
+	inst, err := c.instances.Delete(r.Context(), inst.ID)
+	if err != nil {
+		c.error(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusAccepted, inst)
+}
+
+// owned looks up the instance of the request and checks that the user may see it.
+// Instances of other users are reported as missing.
+func (c *InstanceController) owned(w http.ResponseWriter, r *http.Request) (*instance.Instance, bool) {
+	inst, err := c.instances.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		c.error(w, r, err)
+		return nil, false
+	}
+
+	user := auth.UserFromContext(r.Context())
+	if !user.IsAdmin() && inst.Owner != user.Name {
+		response.NotFound(w, r, "no such instance")
+		return nil, false
+	}
+	return inst, true
+}
+
+// error maps instance errors to responses
+func (c *InstanceController) error(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, instance.ErrNotConfigured):
+		response.NotFound(w, r, err.Error())
+	case errors.Is(err, instance.ErrLimitReached), errors.Is(err, instance.ErrNoVLAN), errors.Is(err, instance.ErrBusy):
+		response.Conflict(w, r, err.Error())
+	default:
+		response.Error(w, r, err)
+	}
+}
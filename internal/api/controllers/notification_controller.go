@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/api/response"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/notify"
+)
+
+// NotificationController handles the notification endpoints
+type NotificationController struct {
+	hub *notify.Hub
+}
+
+// NewNotificationController creates a new notification controller
+func NewNotificationController(hub *notify.Hub) *NotificationController {
+	return &NotificationController{
+		hub: hub,
+	}
+}
+
+// GetDeliveries handles GET /api/notifications/deliveries
+// @Summary Get notification deliveries
+// @Description Retrieves the recent notification deliveries and their outcome, newest first
+// @Tags NOTIFICATIONS
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} notify.Delivery
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Router /api/notifications/deliveries [get]
+func (c *NotificationController) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, c.hub.Deliveries())
+}
+
+// SendTest handles POST /api/notifications/test
+// @Summary Send a test notification
+// @Description Sends a test event to every notifier. The outcome shows up in the delivery log.
+// @Tags NOTIFICATIONS
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} notify.Event
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Router /api/notifications/test [post]
+func (c *NotificationController) SendTest(w http.ResponseWriter, r *http.Request) {
+	if !c.hub.Enabled() {
+		response.NotFound(w, r, "no notifiers are configured")
+		return
+	}
+
+	event := c.hub.Publish(notify.Event{
+		Type:    notify.EventTest,
+		Summary: "Test notification from the GOAD dashboard",
+	})
+	response.JSON(w, http.StatusAccepted, event)
+}
@@ -1,19 +1,22 @@
 package controllers
 
 import (
-	"encoding/json"
+	"context"
+	"fmt"
 	"net/http"
 
-	"github.com/chunzhennn/GOAD-Dashboard/internal/platform/pfsense"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/api/response"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+	"github.com/go-chi/chi/v5"
 )
 
 type PfsenseController struct {
-	pfsenseClient *pfsense.PfsenseClient
+	gateway platform.VPNGateway
 }
 
-func NewPfsenseController(pfsenseClient *pfsense.PfsenseClient) *PfsenseController {
+func NewPfsenseController(gateway platform.VPNGateway) *PfsenseController {
 	return &PfsenseController{
-		pfsenseClient: pfsenseClient,
+		gateway: gateway,
 	}
 }
 
@@ -23,14 +26,108 @@ func NewPfsenseController(pfsenseClient *pfsense.PfsenseClient) *PfsenseControll
 // @Tags PFSENSE
 // @Accept json
 // @Produce json
-// @Success 200 {array} pfsense.PfsenseOpenVPNConnection
-// @Failure 500 {object} map[string]string
+// @Success 200 {array} platform.VPNConnection
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
 // @Router /api/pfsense/openvpn/connections [get]
 func (c *PfsenseController) GetOpenVPNConnections(w http.ResponseWriter, r *http.Request) {
-	connections, err := c.pfsenseClient.GetOpenVPNConnections()
+	connections, err := c.gateway.GetOpenVPNConnections(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, err)
 		return
 	}
-	json.NewEncoder(w).Encode(connections)
+	response.JSON(w, http.StatusOK, connections)
+}
+
+// GetNetworkModes handles GET /api/pfsense/modes
+// @Summary Get network modes
+// @Description Retrieves the available network modes, their firewall rules and the active mode
+// @Tags PFSENSE
+// @Accept json
+// @Produce json
+// @Success 200 {object} platform.NetworkModes
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Router /api/pfsense/modes [get]
+func (c *PfsenseController) GetNetworkModes(w http.ResponseWriter, r *http.Request) {
+	modes, err := c.gateway.GetNetworkModes(r.Context())
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, modes)
+}
+
+// SetNetworkMode handles POST /api/pfsense/modes/{mode}
+// @Summary Switch network mode
+// @Description Enables the firewall rules of a network mode (normal, exam or isolated) and applies the changes
+// @Tags PFSENSE
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param mode path string true "Network mode" Enums(normal, exam, isolated)
+// @Success 200 {object} platform.NetworkModes
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Router /api/pfsense/modes/{mode} [post]
+func (c *PfsenseController) SetNetworkMode(w http.ResponseWriter, r *http.Request) {
+	mode := chi.URLParam(r, "mode")
+	if !platform.IsNetworkMode(mode) {
+		response.BadRequest(w, r, fmt.Sprintf("unknown network mode %q", mode))
+		return
+	}
+
+	// Finish toggling the rules even if the client goes away, a half-applied mode is worse than either
+	err := c.gateway.SetNetworkMode(context.WithoutCancel(r.Context()), mode)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	modes, err := c.gateway.GetNetworkModes(r.Context())
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, modes)
+}
+
+// GetHealth handles GET /api/pfsense/health
+// @Summary Get pfSense health
+// @Description Retrieves system, service, gateway and interface status summarised as healthy, degraded or down
+// @Tags PFSENSE
+// @Accept json
+// @Produce json
+// @Success 200 {object} platform.GatewayHealth
+// @Failure 429 {object} response.ErrorResponse
+// @Router /api/pfsense/health [get]
+func (c *PfsenseController) GetHealth(w http.ResponseWriter, r *http.Request) {
+	health := c.gateway.GetHealth(r.Context())
+	response.JSON(w, http.StatusOK, health)
+}
+
+// GetLeases handles GET /api/pfsense/leases
+// @Summary Get DHCP leases and ARP table
+// @Description Retrieves the DHCP server leases and the ARP table of pfSense
+// @Tags PFSENSE
+// @Accept json
+// @Produce json
+// @Success 200 {object} platform.LeaseTable
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Router /api/pfsense/leases [get]
+func (c *PfsenseController) GetLeases(w http.ResponseWriter, r *http.Request) {
+	table, err := c.gateway.GetLeaseTable(r.Context())
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, table)
 }
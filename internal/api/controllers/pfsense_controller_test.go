@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform/upstream"
+	"github.com/go-chi/chi/v5"
+)
+
+// stubGateway answers the calls the tests make. Any other call panics through the nil embedded interface.
+type stubGateway struct {
+	platform.VPNGateway
+	connections []platform.VPNConnection
+	mode        string
+	err         error
+}
+
+func (g *stubGateway) GetOpenVPNConnections(ctx context.Context) ([]platform.VPNConnection, error) {
+	return g.connections, g.err
+}
+
+func (g *stubGateway) SetNetworkMode(ctx context.Context, name string) error {
+	if g.err == nil {
+		g.mode = name
+	}
+	return g.err
+}
+
+func (g *stubGateway) GetNetworkModes(ctx context.Context) (*platform.NetworkModes, error) {
+	return &platform.NetworkModes{Current: g.mode}, nil
+}
+
+func TestGetOpenVPNConnections(t *testing.T) {
+	tests := []struct {
+		name    string
+		gateway *stubGateway
+		status  int
+	}{
+		{"connected", &stubGateway{connections: []platform.VPNConnection{{Id: 1, Name: "alice", ConnectTime: 1700000000}}}, http.StatusOK},
+		{"upstream error", &stubGateway{err: &upstream.Error{Source: upstream.SourcePfsense, StatusCode: http.StatusInternalServerError}}, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			NewPfsenseController(tt.gateway).GetOpenVPNConnections(w, httptest.NewRequest("GET", "/api/pfsense/openvpn/connections", nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d", w.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var connections []platform.VPNConnection
+			if err := json.NewDecoder(w.Body).Decode(&connections); err != nil || len(connections) != 1 || connections[0].Name != "alice" {
+				t.Errorf("got %+v %v, want alice", connections, err)
+			}
+		})
+	}
+}
+
+func TestSetNetworkMode(t *testing.T) {
+	tests := []struct {
+		mode   string
+		status int
+		want   string
+	}{
+		{"exam", http.StatusOK, "exam"},
+		{"open", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			gateway := &stubGateway{}
+			router := chi.NewRouter()
+			router.Post("/modes/{mode}", NewPfsenseController(gateway).SetNetworkMode)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("POST", "/modes/"+tt.mode, nil))
+
+			if w.Code != tt.status || gateway.mode != tt.want {
+				t.Errorf("status %d with mode %q, want %d with %q", w.Code, gateway.mode, tt.status, tt.want)
+			}
+		})
+	}
+}
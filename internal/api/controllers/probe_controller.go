@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/api/response"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform/upstream"
+)
+
+// readyTimeout bounds each upstream check of the readiness probe
+const readyTimeout = 5 * time.Second
+
+// Probe states
+const (
+	ProbeOK          = "ok"
+	ProbeUnavailable = "unavailable"
+	ProbeDraining    = "draining"
+)
+
+// ProbeStatus is the body of the liveness and readiness probes
+type ProbeStatus struct {
+	Status string            `json:"status" example:"ok"`
+	Checks map[string]string `json:"checks,omitempty"` // 每项检查的结果, ok 或错误信息
+}
+
+// ProbeController handles the liveness and readiness probes of container orchestrators
+type ProbeController struct {
+	hypervisor platform.Hypervisor
+	gateway    platform.VPNGateway
+	draining   atomic.Bool
+}
+
+// NewProbeController creates a new probe controller
+func NewProbeController(hypervisor platform.Hypervisor, gateway platform.VPNGateway) *ProbeController {
+	return &ProbeController{
+		hypervisor: hypervisor,
+		gateway:    gateway,
+	}
+}
+
+// Drain makes the readiness probe fail so that no new traffic is routed to the server while it shuts down
+func (c *ProbeController) Drain() {
+	c.draining.Store(true)
+}
+
+// Healthz handles GET /healthz
+// @Summary Liveness probe
+// @Description Reports that the process is up. It does not contact Proxmox or pfSense.
+// @Tags PROBES
+// @Produce json
+// @Success 200 {object} ProbeStatus
+// @Router /healthz [get]
+func (c *ProbeController) Healthz(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, ProbeStatus{Status: ProbeOK})
+}
+
+// Readyz handles GET /readyz
+// @Summary Readiness probe
+// @Description Reports whether Proxmox and pfSense are reachable. The configuration is validated on startup, so a running server always has a valid one. Fails while the server shuts down.
+// @Tags PROBES
+// @Produce json
+// @Success 200 {object} ProbeStatus
+// @Failure 503 {object} ProbeStatus
+// @Router /readyz [get]
+func (c *ProbeController) Readyz(w http.ResponseWriter, r *http.Request) {
+	if c.draining.Load() {
+		response.JSON(w, http.StatusServiceUnavailable, ProbeStatus{Status: ProbeDraining})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	checks := map[string]func(ctx context.Context) error{
+		"proxmox": func(ctx context.Context) error {
+			_, err := c.hypervisor.GetNodes(ctx)
+			return err
+		},
+		"pfsense": func(ctx context.Context) error {
+			_, err := c.gateway.GetSystemStatus(ctx)
+			return err
+		},
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	// 配置在启动时已校验, 无效的配置会使进程退出
+	status := ProbeStatus{Status: ProbeOK, Checks: map[string]string{"config": ProbeOK}}
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+			result := ProbeOK
+			if err := check(ctx); err != nil {
+				result = probeError(err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			status.Checks[name] = result
+			if result != ProbeOK {
+				status.Status = ProbeUnavailable
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	if status.Status != ProbeOK {
+		code = http.StatusServiceUnavailable
+	}
+	response.JSON(w, code, status)
+}
+
+// probeError describes a failed check without leaking upstream details
+func probeError(err error) string {
+	var upstreamErr *upstream.Error
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr.PublicMessage()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timed out"
+	}
+	return err.Error()
+}
@@ -1,76 +1,291 @@
 package controllers
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
-	"github.com/chunzhennn/GOAD-Dashboard/internal/platform/proxmox"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/api/response"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/lab"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+	"github.com/go-chi/chi/v5"
 )
 
 // PVEController handles all PVE-related endpoints
 type PVEController struct {
-	pveClient *proxmox.PVEClient
+	hypervisor platform.Hypervisor
+	gateway    platform.VPNGateway
+	lab        *lab.Lab
 }
 
-// NewPVEController creates a new PVE controller
-func NewPVEController(pveClient *proxmox.PVEClient) *PVEController {
+// NewPVEController creates a new PVE controller. The VPN gateway is used to look up the lab IP of each VM.
+func NewPVEController(hypervisor platform.Hypervisor, gateway platform.VPNGateway, lab *lab.Lab) *PVEController {
 	return &PVEController{
-		pveClient: pveClient,
+		hypervisor: hypervisor,
+		gateway:    gateway,
+		lab:        lab,
 	}
 }
 
 // GetVMs handles GET /api/pve/vms
 // @Summary Get all VMs
-// @Description Retrieves information about all virtual machines
+// @Description Retrieves information about all virtual machines, including their MAC addresses, current lab IP and guest agent details
 // @Tags PVE
 // @Accept json
 // @Produce json
-// @Success 200 {array} proxmox.VMInfo
-// @Failure 500 {object} map[string]string
+// @Success 200 {array} platform.VMInfo
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
 // @Router /api/pve/vms [get]
 func (c *PVEController) GetVMs(w http.ResponseWriter, r *http.Request) {
-	vms, err := c.pveClient.GetVMs()
+	vms, err := c.hypervisor.GetVMs(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, err)
 		return
 	}
-	json.NewEncoder(w).Encode(vms)
+	c.addLabIPs(r.Context(), vms)
+	c.hypervisor.AddGuestInfo(r.Context(), vms)
+	response.JSON(w, http.StatusOK, vms)
+}
+
+// GetNodes handles GET /api/pve/nodes
+// @Summary Get nodes
+// @Description Retrieves the CPU, memory, uptime, load and version of every Proxmox node
+// @Tags PVE
+// @Accept json
+// @Produce json
+// @Success 200 {array} platform.NodeStatus
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Router /api/pve/nodes [get]
+func (c *PVEController) GetNodes(w http.ResponseWriter, r *http.Request) {
+	nodes, err := c.hypervisor.GetNodeStatuses(r.Context())
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, nodes)
+}
+
+// GetStorage handles GET /api/pve/storage
+// @Summary Get storage usage
+// @Description Retrieves the usage of every storage. Storage holding VM disks and snapshots carries a warning once it crosses STORAGE_WARNING_PERCENT.
+// @Tags PVE
+// @Accept json
+// @Produce json
+// @Success 200 {array} platform.StorageInfo
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Router /api/pve/storage [get]
+func (c *PVEController) GetStorage(w http.ResponseWriter, r *http.Request) {
+	storages, err := c.hypervisor.GetStorage(r.Context())
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, storages)
+}
+
+// GetVMMetrics handles GET /api/pve/vms/{vmid}/metrics
+// @Summary Get VM metrics
+// @Description Retrieves the CPU, memory, disk I/O and network history of a virtual machine from the Proxmox RRD data
+// @Tags PVE
+// @Accept json
+// @Produce json
+// @Param vmid path string true "VM ID"
+// @Param timeframe query string false "hour, day or week (default hour)"
+// @Param cf query string false "avg or max (default avg)"
+// @Success 200 {object} platform.VMMetrics
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Router /api/pve/vms/{vmid}/metrics [get]
+func (c *PVEController) GetVMMetrics(w http.ResponseWriter, r *http.Request) {
+	timeframe := r.URL.Query().Get("timeframe")
+	if timeframe == "" {
+		timeframe = platform.TimeframeHour
+	}
+	if !platform.IsTimeframe(timeframe) {
+		response.BadRequest(w, r, fmt.Sprintf("unknown timeframe %q", timeframe))
+		return
+	}
+
+	var consolidation string
+	switch cf := r.URL.Query().Get("cf"); cf {
+	case "", "avg":
+		consolidation = platform.ConsolidationAverage
+	case "max":
+		consolidation = platform.ConsolidationMax
+	default:
+		response.BadRequest(w, r, fmt.Sprintf("unknown consolidation function %q", cf))
+		return
+	}
+
+	vm, err := c.hypervisor.FindVM(r.Context(), chi.URLParam(r, "vmid"))
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	metrics, err := c.hypervisor.GetVMMetrics(r.Context(), vm.Node, vm.ID, timeframe, consolidation)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, metrics)
+}
+
+// addLabIPs correlates the MAC addresses of each VM with the pfSense DHCP leases and ARP table.
+// Lookup failures only leave the fields empty.
+func (c *PVEController) addLabIPs(ctx context.Context, vms []platform.VMInfo) {
+	table, err := c.gateway.GetLeaseTable(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to get lease table", "error", err)
+		return
+	}
+	ips := table.IPsByMAC()
+
+	for i := range vms {
+		macs, err := c.hypervisor.GetVMMACs(ctx, vms[i].Node, vms[i].ID)
+		if err != nil {
+			slog.WarnContext(ctx, "failed to get MAC addresses", "vmid", vms[i].ID, "error", err)
+			continue
+		}
+		vms[i].MACs = macs
+		for _, mac := range macs {
+			if ip, ok := ips[mac]; ok {
+				vms[i].LabIP = ip
+				break
+			}
+		}
+	}
 }
 
 // StartAllVMs handles POST /api/pve/vms/start
 // @Summary Start all VMs
-// @Description Starts all virtual machines
+// @Description Starts a job that starts the stopped virtual machines boot group by boot group, honouring the group delays and readiness waits
 // @Tags PVE
 // @Accept json
 // @Produce json
-// @Success 200 {array} proxmox.VMOperationResult
-// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Success 202 {object} lab.Job
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
 // @Router /api/pve/vms/start [post]
 func (c *PVEController) StartAllVMs(w http.ResponseWriter, r *http.Request) {
-	results, err := c.pveClient.StartAllVMs()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	json.NewEncoder(w).Encode(results)
+	c.startJob(w, r, c.lab.StartAll)
 }
 
 // StopAllVMs handles POST /api/pve/vms/stop
 // @Summary Stop all VMs
-// @Description Stops all virtual machines
+// @Description Hard stops all virtual machines, like pulling the power cord. Prefer /api/pve/vms/shutdown.
 // @Tags PVE
 // @Accept json
 // @Produce json
-// @Success 200 {array} proxmox.VMOperationResult
-// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Success 200 {array} platform.VMOperationResult
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
 // @Router /api/pve/vms/stop [post]
 func (c *PVEController) StopAllVMs(w http.ResponseWriter, r *http.Request) {
-	results, err := c.pveClient.StopAllVMs()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	c.powerAll(w, r, c.hypervisor.StopAllVMs)
+}
+
+// ShutdownAllVMs handles POST /api/pve/vms/shutdown
+// @Summary Shut down all VMs
+// @Description Starts a job that shuts the running virtual machines down through ACPI in reverse boot order. VMs still running after the timeout are stopped unless force is false.
+// @Tags PVE
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param timeout query string false "ACPI shutdown timeout such as 90s, defaults to LAB_SHUTDOWN_TIMEOUT and must be shorter than LAB_JOB_TIMEOUT"
+// @Param force query bool false "Stop VMs that do not shut down in time (default true)"
+// @Success 202 {object} lab.Job
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Router /api/pve/vms/shutdown [post]
+func (c *PVEController) ShutdownAllVMs(w http.ResponseWriter, r *http.Request) {
+	opts := lab.ShutdownOptions{ForceStop: true}
+
+	if value := r.URL.Query().Get("timeout"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			response.BadRequest(w, r, fmt.Sprintf("invalid timeout %q", value))
+			return
+		}
+		if timeout >= c.lab.Jobs().Timeout() {
+			response.BadRequest(w, r, fmt.Sprintf("timeout must be shorter than the job timeout of %s", c.lab.Jobs().Timeout()))
+			return
+		}
+		opts.Timeout = timeout
+	}
+	if value := r.URL.Query().Get("force"); value != "" {
+		force, err := strconv.ParseBool(value)
+		if err != nil {
+			response.BadRequest(w, r, fmt.Sprintf("invalid force %q", value))
+			return
+		}
+		opts.ForceStop = force
 	}
-	json.NewEncoder(w).Encode(results)
+
+	c.startJob(w, r, func(ctx context.Context) (*lab.Job, error) {
+		return c.lab.ShutdownAll(ctx, opts)
+	})
+}
+
+// SuspendAllVMs handles POST /api/pve/vms/suspend
+// @Summary Suspend all VMs
+// @Description Suspends all virtual machines, keeping their memory in place
+// @Tags PVE
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} platform.VMOperationResult
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Router /api/pve/vms/suspend [post]
+func (c *PVEController) SuspendAllVMs(w http.ResponseWriter, r *http.Request) {
+	c.powerAll(w, r, c.hypervisor.SuspendAllVMs)
+}
+
+// ResumeAllVMs handles POST /api/pve/vms/resume
+// @Summary Resume all VMs
+// @Description Resumes all suspended virtual machines
+// @Tags PVE
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} platform.VMOperationResult
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Router /api/pve/vms/resume [post]
+func (c *PVEController) ResumeAllVMs(w http.ResponseWriter, r *http.Request) {
+	c.powerAll(w, r, c.hypervisor.ResumeAllVMs)
 }
 
 // ResetAllVMs handles POST /api/pve/vms/reset
@@ -79,16 +294,36 @@ func (c *PVEController) StopAllVMs(w http.ResponseWriter, r *http.Request) {
 // @Tags PVE
 // @Accept json
 // @Produce json
-// @Success 200 {array} proxmox.VMOperationResult
-// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Success 200 {array} platform.VMOperationResult
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
 // @Router /api/pve/vms/reset [post]
 func (c *PVEController) ResetAllVMs(w http.ResponseWriter, r *http.Request) {
-	results, err := c.pveClient.ResetAllVMs()
+	c.powerAll(w, r, c.hypervisor.ResetAllVMs)
+}
+
+// powerAll runs a power operation on all VMs and answers with the results, or 409 while a lab job is running
+func (c *PVEController) powerAll(w http.ResponseWriter, r *http.Request, op func(ctx context.Context) ([]platform.VMOperationResult, error)) {
+	var results []platform.VMOperationResult
+	err := c.lab.Jobs().Exclusive(func() error {
+		var err error
+		results, err = op(r.Context())
+		return err
+	})
+	if errors.Is(err, lab.ErrJobInProgress) {
+		response.Conflict(w, r, err.Error())
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, err)
 		return
 	}
-	json.NewEncoder(w).Encode(results)
+	response.JSON(w, http.StatusOK, results)
 }
 
 // GetLastReset handles GET /api/pve/reset
@@ -98,33 +333,80 @@ func (c *PVEController) ResetAllVMs(w http.ResponseWriter, r *http.Request) {
 // @Accept json
 // @Produce json
 // @Success 200 {object} map[string]uint64
-// @Failure 500 {object} map[string]string
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
 // @Router /api/pve/reset [get]
 func (c *PVEController) GetLastReset(w http.ResponseWriter, r *http.Request) {
-	lastReset, err := c.pveClient.GetLastReset()
+	lastReset, err := c.hypervisor.GetLastReset()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(lastReset)
+	response.JSON(w, http.StatusOK, lastReset)
 }
 
 // ResetLab handles POST /api/pve/reset
 // @Summary Reset the lab
-// @Description Resets all VMs to their latest snapshots
+// @Description Starts a reset job that rolls all VMs back to their latest snapshots, starts stopped VMs in boot order and waits for guest agents and lab services
 // @Tags PVE
 // @Accept json
 // @Produce json
-// @Success 200 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Success 202 {object} lab.Job
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
 // @Router /api/pve/reset [post]
 func (c *PVEController) ResetLab(w http.ResponseWriter, r *http.Request) {
-	err := c.pveClient.ResetLab()
+	c.startJob(w, r, c.lab.Reset)
+}
+
+// startJob starts a lab job and answers 202 with it, or 409 while another job is running
+func (c *PVEController) startJob(w http.ResponseWriter, r *http.Request, start func(ctx context.Context) (*lab.Job, error)) {
+	// The job outlives the request, the client follows it through /api/pve/jobs/{id}
+	job, err := start(context.WithoutCancel(r.Context()))
+	if errors.Is(err, lab.ErrJobInProgress) {
+		response.Conflict(w, r, err.Error())
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusAccepted, job)
+}
+
+// GetJobs handles GET /api/pve/jobs
+// @Summary Get lab jobs
+// @Description Retrieves the recent start, shutdown and reset jobs, newest first
+// @Tags PVE
+// @Accept json
+// @Produce json
+// @Success 200 {array} lab.Job
+// @Failure 429 {object} response.ErrorResponse
+// @Router /api/pve/jobs [get]
+func (c *PVEController) GetJobs(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, c.lab.Jobs().Jobs())
+}
+
+// GetJob handles GET /api/pve/jobs/{id}
+// @Summary Get a lab job
+// @Description Retrieves the progress and outcome of a start, shutdown or reset job
+// @Tags PVE
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} lab.Job
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Router /api/pve/jobs/{id} [get]
+func (c *PVEController) GetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := c.lab.Jobs().Get(chi.URLParam(r, "id"))
+	if !ok {
+		response.NotFound(w, r, "no such job")
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"message": "VMs reset successfully"}`))
+	response.JSON(w, http.StatusOK, job)
 }
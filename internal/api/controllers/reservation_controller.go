@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/api/response"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/auth"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/reservation"
+	"github.com/go-chi/chi/v5"
+)
+
+// ReservationController handles the lab reservation endpoints
+type ReservationController struct {
+	store *reservation.Store
+	users *auth.Authenticator
+}
+
+// NewReservationController creates a new reservation controller
+func NewReservationController(store *reservation.Store, users *auth.Authenticator) *ReservationController {
+	return &ReservationController{
+		store: store,
+		users: users,
+	}
+}
+
+// ReservationRequest is the body of POST and PUT /api/reservations
+type ReservationRequest struct {
+	Title     string `json:"title"`
+	Start     int64  `json:"start"` // Unix 时间戳
+	End       int64  `json:"end"`   // Unix 时间戳
+	AutoReset *bool  `json:"auto_reset,omitempty"`
+	User      string `json:"user,omitempty"` // 仅管理员可为 AUTH_USERS 中的其他用户预约
+}
+
+// GetReservations handles GET /api/reservations
+// @Summary Get reservations
+// @Description Retrieves every reservation of the lab, ordered by start time
+// @Tags RESERVATIONS
+// @Accept json
+// @Produce json
+// @Success 200 {array} reservation.Reservation
+// @Failure 429 {object} response.ErrorResponse
+// @Router /api/reservations [get]
+func (c *ReservationController) GetReservations(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, c.store.List())
+}
+
+// GetCurrentReservation handles GET /api/reservations/current
+// @Summary Get the current reservation
+// @Description Retrieves the reservation holding the lab right now
+// @Tags RESERVATIONS
+// @Accept json
+// @Produce json
+// @Success 200 {object} reservation.Reservation
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Router /api/reservations/current [get]
+func (c *ReservationController) GetCurrentReservation(w http.ResponseWriter, r *http.Request) {
+	current := c.store.Current(time.Now())
+	if current == nil {
+		response.NotFound(w, r, "the lab is not reserved right now")
+		return
+	}
+	response.JSON(w, http.StatusOK, current)
+}
+
+// GetReservation handles GET /api/reservations/{id}
+// @Summary Get a reservation
+// @Description Retrieves a reservation
+// @Tags RESERVATIONS
+// @Accept json
+// @Produce json
+// @Param id path string true "Reservation ID"
+// @Success 200 {object} reservation.Reservation
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Router /api/reservations/{id} [get]
+func (c *ReservationController) GetReservation(w http.ResponseWriter, r *http.Request) {
+	res, err := c.store.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, res)
+}
+
+// GetCalendar handles GET /api/reservations/calendar.ics
+// @Summary Get the reservation calendar
+// @Description Exports the reservations as an iCalendar feed that calendar applications can subscribe to
+// @Tags RESERVATIONS
+// @Produce text/calendar
+// @Success 200 {string} string
+// @Failure 429 {object} response.ErrorResponse
+// @Router /api/reservations/calendar.ics [get]
+func (c *ReservationController) GetCalendar(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="goad-reservations.ics"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(reservation.ICalendar(c.store.List(), r.Host)))
+}
+
+// CreateReservation handles POST /api/reservations
+// @Summary Book the lab
+// @Description Reserves the lab for a time slot. While it lasts, the holder may start, stop and reset the lab. Admins may book for another user configured in AUTH_USERS.
+// @Tags RESERVATIONS
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ReservationRequest true "Time slot"
+// @Success 201 {object} reservation.Reservation
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Router /api/reservations [post]
+func (c *ReservationController) CreateReservation(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeReservation(w, r)
+	if !ok {
+		return
+	}
+
+	user := auth.UserFromContext(r.Context())
+	holder := user.Name
+	if req.User != "" && req.User != user.Name {
+		if !user.IsAdmin() {
+			response.Forbidden(w, r, "only admins can book for other users")
+			return
+		}
+		// A reservation of an unknown user could never be used
+		if !c.users.HasUser(req.User) {
+			response.BadRequest(w, r, fmt.Sprintf("unknown user %q", req.User))
+			return
+		}
+		holder = req.User
+	}
+
+	res, err := c.store.Create(holder, slotOf(req), !user.IsAdmin())
+	if err != nil {
+		c.error(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusCreated, res)
+}
+
+// UpdateReservation handles PUT /api/reservations/{id}
+// @Summary Change a reservation
+// @Description Moves or renames a reservation of the current user
+// @Tags RESERVATIONS
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Reservation ID"
+// @Param request body ReservationRequest true "Time slot"
+// @Success 200 {object} reservation.Reservation
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Router /api/reservations/{id} [put]
+func (c *ReservationController) UpdateReservation(w http.ResponseWriter, r *http.Request) {
+	if !c.owned(w, r) {
+		return
+	}
+	req, ok := decodeReservation(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := c.store.Update(chi.URLParam(r, "id"), slotOf(req))
+	if err != nil {
+		c.error(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, res)
+}
+
+// DeleteReservation handles DELETE /api/reservations/{id}
+// @Summary Cancel a reservation
+// @Description Cancels a reservation of the current user
+// @Tags RESERVATIONS
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Reservation ID"
+// @Success 204
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Router /api/reservations/{id} [delete]
+func (c *ReservationController) DeleteReservation(w http.ResponseWriter, r *http.Request) {
+	if !c.owned(w, r) {
+		return
+	}
+
+	if err := c.store.Delete(chi.URLParam(r, "id")); err != nil {
+		c.error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// owned checks that the reservation of the request belongs to the user, or that the user is an admin
+func (c *ReservationController) owned(w http.ResponseWriter, r *http.Request) bool {
+	res, err := c.store.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, r, err)
+		return false
+	}
+
+	user := auth.UserFromContext(r.Context())
+	if !user.IsAdmin() && res.User != user.Name {
+		response.Forbidden(w, r, "this reservation belongs to another user")
+		return false
+	}
+	return true
+}
+
+// error maps reservation errors to responses
+func (c *ReservationController) error(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, reservation.ErrInvalid):
+		response.BadRequest(w, r, err.Error())
+	case errors.Is(err, reservation.ErrOverlap), errors.Is(err, reservation.ErrLimitReached):
+		response.Conflict(w, r, err.Error())
+	default:
+		response.Error(w, r, err)
+	}
+}
+
+// decodeReservation reads the body of a reservation request
+func decodeReservation(w http.ResponseWriter, r *http.Request) (*ReservationRequest, bool) {
+	var req ReservationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, r, "invalid request body")
+		return nil, false
+	}
+	return &req, true
+}
+
+// slotOf returns the time slot of a reservation request
+func slotOf(req *ReservationRequest) reservation.Slot {
+	return reservation.Slot{
+		Title:     req.Title,
+		Start:     req.Start,
+		End:       req.End,
+		AutoReset: req.AutoReset,
+	}
+}
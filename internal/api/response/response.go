@@ -0,0 +1,126 @@
+package response
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/errdefs"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform/upstream"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Error codes returned in ErrorResponse.Code
+const (
+	CodeBadRequest      = "bad_request"
+	CodeUnauthorized    = "unauthorized"
+	CodeForbidden       = "forbidden"
+	CodeNotFound        = "not_found"
+	CodeConflict        = "conflict"
+	CodeRateLimited     = "rate_limited"
+	CodeUpstreamError   = "upstream_error"
+	CodeUpstreamTimeout = "upstream_timeout"
+	CodeInternalError   = "internal_error"
+	CodeClientClosed    = "client_closed_request"
+)
+
+// StatusClientClosedRequest is the nginx status for a request the client gave up on before it was answered
+const StatusClientClosedRequest = 499
+
+// ErrorResponse is the body of every error returned by the API
+type ErrorResponse struct {
+	Code      string `json:"code" example:"upstream_error"`
+	Message   string `json:"message" example:"proxmox request failed with status 500"`
+	RequestID string `json:"request_id,omitempty"`
+	Source    string `json:"source,omitempty" example:"proxmox"` // 出错的上游: proxmox 或 pfsense
+}
+
+// JSON writes v as a JSON response with the given status
+func JSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Error writes err as an ErrorResponse, mapping upstream failures to 502/504, unknown objects to 404
+// and requests cancelled by a disconnected client to 499.
+// Upstream and internal details are logged but not sent to the client.
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	var upstreamErr *upstream.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		// 客户端已断开, 响应多半无人接收
+		slog.DebugContext(r.Context(), "request cancelled", "error", err)
+		write(w, r, StatusClientClosedRequest, CodeClientClosed, "request cancelled", "")
+	case errors.As(err, &upstreamErr) && errors.Is(upstreamErr, errdefs.ErrNotFound):
+		slog.InfoContext(r.Context(), "upstream object not found", "source", upstreamErr.Source, "error", err)
+		write(w, r, http.StatusNotFound, CodeNotFound, fmt.Sprintf("%s reported that the object does not exist", upstreamErr.Source), upstreamErr.Source)
+	case errors.Is(err, errdefs.ErrNotFound):
+		write(w, r, http.StatusNotFound, CodeNotFound, err.Error(), "")
+	case errors.As(err, &upstreamErr):
+		slog.WarnContext(r.Context(), "upstream error", "source", upstreamErr.Source, "error", err)
+		status, code := http.StatusBadGateway, CodeUpstreamError
+		if upstreamErr.Timeout() {
+			status, code = http.StatusGatewayTimeout, CodeUpstreamTimeout
+		}
+		write(w, r, status, code, upstreamErr.PublicMessage(), upstreamErr.Source)
+	case errors.Is(err, context.DeadlineExceeded):
+		write(w, r, http.StatusGatewayTimeout, CodeUpstreamTimeout, "request timed out", "")
+	default:
+		slog.ErrorContext(r.Context(), "internal error", "error", err)
+		write(w, r, http.StatusInternalServerError, CodeInternalError, "internal server error", "")
+	}
+}
+
+// BadRequest writes a 400 ErrorResponse
+func BadRequest(w http.ResponseWriter, r *http.Request, message string) {
+	write(w, r, http.StatusBadRequest, CodeBadRequest, message, "")
+}
+
+// Unauthorized writes a 401 ErrorResponse asking for a bearer token
+func Unauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="goad-dashboard"`)
+	write(w, r, http.StatusUnauthorized, CodeUnauthorized, message, "")
+}
+
+// Forbidden writes a 403 ErrorResponse
+func Forbidden(w http.ResponseWriter, r *http.Request, message string) {
+	write(w, r, http.StatusForbidden, CodeForbidden, message, "")
+}
+
+// NotFound writes a 404 ErrorResponse
+func NotFound(w http.ResponseWriter, r *http.Request, message string) {
+	write(w, r, http.StatusNotFound, CodeNotFound, message, "")
+}
+
+// Conflict writes a 409 ErrorResponse
+func Conflict(w http.ResponseWriter, r *http.Request, message string) {
+	write(w, r, http.StatusConflict, CodeConflict, message, "")
+}
+
+// RateLimited writes a 429 ErrorResponse. It is used as the limit handler of the rate limiter.
+func RateLimited(w http.ResponseWriter, r *http.Request) {
+	write(w, r, http.StatusTooManyRequests, CodeRateLimited, "too many requests, please slow down", "")
+}
+
+// NotFoundHandler writes a 404 ErrorResponse for unknown API routes
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	NotFound(w, r, "no such endpoint")
+}
+
+// MethodNotAllowedHandler writes a 405 ErrorResponse
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	write(w, r, http.StatusMethodNotAllowed, CodeBadRequest, "method not allowed", "")
+}
+
+func write(w http.ResponseWriter, r *http.Request, status int, code string, message string, source string) {
+	JSON(w, status, ErrorResponse{
+		Code:      code,
+		Message:   message,
+		RequestID: middleware.GetReqID(r.Context()),
+		Source:    source,
+	})
+}
@@ -0,0 +1,39 @@
+package response
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/errdefs"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform/upstream"
+)
+
+func TestError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"not found", fmt.Errorf("VM 101: %w", errdefs.ErrNotFound), http.StatusNotFound, CodeNotFound},
+		{"upstream", &upstream.Error{Source: upstream.SourceProxmox, StatusCode: 500}, http.StatusBadGateway, CodeUpstreamError},
+		{"upstream timeout", &upstream.Error{Source: upstream.SourcePfsense, Err: context.DeadlineExceeded}, http.StatusGatewayTimeout, CodeUpstreamTimeout},
+		{"client gone", fmt.Errorf("failed to get VMs: %w", context.Canceled), StatusClientClosedRequest, CodeClientClosed},
+		{"client gone upstream", &upstream.Error{Source: upstream.SourceProxmox, Err: context.Canceled}, StatusClientClosedRequest, CodeClientClosed},
+		{"internal", fmt.Errorf("boom"), http.StatusInternalServerError, CodeInternalError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		Error(w, httptest.NewRequest("GET", "/api/pve/vms", nil), tt.err)
+
+		var body ErrorResponse
+		json.NewDecoder(w.Body).Decode(&body)
+		if w.Code != tt.status || body.Code != tt.code {
+			t.Errorf("%s: got %d %s, want %d %s", tt.name, w.Code, body.Code, tt.status, tt.code)
+		}
+	}
+}
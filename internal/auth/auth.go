@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"strings"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/api/response"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+)
+
+// User is the identity attached to an authenticated request
+type User struct {
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	Anonymous bool   `json:"anonymous"` // 未配置 AUTH_USERS 时的匿名管理员
+}
+
+// IsAdmin reports whether the user may control the whole lab
+func (u *User) IsAdmin() bool {
+	return u.Role == config.RoleAdmin
+}
+
+// anonymous is attached to every request while authentication is disabled, keeping the API open as before
+var anonymous = User{Name: "anonymous", Role: config.RoleAdmin, Anonymous: true}
+
+type contextKey struct{}
+
+// Authenticator identifies users by the bearer tokens configured in AUTH_USERS,
+// or by the common name of a verified TLS client certificate
+type Authenticator struct {
+	users  map[[sha256.Size]byte]User // 以 token 的 SHA-256 为键
+	byName map[string]User
+}
+
+// NewAuthenticator creates an authenticator using the application config
+func NewAuthenticator(config *config.Config) *Authenticator {
+	users := map[[sha256.Size]byte]User{}
+	byName := map[string]User{}
+	for _, user := range config.GetAuthUsers() {
+		users[sha256.Sum256([]byte(user.Token))] = User{Name: user.Name, Role: user.Role}
+		byName[user.Name] = User{Name: user.Name, Role: user.Role}
+	}
+	return &Authenticator{users: users, byName: byName}
+}
+
+// Enabled reports whether any users are configured
+func (a *Authenticator) Enabled() bool {
+	return len(a.users) > 0
+}
+
+// HasUser reports whether name is a user configured in AUTH_USERS
+func (a *Authenticator) HasUser(name string) bool {
+	_, ok := a.byName[name]
+	return ok
+}
+
+// Middleware attaches the user identified by the request's bearer token to its context.
+// Requests without a token are identified by their client certificate if they have one, or continue without a user.
+// Requests with an unknown token are rejected. While authentication is disabled every request runs as the anonymous admin.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Enabled() {
+			next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), &anonymous)))
+			return
+		}
+
+		token, ok := bearerToken(r)
+		if !ok {
+			if user, ok := a.certificateUser(r); ok {
+				r = r.WithContext(WithUser(r.Context(), &user))
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Tokens are looked up by hash so that the comparison does not leak them through timing
+		user, ok := a.users[sha256.Sum256([]byte(token))]
+		if !ok {
+			response.Unauthorized(w, r, "invalid token")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), &user)))
+	})
+}
+
+// certificateUser returns the user named by the common name of the request's verified client certificate
+func (a *Authenticator) certificateUser(r *http.Request) (User, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return User{}, false
+	}
+	user, ok := a.byName[r.TLS.VerifiedChains[0][0].Subject.CommonName]
+	return user, ok
+}
+
+// RequireUser rejects requests that are not made by a configured user
+func RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := UserFromContext(r.Context())
+		switch {
+		case user == nil:
+			response.Unauthorized(w, r, "authentication required")
+		case user.Anonymous:
+			response.Forbidden(w, r, "this endpoint requires authentication to be configured with AUTH_USERS")
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// RequireAdmin rejects requests that are not made by an admin
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := UserFromContext(r.Context())
+		switch {
+		case user == nil:
+			response.Unauthorized(w, r, "authentication required")
+		case !user.IsAdmin():
+			response.Forbidden(w, r, "admin role required")
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// WithUser returns a copy of ctx carrying user
+func WithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// UserFromContext returns the user of a request, or nil if it is not authenticated
+func UserFromContext(ctx context.Context) *User {
+	user, _ := ctx.Value(contextKey{}).(*User)
+	return user
+}
+
+// bearerToken extracts the token of an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
@@ -2,71 +2,780 @@ package config
 
 import (
 	"fmt"
+	"net/mail"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds all configuration values for the application
 type Config struct {
 	port             string
+	demo             bool
 	proxmoxURL       string
 	proxmoxAuthToken string
 	pfsenseURL       string
 	pfsenseUsername  string
 	pfsensePassword  string
+	pfsenseAuthMode  string
+	pfsenseAPIKey    string
+	pfsenseModeRules map[string][]string
+
+	proxmoxCAFile      string
+	proxmoxFingerprint string
+	proxmoxInsecure    bool
+	pfsenseCAFile      string
+	pfsenseFingerprint string
+	pfsenseInsecure    bool
+
+	proxmoxTimeout       time.Duration
+	pfsenseTimeout       time.Duration
+	upstreamRetries      int
+	upstreamRetryBackoff time.Duration
+
+	guestAgentCacheTTL time.Duration
+
+	labHosts         []LabHost
+	labHealthTimeout time.Duration
+
+	labBootGroups      []BootGroup
+	labReadyTimeout    time.Duration
+	labShutdownTimeout time.Duration
+	labJobTimeout      time.Duration
+
+	storageWarningPercent int
+
+	authUsers []AuthUser
+
+	proxmoxPool string
+
+	instanceTemplates  []string
+	instancePoolPrefix string
+	instanceBridge     string
+	instanceVLANs      [2]int
+	instanceTTL        time.Duration
+	instanceMaxPerUser int
+
+	reservationsFile       string
+	reservationAutoReset   bool
+	reservationMaxDuration time.Duration
+	reservationMaxPerUser  int
+
+	webhooks           []Webhook
+	webhookSecret      string
+	webhookEvents      []string
+	webhookRetries     int
+	notifyPollInterval time.Duration
+
+	smtpHost            string
+	smtpPort            string
+	smtpSecurity        string
+	smtpUsername        string
+	smtpPassword        string
+	smtpFrom            string
+	smtpInsecure        bool
+	smtpRecipients      []Recipient
+	smtpSubjectTemplate string
+	smtpBodyTemplate    string
+	smtpRetries         int
+
+	vmDownAfter       time.Duration
+	maintenanceNotice time.Duration
+
+	shutdownTimeout    time.Duration
+	shutdownDrainDelay time.Duration
+
+	tlsCertFile       string
+	tlsKeyFile        string
+	tlsMinVersion     string
+	tlsReloadInterval time.Duration
+	tlsClientCAFile   string
+	tlsClientAuth     string
+	httpRedirectPort  string
+
+	logLevel  string
+	logFormat string
+}
+
+// Recipient is an email address notified of lab events
+type Recipient struct {
+	Address string
+	// Events are the event types the recipient subscribes to, all when empty
+	Events []string
+}
+
+// Webhook is an outbound notification endpoint
+type Webhook struct {
+	// Format is the payload format, one of the Webhook* constants
+	Format string
+	URL    string
+}
+
+// AuthUser is a user allowed to authenticate with a bearer token
+type AuthUser struct {
+	Name  string
+	Role  string
+	Token string
+}
+
+// BootGroup is a set of lab VMs started together. Groups start in order and shut down in reverse.
+type BootGroup struct {
+	Name string
+	// VMs are the Proxmox VM names in the group
+	VMs []string
+	// Delay is waited after the group has started
+	Delay time.Duration
+	// WaitReady waits for the guest agents and service probes of the group before starting the next one
+	WaitReady bool
+}
+
+// LabHost is a lab VM whose services are probed by the health checks
+type LabHost struct {
+	// Name is the VM name in Proxmox, e.g. kingslanding
+	Name string
+	// Address is the IP or hostname the probes connect to
+	Address string
+	// Domain is the AD domain served by the host. Only domain controllers have one.
+	Domain string
+}
+
+// User roles. Admins control the whole lab, students may only open consoles.
+const (
+	RoleAdmin   = "admin"
+	RoleStudent = "student"
+)
+
+// Supported webhook payload formats
+const (
+	WebhookJSON    = "json"
+	WebhookSlack   = "slack"
+	WebhookDiscord = "discord"
+	WebhookTeams   = "teams"
+)
+
+// Supported SMTP connection security modes
+const (
+	SMTPStartTLS = "starttls"
+	SMTPTLS      = "tls"
+	SMTPNone     = "none"
+)
+
+// Supported log levels
+const (
+	LogDebug = "debug"
+	LogInfo  = "info"
+	LogWarn  = "warn"
+	LogError = "error"
+)
+
+// Supported log formats
+const (
+	LogJSON = "json"
+	LogText = "text"
+)
+
+// Supported TLS client certificate modes
+const (
+	TLSClientOptional = "optional"
+	TLSClientRequire  = "require"
+)
+
+// Supported pfSense REST API authentication modes
+const (
+	PfsenseAuthBasic = "basic"
+	PfsenseAuthKey   = "key"
+	PfsenseAuthJWT   = "jwt"
+)
+
+// demoDefaults describe the simulated lab of the demo mode. They apply to the variables that are not set.
+var demoDefaults = map[string]string{
+	"LAB_HOSTS":          "DC01=192.168.56.10@sevenkingdoms.local,DC02=192.168.56.11@north.sevenkingdoms.local,DC03=192.168.56.12@essos.local,SRV02=192.168.56.22,SRV03=192.168.56.23",
+	"PROXMOX_POOL":       "goad",
+	"INSTANCE_TEMPLATES": "9001,9002,9003,9004,9005",
+	"INSTANCE_BRIDGE":    "vmbr2",
+	"INSTANCE_VLANS":     "100-109",
 }
 
 // LoadConfig loads configuration from environment variables
 func LoadConfig() (*Config, error) {
+	var err error
 	config := &Config{}
 
+	config.logLevel = strings.ToLower(os.Getenv("LOG_LEVEL"))
+	switch config.logLevel {
+	case "":
+		config.logLevel = LogInfo
+	case LogDebug, LogInfo, LogWarn, LogError:
+	default:
+		return nil, fmt.Errorf("LOG_LEVEL must be %q, %q, %q or %q", LogDebug, LogInfo, LogWarn, LogError)
+	}
+
+	config.logFormat = strings.ToLower(os.Getenv("LOG_FORMAT"))
+	switch config.logFormat {
+	case "":
+		config.logFormat = LogJSON
+	case LogJSON, LogText:
+	default:
+		return nil, fmt.Errorf("LOG_FORMAT must be %q or %q", LogJSON, LogText)
+	}
+
 	config.port = os.Getenv("PORT")
 	if config.port == "" {
 		config.port = "8080"
 	}
 
+	config.demo, err = getBool("DEMO", false)
+	if err != nil {
+		return nil, err
+	}
+	// The simulated lab of the demo mode replaces Proxmox and pfSense
+	if !config.demo {
+		if err := loadUpstreams(config); err != nil {
+			return nil, err
+		}
+	}
+
+	// Firewall rules toggled by the network modes, selected by tracker ID or description
+	config.pfsenseModeRules = map[string][]string{
+		"exam":     splitList(os.Getenv("PFSENSE_EXAM_RULES")),
+		"isolated": splitList(os.Getenv("PFSENSE_ISOLATED_RULES")),
+	}
+
+	config.proxmoxCAFile = os.Getenv("PROXMOX_CA_FILE")
+	config.proxmoxFingerprint = os.Getenv("PROXMOX_FINGERPRINT")
+	config.proxmoxInsecure, err = getBool("PROXMOX_INSECURE_SKIP_VERIFY", false)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTLSVerification("PROXMOX", config.proxmoxCAFile, config.proxmoxFingerprint, config.proxmoxInsecure); err != nil {
+		return nil, err
+	}
+
+	config.pfsenseCAFile = os.Getenv("PFSENSE_CA_FILE")
+	config.pfsenseFingerprint = os.Getenv("PFSENSE_FINGERPRINT")
+	config.pfsenseInsecure, err = getBool("PFSENSE_INSECURE_SKIP_VERIFY", false)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTLSVerification("PFSENSE", config.pfsenseCAFile, config.pfsenseFingerprint, config.pfsenseInsecure); err != nil {
+		return nil, err
+	}
+
+	config.proxmoxTimeout, err = getDuration("PROXMOX_TIMEOUT", 15*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	config.pfsenseTimeout, err = getDuration("PFSENSE_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	config.upstreamRetries, err = getInt("UPSTREAM_RETRIES", 2)
+	if err != nil {
+		return nil, err
+	}
+
+	config.upstreamRetryBackoff, err = getDuration("UPSTREAM_RETRY_BACKOFF", 500*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+
+	config.guestAgentCacheTTL, err = getDuration("GUEST_AGENT_CACHE_TTL", 60*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	config.labHosts, err = parseLabHosts(config.getenv("LAB_HOSTS"))
+	if err != nil {
+		return nil, err
+	}
+
+	config.labHealthTimeout, err = getDuration("LAB_HEALTH_TIMEOUT", 3*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	config.labBootGroups, err = parseBootGroups(os.Getenv("LAB_BOOT_GROUPS"))
+	if err != nil {
+		return nil, err
+	}
+
+	config.labReadyTimeout, err = getDuration("LAB_READY_TIMEOUT", 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	config.labShutdownTimeout, err = getDuration("LAB_SHUTDOWN_TIMEOUT", 3*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	config.labJobTimeout, err = getDuration("LAB_JOB_TIMEOUT", 30*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	if config.labJobTimeout <= config.labReadyTimeout || config.labJobTimeout <= config.labShutdownTimeout {
+		return nil, fmt.Errorf("LAB_JOB_TIMEOUT environment variable must be longer than LAB_READY_TIMEOUT and LAB_SHUTDOWN_TIMEOUT")
+	}
+
+	config.storageWarningPercent, err = getInt("STORAGE_WARNING_PERCENT", 85)
+	if err != nil {
+		return nil, err
+	}
+	if config.storageWarningPercent > 100 {
+		return nil, fmt.Errorf("STORAGE_WARNING_PERCENT environment variable must be between 0 and 100")
+	}
+
+	config.authUsers, err = parseAuthUsers(os.Getenv("AUTH_USERS"))
+	if err != nil {
+		return nil, err
+	}
+
+	config.proxmoxPool = config.getenv("PROXMOX_POOL")
+
+	config.instanceTemplates = splitList(config.getenv("INSTANCE_TEMPLATES"))
+	config.instancePoolPrefix = os.Getenv("INSTANCE_POOL_PREFIX")
+	if config.instancePoolPrefix == "" {
+		config.instancePoolPrefix = "goad-"
+	}
+	config.instanceBridge = config.getenv("INSTANCE_BRIDGE")
+	config.instanceVLANs, err = parseRange("INSTANCE_VLANS", config.getenv("INSTANCE_VLANS"))
+	if err != nil {
+		return nil, err
+	}
+	// Without a lab pool the shared lab would include the instance VMs
+	if len(config.instanceTemplates) > 0 && (config.instanceBridge == "" || config.instanceVLANs[0] == 0 || config.proxmoxPool == "") {
+		return nil, fmt.Errorf("PROXMOX_POOL, INSTANCE_BRIDGE and INSTANCE_VLANS environment variables are required when INSTANCE_TEMPLATES is set")
+	}
+
+	config.instanceTTL, err = getDuration("INSTANCE_TTL", 8*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	config.instanceMaxPerUser, err = getInt("INSTANCE_MAX_PER_USER", 1)
+	if err != nil {
+		return nil, err
+	}
+
+	config.reservationsFile = os.Getenv("RESERVATIONS_FILE")
+
+	config.reservationAutoReset, err = getBool("RESERVATION_AUTO_RESET", false)
+	if err != nil {
+		return nil, err
+	}
+
+	config.reservationMaxDuration, err = getDuration("RESERVATION_MAX_DURATION", 8*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	config.reservationMaxPerUser, err = getInt("RESERVATION_MAX_PER_USER", 3)
+	if err != nil {
+		return nil, err
+	}
+
+	config.webhooks, err = parseWebhooks(os.Getenv("WEBHOOKS"))
+	if err != nil {
+		return nil, err
+	}
+	config.webhookSecret = os.Getenv("WEBHOOK_SECRET")
+	config.webhookEvents = splitList(os.Getenv("WEBHOOK_EVENTS"))
+
+	config.webhookRetries, err = getInt("WEBHOOK_RETRIES", 3)
+	if err != nil {
+		return nil, err
+	}
+
+	config.notifyPollInterval, err = getDuration("NOTIFY_POLL_INTERVAL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if config.notifyPollInterval == 0 {
+		return nil, fmt.Errorf("NOTIFY_POLL_INTERVAL environment variable must be greater than zero")
+	}
+
+	config.smtpHost = os.Getenv("SMTP_HOST")
+	config.smtpUsername = os.Getenv("SMTP_USERNAME")
+	config.smtpPassword = os.Getenv("SMTP_PASSWORD")
+	config.smtpFrom = os.Getenv("SMTP_FROM")
+	config.smtpSubjectTemplate = os.Getenv("SMTP_SUBJECT_TEMPLATE")
+
+	config.smtpSecurity = os.Getenv("SMTP_SECURITY")
+	switch config.smtpSecurity {
+	case "":
+		config.smtpSecurity = SMTPStartTLS
+	case SMTPStartTLS, SMTPTLS, SMTPNone:
+	default:
+		return nil, fmt.Errorf("SMTP_SECURITY must be %q, %q or %q", SMTPStartTLS, SMTPTLS, SMTPNone)
+	}
+
+	config.smtpRetries, err = getInt("SMTP_RETRIES", 3)
+	if err != nil {
+		return nil, err
+	}
+
+	config.smtpPort = os.Getenv("SMTP_PORT")
+	if config.smtpPort == "" {
+		config.smtpPort = "587"
+		if config.smtpSecurity == SMTPTLS {
+			config.smtpPort = "465"
+		}
+	}
+
+	config.smtpInsecure, err = getBool("SMTP_INSECURE_SKIP_VERIFY", false)
+	if err != nil {
+		return nil, err
+	}
+
+	config.smtpRecipients, err = parseRecipients(os.Getenv("SMTP_RECIPIENTS"))
+	if err != nil {
+		return nil, err
+	}
+
+	if file := os.Getenv("SMTP_BODY_TEMPLATE_FILE"); file != "" {
+		body, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SMTP_BODY_TEMPLATE_FILE: %w", err)
+		}
+		config.smtpBodyTemplate = string(body)
+	}
+
+	if config.smtpHost != "" {
+		if config.smtpFrom == "" {
+			return nil, fmt.Errorf("SMTP_FROM environment variable is required when SMTP_HOST is set")
+		}
+		if len(config.smtpRecipients) == 0 {
+			return nil, fmt.Errorf("SMTP_RECIPIENTS environment variable is required when SMTP_HOST is set")
+		}
+	}
+
+	config.vmDownAfter, err = getDuration("VM_DOWN_AFTER", 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	config.maintenanceNotice, err = getDuration("MAINTENANCE_NOTICE", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	config.shutdownTimeout, err = getDuration("SHUTDOWN_TIMEOUT", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	config.shutdownDrainDelay, err = getDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	config.tlsCertFile = os.Getenv("TLS_CERT_FILE")
+	config.tlsKeyFile = os.Getenv("TLS_KEY_FILE")
+	if (config.tlsCertFile == "") != (config.tlsKeyFile == "") {
+		return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE environment variables must be set together")
+	}
+
+	config.tlsMinVersion = os.Getenv("TLS_MIN_VERSION")
+	switch config.tlsMinVersion {
+	case "":
+		config.tlsMinVersion = "1.2"
+	case "1.2", "1.3":
+	default:
+		return nil, fmt.Errorf("TLS_MIN_VERSION must be \"1.2\" or \"1.3\"")
+	}
+
+	config.tlsReloadInterval, err = getDuration("TLS_RELOAD_INTERVAL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	config.tlsClientCAFile = os.Getenv("TLS_CLIENT_CA_FILE")
+	config.tlsClientAuth = os.Getenv("TLS_CLIENT_AUTH")
+	switch config.tlsClientAuth {
+	case "":
+		config.tlsClientAuth = TLSClientOptional
+	case TLSClientOptional, TLSClientRequire:
+	default:
+		return nil, fmt.Errorf("TLS_CLIENT_AUTH must be %q or %q", TLSClientOptional, TLSClientRequire)
+	}
+
+	config.httpRedirectPort = os.Getenv("HTTP_REDIRECT_PORT")
+
+	if config.tlsCertFile == "" && (config.tlsClientCAFile != "" || config.httpRedirectPort != "") {
+		return nil, fmt.Errorf("TLS_CLIENT_CA_FILE and HTTP_REDIRECT_PORT require TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+
+	return config, nil
+}
+
+// parseRecipients parses "address[:event,event]" recipients separated by semicolons
+func parseRecipients(value string) ([]Recipient, error) {
+	var recipients []Recipient
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		address, events, _ := strings.Cut(entry, ":")
+		address = strings.TrimSpace(address)
+		if _, err := mail.ParseAddress(address); err != nil || strings.ContainsAny(address, "<> ") {
+			return nil, fmt.Errorf("SMTP_RECIPIENTS entry %q must look like user@example.org or user@example.org:reset.*,vm.down", entry)
+		}
+		recipients = append(recipients, Recipient{Address: address, Events: splitList(events)})
+	}
+	return recipients, nil
+}
+
+// parseLabHosts parses "name=address[@domain]" entries separated by commas
+func parseLabHosts(value string) ([]LabHost, error) {
+	var hosts []LabHost
+	for _, entry := range splitList(value) {
+		name, target, ok := strings.Cut(entry, "=")
+		if !ok || name == "" || target == "" {
+			return nil, fmt.Errorf("LAB_HOSTS entry %q must look like name=address or name=address@domain", entry)
+		}
+		address, domain, _ := strings.Cut(target, "@")
+		hosts = append(hosts, LabHost{Name: name, Address: address, Domain: domain})
+	}
+	return hosts, nil
+}
+
+// parseWebhooks parses "format:url" entries separated by commas
+func parseWebhooks(value string) ([]Webhook, error) {
+	var webhooks []Webhook
+	for _, entry := range splitList(value) {
+		format, target, ok := strings.Cut(entry, ":")
+		switch {
+		case !ok || !strings.HasPrefix(target, "http"):
+			return nil, fmt.Errorf("WEBHOOKS entry must look like format:url, e.g. slack:https://hooks.slack.com/services/...")
+		case format != WebhookJSON && format != WebhookSlack && format != WebhookDiscord && format != WebhookTeams:
+			return nil, fmt.Errorf("WEBHOOKS format %q must be one of json, slack, discord or teams", format)
+		}
+		webhooks = append(webhooks, Webhook{Format: format, URL: target})
+	}
+	return webhooks, nil
+}
+
+// parseRange parses an inclusive range of VLAN IDs such as "100-199"
+func parseRange(name string, value string) ([2]int, error) {
+	if value == "" {
+		return [2]int{}, nil
+	}
+	low, high, ok := strings.Cut(value, "-")
+	first, err1 := strconv.Atoi(strings.TrimSpace(low))
+	last, err2 := strconv.Atoi(strings.TrimSpace(high))
+	if !ok || err1 != nil || err2 != nil || first < 1 || last > 4094 || first > last {
+		return [2]int{}, fmt.Errorf("%s environment variable must be a VLAN range such as 100-199", name)
+	}
+	return [2]int{first, last}, nil
+}
+
+// parseAuthUsers parses "name:role:token" entries separated by commas
+func parseAuthUsers(value string) ([]AuthUser, error) {
+	var users []AuthUser
+	names := map[string]bool{}
+	for _, entry := range splitList(value) {
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("AUTH_USERS entry must look like name:role:token")
+		}
+		if parts[1] != RoleAdmin && parts[1] != RoleStudent {
+			return nil, fmt.Errorf("AUTH_USERS user %q must have the role %q or %q", parts[0], RoleAdmin, RoleStudent)
+		}
+		if names[parts[0]] {
+			return nil, fmt.Errorf("AUTH_USERS lists user %q twice", parts[0])
+		}
+		names[parts[0]] = true
+		users = append(users, AuthUser{Name: parts[0], Role: parts[1], Token: parts[2]})
+	}
+	return users, nil
+}
+
+// parseBootGroups parses "name:vm1,vm2[@wait]" groups separated by semicolons,
+// where wait is either "ready" or a delay such as "30s"
+func parseBootGroups(value string) ([]BootGroup, error) {
+	var groups []BootGroup
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, members, ok := strings.Cut(entry, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("LAB_BOOT_GROUPS entry %q must look like name:vm1,vm2[@ready|@delay]", entry)
+		}
+
+		group := BootGroup{Name: strings.TrimSpace(name)}
+		members, wait, _ := strings.Cut(members, "@")
+		group.VMs = splitList(members)
+
+		switch wait = strings.TrimSpace(wait); wait {
+		case "":
+		case "ready":
+			group.WaitReady = true
+		default:
+			delay, err := time.ParseDuration(wait)
+			if err != nil || delay < 0 {
+				return nil, fmt.Errorf("LAB_BOOT_GROUPS group %q must wait for \"ready\" or a duration such as \"30s\"", group.Name)
+			}
+			group.Delay = delay
+		}
+
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// loadUpstreams loads the Proxmox and pfSense URLs and credentials
+func loadUpstreams(config *Config) error {
 	config.proxmoxURL = os.Getenv("PROXMOX_URL")
 	if config.proxmoxURL == "" {
-		return nil, fmt.Errorf("PROXMOX_URL environment variable is required")
+		return fmt.Errorf("PROXMOX_URL environment variable is required")
 	}
 
 	pveUsername := os.Getenv("PROXMOX_USERNAME")
 	if pveUsername == "" {
-		return nil, fmt.Errorf("PROXMOX_USERNAME environment variable is required")
+		return fmt.Errorf("PROXMOX_USERNAME environment variable is required")
 	}
 
 	pveRealm := os.Getenv("PROXMOX_REALM")
 	if pveRealm == "" {
-		return nil, fmt.Errorf("PROXMOX_REALM environment variable is required")
+		return fmt.Errorf("PROXMOX_REALM environment variable is required")
 	}
 
 	pveAPITokenName := os.Getenv("PROXMOX_API_TOKEN_NAME")
 	if pveAPITokenName == "" {
-		return nil, fmt.Errorf("PROXMOX_API_TOKEN_NAME environment variable is required")
+		return fmt.Errorf("PROXMOX_API_TOKEN_NAME environment variable is required")
 	}
 
 	pveAPIToken := os.Getenv("PROXMOX_API_TOKEN")
 	if pveAPIToken == "" {
-		return nil, fmt.Errorf("PROXMOX_API_TOKEN environment variable is required")
+		return fmt.Errorf("PROXMOX_API_TOKEN environment variable is required")
 	}
 
 	config.proxmoxAuthToken = fmt.Sprintf("%s@%s!%s=%s", pveUsername, pveRealm, pveAPITokenName, pveAPIToken)
 
 	config.pfsenseURL = os.Getenv("PFSENSE_URL")
 	if config.pfsenseURL == "" {
-		return nil, fmt.Errorf("PFSENSE_URL environment variable is required")
+		return fmt.Errorf("PFSENSE_URL environment variable is required")
 	}
 
-	config.pfsenseUsername = os.Getenv("PFSENSE_USERNAME")
-	if config.pfsenseUsername == "" {
-		return nil, fmt.Errorf("PFSENSE_USERNAME environment variable is required")
+	config.pfsenseAPIKey = os.Getenv("PFSENSE_API_KEY")
+
+	config.pfsenseAuthMode = os.Getenv("PFSENSE_AUTH_MODE")
+	if config.pfsenseAuthMode == "" {
+		// Prefer the API key when one is provided, otherwise fall back to basic auth
+		if config.pfsenseAPIKey != "" {
+			config.pfsenseAuthMode = PfsenseAuthKey
+		} else {
+			config.pfsenseAuthMode = PfsenseAuthBasic
+		}
 	}
 
-	config.pfsensePassword = os.Getenv("PFSENSE_PASSWORD")
-	if config.pfsensePassword == "" {
-		return nil, fmt.Errorf("PFSENSE_PASSWORD environment variable is required")
+	switch config.pfsenseAuthMode {
+	case PfsenseAuthKey:
+		if config.pfsenseAPIKey == "" {
+			return fmt.Errorf("PFSENSE_API_KEY environment variable is required when PFSENSE_AUTH_MODE is %q", PfsenseAuthKey)
+		}
+	case PfsenseAuthBasic, PfsenseAuthJWT:
+		config.pfsenseUsername = os.Getenv("PFSENSE_USERNAME")
+		if config.pfsenseUsername == "" {
+			return fmt.Errorf("PFSENSE_USERNAME environment variable is required")
+		}
+
+		config.pfsensePassword = os.Getenv("PFSENSE_PASSWORD")
+		if config.pfsensePassword == "" {
+			return fmt.Errorf("PFSENSE_PASSWORD environment variable is required")
+		}
+	default:
+		return fmt.Errorf("PFSENSE_AUTH_MODE must be one of %q, %q or %q", PfsenseAuthBasic, PfsenseAuthKey, PfsenseAuthJWT)
 	}
 
-	return config, nil
+	return nil
+}
+
+// checkTLSVerification rejects more than one way of verifying an upstream certificate,
+// so that a leftover variable cannot quietly weaken or disable verification
+func checkTLSVerification(prefix string, caFile string, fingerprint string, insecure bool) error {
+	var set []string
+	if caFile != "" {
+		set = append(set, prefix+"_CA_FILE")
+	}
+	if fingerprint != "" {
+		set = append(set, prefix+"_FINGERPRINT")
+	}
+	if insecure {
+		set = append(set, prefix+"_INSECURE_SKIP_VERIFY")
+	}
+	if len(set) > 1 {
+		return fmt.Errorf("%s cannot be combined, set only one of them", strings.Join(set, " and "))
+	}
+	return nil
+}
+
+// getenv returns an environment variable or, in demo mode, its demo default when it is not set
+func (c *Config) getenv(name string) string {
+	value := os.Getenv(name)
+	if value == "" && c.demo {
+		value = demoDefaults[name]
+	}
+	return value
+}
+
+// getDuration parses a duration such as "10s" from an environment variable
+func getDuration(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("%s environment variable must be a non-negative duration such as \"10s\"", name)
+	}
+	return duration, nil
+}
+
+// getInt parses a non-negative integer from an environment variable
+func getInt(name string, fallback int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("%s environment variable must be a non-negative integer", name)
+	}
+	return number, nil
+}
+
+// getBool parses a boolean such as "1" or "true" from an environment variable
+func getBool(name string, fallback bool) (bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s environment variable must be a boolean such as \"1\" or \"0\"", name)
+	}
+	return b, nil
+}
+
+// splitList splits a comma separated value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 // GetPort returns the port
@@ -74,6 +783,11 @@ func (c *Config) GetPort() string {
 	return c.port
 }
 
+// GetDemo reports whether the server runs against a simulated lab instead of Proxmox and pfSense
+func (c *Config) GetDemo() bool {
+	return c.demo
+}
+
 // GetProxmoxURL returns the Proxmox URL
 func (c *Config) GetProxmoxURL() string {
 	return c.proxmoxURL
@@ -98,3 +812,313 @@ func (c *Config) GetPfsenseUsername() string {
 func (c *Config) GetPfsensePassword() string {
 	return c.pfsensePassword
 }
+
+// GetPfsenseAuthMode returns the Pfsense authentication mode
+func (c *Config) GetPfsenseAuthMode() string {
+	return c.pfsenseAuthMode
+}
+
+// GetPfsenseAPIKey returns the Pfsense API key
+func (c *Config) GetPfsenseAPIKey() string {
+	return c.pfsenseAPIKey
+}
+
+// GetPfsenseModeRules returns the firewall rule selectors enabled by each network mode
+func (c *Config) GetPfsenseModeRules() map[string][]string {
+	return c.pfsenseModeRules
+}
+
+// GetProxmoxCAFile returns the CA bundle used to verify the Proxmox certificate
+func (c *Config) GetProxmoxCAFile() string {
+	return c.proxmoxCAFile
+}
+
+// GetProxmoxFingerprint returns the pinned SHA-256 fingerprint of the Proxmox certificate
+func (c *Config) GetProxmoxFingerprint() string {
+	return c.proxmoxFingerprint
+}
+
+// GetProxmoxInsecure returns whether verification of the Proxmox certificate is disabled
+func (c *Config) GetProxmoxInsecure() bool {
+	return c.proxmoxInsecure
+}
+
+// GetPfsenseCAFile returns the CA bundle used to verify the Pfsense certificate
+func (c *Config) GetPfsenseCAFile() string {
+	return c.pfsenseCAFile
+}
+
+// GetPfsenseFingerprint returns the pinned SHA-256 fingerprint of the Pfsense certificate
+func (c *Config) GetPfsenseFingerprint() string {
+	return c.pfsenseFingerprint
+}
+
+// GetPfsenseInsecure returns whether verification of the Pfsense certificate is disabled
+func (c *Config) GetPfsenseInsecure() bool {
+	return c.pfsenseInsecure
+}
+
+// GetProxmoxTimeout returns the timeout of a single Proxmox request
+func (c *Config) GetProxmoxTimeout() time.Duration {
+	return c.proxmoxTimeout
+}
+
+// GetPfsenseTimeout returns the timeout of a single Pfsense request
+func (c *Config) GetPfsenseTimeout() time.Duration {
+	return c.pfsenseTimeout
+}
+
+// GetUpstreamRetries returns how many times idempotent upstream requests are retried
+func (c *Config) GetUpstreamRetries() int {
+	return c.upstreamRetries
+}
+
+// GetUpstreamRetryBackoff returns the delay before the first retry of an upstream request
+func (c *Config) GetUpstreamRetryBackoff() time.Duration {
+	return c.upstreamRetryBackoff
+}
+
+// GetGuestAgentCacheTTL returns how long guest agent results are cached
+func (c *Config) GetGuestAgentCacheTTL() time.Duration {
+	return c.guestAgentCacheTTL
+}
+
+// GetLabHosts returns the lab hosts probed by the health checks
+func (c *Config) GetLabHosts() []LabHost {
+	return c.labHosts
+}
+
+// GetLabHealthTimeout returns the timeout of a single health probe
+func (c *Config) GetLabHealthTimeout() time.Duration {
+	return c.labHealthTimeout
+}
+
+// GetLabBootGroups returns the groups in which lab VMs are started and shut down
+func (c *Config) GetLabBootGroups() []BootGroup {
+	return c.labBootGroups
+}
+
+// GetLabReadyTimeout returns how long to wait for guest agents and services to come up
+func (c *Config) GetLabReadyTimeout() time.Duration {
+	return c.labReadyTimeout
+}
+
+// GetLabShutdownTimeout returns how long an ACPI shutdown may take before the VM is stopped
+func (c *Config) GetLabShutdownTimeout() time.Duration {
+	return c.labShutdownTimeout
+}
+
+// GetLabJobTimeout returns how long a lab job may run before it is cancelled and marked failed
+func (c *Config) GetLabJobTimeout() time.Duration {
+	return c.labJobTimeout
+}
+
+// GetStorageWarningPercent returns the usage of a VM disk storage above which a warning is raised
+func (c *Config) GetStorageWarningPercent() int {
+	return c.storageWarningPercent
+}
+
+// GetAuthUsers returns the users allowed to authenticate. Authentication is disabled when there are none.
+func (c *Config) GetAuthUsers() []AuthUser {
+	return c.authUsers
+}
+
+// GetProxmoxPool returns the pool holding the shared lab VMs, empty if every VM belongs to it
+func (c *Config) GetProxmoxPool() string {
+	return c.proxmoxPool
+}
+
+// GetInstanceTemplates returns the IDs of the template VMs cloned into each instance. Instances are disabled when there are none.
+func (c *Config) GetInstanceTemplates() []string {
+	return c.instanceTemplates
+}
+
+// GetInstancePoolPrefix returns the prefix of the pools created for instances
+func (c *Config) GetInstancePoolPrefix() string {
+	return c.instancePoolPrefix
+}
+
+// GetInstanceBridge returns the bridge the NICs of instance VMs are attached to
+func (c *Config) GetInstanceBridge() string {
+	return c.instanceBridge
+}
+
+// GetInstanceVLANs returns the first and last VLAN tag handed out to instances
+func (c *Config) GetInstanceVLANs() (int, int) {
+	return c.instanceVLANs[0], c.instanceVLANs[1]
+}
+
+// GetInstanceTTL returns how long an instance lives before it is torn down
+func (c *Config) GetInstanceTTL() time.Duration {
+	return c.instanceTTL
+}
+
+// GetInstanceMaxPerUser returns how many instances a student may hold at once
+func (c *Config) GetInstanceMaxPerUser() int {
+	return c.instanceMaxPerUser
+}
+
+// GetReservationsFile returns the file reservations are persisted to, empty to keep them in memory
+func (c *Config) GetReservationsFile() string {
+	return c.reservationsFile
+}
+
+// GetReservationAutoReset returns whether new reservations reset the lab when they start, unless the booking says otherwise
+func (c *Config) GetReservationAutoReset() bool {
+	return c.reservationAutoReset
+}
+
+// GetReservationMaxDuration returns the longest time slot that can be booked
+func (c *Config) GetReservationMaxDuration() time.Duration {
+	return c.reservationMaxDuration
+}
+
+// GetReservationMaxPerUser returns how many reservations that have not ended a student may hold at once
+func (c *Config) GetReservationMaxPerUser() int {
+	return c.reservationMaxPerUser
+}
+
+// GetWebhooks returns the webhooks notified of lab events
+func (c *Config) GetWebhooks() []Webhook {
+	return c.webhooks
+}
+
+// GetWebhookSecret returns the key webhook payloads are signed with, empty to not sign them
+func (c *Config) GetWebhookSecret() string {
+	return c.webhookSecret
+}
+
+// GetWebhookEvents returns the event types sent to webhooks, all when empty
+func (c *Config) GetWebhookEvents() []string {
+	return c.webhookEvents
+}
+
+// GetWebhookRetries returns how many times a failed webhook delivery is retried
+func (c *Config) GetWebhookRetries() int {
+	return c.webhookRetries
+}
+
+// GetSMTPRetries returns how many times a failed email delivery is retried
+func (c *Config) GetSMTPRetries() int {
+	return c.smtpRetries
+}
+
+// GetSMTPHost returns the SMTP server email notifications are sent through, empty when email is disabled
+func (c *Config) GetSMTPHost() string {
+	return c.smtpHost
+}
+
+// GetSMTPPort returns the SMTP server port
+func (c *Config) GetSMTPPort() string {
+	return c.smtpPort
+}
+
+// GetSMTPSecurity returns how the SMTP connection is secured, one of the SMTP* constants
+func (c *Config) GetSMTPSecurity() string {
+	return c.smtpSecurity
+}
+
+// GetSMTPUsername returns the SMTP username, empty to send without authentication
+func (c *Config) GetSMTPUsername() string {
+	return c.smtpUsername
+}
+
+// GetSMTPPassword returns the SMTP password
+func (c *Config) GetSMTPPassword() string {
+	return c.smtpPassword
+}
+
+// GetSMTPFrom returns the sender address of email notifications
+func (c *Config) GetSMTPFrom() string {
+	return c.smtpFrom
+}
+
+// GetSMTPInsecure returns whether verification of the SMTP server certificate is disabled
+func (c *Config) GetSMTPInsecure() bool {
+	return c.smtpInsecure
+}
+
+// GetSMTPRecipients returns the recipients of email notifications
+func (c *Config) GetSMTPRecipients() []Recipient {
+	return c.smtpRecipients
+}
+
+// GetSMTPSubjectTemplate returns the template of the email subject, empty for the default
+func (c *Config) GetSMTPSubjectTemplate() string {
+	return c.smtpSubjectTemplate
+}
+
+// GetSMTPBodyTemplate returns the template of the email body, empty for the default
+func (c *Config) GetSMTPBodyTemplate() string {
+	return c.smtpBodyTemplate
+}
+
+// GetVMDownAfter returns how long a VM must stay down before it is reported, zero to not report it
+func (c *Config) GetVMDownAfter() time.Duration {
+	return c.vmDownAfter
+}
+
+// GetMaintenanceNotice returns how long before an automatic lab reset it is announced
+func (c *Config) GetMaintenanceNotice() time.Duration {
+	return c.maintenanceNotice
+}
+
+// GetShutdownTimeout returns how long in-flight requests and lab jobs are waited for on shutdown
+func (c *Config) GetShutdownTimeout() time.Duration {
+	return c.shutdownTimeout
+}
+
+// GetShutdownDrainDelay returns how long /readyz fails before the server stops accepting connections on shutdown
+func (c *Config) GetShutdownDrainDelay() time.Duration {
+	return c.shutdownDrainDelay
+}
+
+// GetTLSCertFile returns the PEM certificate served on PORT, empty to serve plain HTTP
+func (c *Config) GetTLSCertFile() string {
+	return c.tlsCertFile
+}
+
+// GetTLSKeyFile returns the PEM private key of the served certificate
+func (c *Config) GetTLSKeyFile() string {
+	return c.tlsKeyFile
+}
+
+// GetTLSMinVersion returns the minimum TLS version accepted, "1.2" or "1.3"
+func (c *Config) GetTLSMinVersion() string {
+	return c.tlsMinVersion
+}
+
+// GetTLSReloadInterval returns how often the certificate files are checked for changes, zero to never reload them
+func (c *Config) GetTLSReloadInterval() time.Duration {
+	return c.tlsReloadInterval
+}
+
+// GetTLSClientCAFile returns the PEM bundle client certificates are verified against, empty to not ask for them
+func (c *Config) GetTLSClientCAFile() string {
+	return c.tlsClientCAFile
+}
+
+// GetTLSClientAuth returns whether client certificates are optional or required, one of the TLSClient* constants
+func (c *Config) GetTLSClientAuth() string {
+	return c.tlsClientAuth
+}
+
+// GetHTTPRedirectPort returns the port of the plain HTTP listener redirecting to HTTPS, empty to not listen
+func (c *Config) GetHTTPRedirectPort() string {
+	return c.httpRedirectPort
+}
+
+// GetLogLevel returns the minimum level of logged messages, one of the Log* level constants
+func (c *Config) GetLogLevel() string {
+	return c.logLevel
+}
+
+// GetLogFormat returns whether logs are written as JSON or text, one of the Log* format constants
+func (c *Config) GetLogFormat() string {
+	return c.logFormat
+}
+
+// GetNotifyPollInterval returns how often VMs, VPN connections and pfSense are polled for changes
+func (c *Config) GetNotifyPollInterval() time.Duration {
+	return c.notifyPollInterval
+}
@@ -0,0 +1,26 @@
+package config
+
+import "testing"
+
+func TestCheckTLSVerification(t *testing.T) {
+	tests := []struct {
+		caFile      string
+		fingerprint string
+		insecure    bool
+		wantErr     bool
+	}{
+		{"", "", false, false},
+		{"ca.pem", "", false, false},
+		{"", "AB:CD", false, false},
+		{"", "", true, false},
+		{"ca.pem", "AB:CD", false, true},
+		{"", "AB:CD", true, true},
+		{"ca.pem", "", true, true},
+	}
+	for _, tt := range tests {
+		err := checkTLSVerification("PROXMOX", tt.caFile, tt.fingerprint, tt.insecure)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("checkTLSVerification(%q, %q, %v) = %v, want error %v", tt.caFile, tt.fingerprint, tt.insecure, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package console
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+	"golang.org/x/net/websocket"
+)
+
+// sessionTTL is how long a session may wait for its websocket. Proxmox closes an unused VNC proxy after about 10 seconds.
+const sessionTTL = 10 * time.Second
+
+// dialTimeout bounds the connection to the Proxmox VNC websocket
+const dialTimeout = 10 * time.Second
+
+// ErrNoSession is returned when a websocket presents an unknown, used or expired session
+var ErrNoSession = errors.New("no such console session, it may have expired")
+
+// Session is a console opened for a user. It can be connected to once, within a few seconds.
+type Session struct {
+	ID        string    `json:"id"`
+	VMID      string    `json:"vmid"`
+	User      string    `json:"user"`
+	Password  string    `json:"password"` // noVNC 连接时使用的 VNC 密码
+	URL       string    `json:"url"`      // websocket 路径
+	ExpiresAt time.Time `json:"expires_at"`
+
+	node   string
+	ticket *platform.VNCTicket
+}
+
+// Manager opens VNC proxies on Proxmox and relays their websockets, so that clients never talk to Proxmox directly
+type Manager struct {
+	consoles platform.Consoles
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewManager creates a console manager opening the consoles of consoles
+func NewManager(consoles platform.Consoles) *Manager {
+	return &Manager{
+		consoles: consoles,
+		sessions: map[string]*Session{},
+	}
+}
+
+// Open opens a VNC proxy for a VM on behalf of user
+func (m *Manager) Open(ctx context.Context, vm *platform.VMInfo, user string) (*Session, error) {
+	ticket, err := m.consoles.OpenVNCProxy(ctx, vm.Node, vm.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		ID:        id,
+		VMID:      vm.ID,
+		User:      user,
+		Password:  ticket.Ticket,
+		URL:       "/api/pve/vms/" + url.PathEscape(vm.ID) + "/console?session=" + id,
+		ExpiresAt: time.Now().Add(sessionTTL),
+		node:      vm.Node,
+		ticket:    ticket,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, s := range m.sessions {
+		if time.Now().After(s.ExpiresAt) {
+			delete(m.sessions, id)
+		}
+	}
+	m.sessions[session.ID] = session
+
+	return session, nil
+}
+
+// Take removes and returns the session with the given ID if it belongs to the VM and has not expired
+func (m *Manager) Take(id string, vmID string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[id]
+	if !ok || session.VMID != vmID {
+		return nil, ErrNoSession
+	}
+	delete(m.sessions, id)
+
+	if time.Now().After(session.ExpiresAt) {
+		return nil, ErrNoSession
+	}
+	return session, nil
+}
+
+// Relay connects the client websocket to the VNC websocket of the session and copies data both ways until one side closes
+func (m *Manager) Relay(client *websocket.Conn, session *Session) {
+	defer client.Close()
+
+	requestCtx := client.Request().Context()
+	ctx, cancel := context.WithTimeout(requestCtx, dialTimeout)
+	defer cancel()
+
+	upstream, err := m.consoles.DialVNCWebSocket(ctx, session.node, session.VMID, session.ticket)
+	if err != nil {
+		slog.WarnContext(requestCtx, "console failed", "vmid", session.VMID, "user", session.User, "error", err)
+		return
+	}
+	defer upstream.Close()
+
+	slog.InfoContext(requestCtx, "console opened", "vmid", session.VMID, "user", session.User)
+	started := time.Now()
+
+	client.PayloadType = websocket.BinaryFrame
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, client)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+
+	slog.InfoContext(requestCtx, "console closed", "vmid", session.VMID, "user", session.User, "duration", time.Since(started).Round(time.Second).String())
+}
+
+// newSessionID returns a random, unguessable session ID
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,402 @@
+package demo
+
+import (
+	"bytes"
+	"context"
+	"crypto/des"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+)
+
+// ticketTTL is how long a VNC proxy waits for its websocket, like on Proxmox
+const ticketTTL = 10 * time.Second
+
+// Size of the simulated screen
+const (
+	screenWidth  = 800
+	screenHeight = 600
+)
+
+// RFB message types
+const (
+	rfbSetPixelFormat           = 0
+	rfbSetEncodings             = 2
+	rfbFramebufferUpdateRequest = 3
+	rfbKeyEvent                 = 4
+	rfbPointerEvent             = 5
+	rfbClientCutText            = 6
+)
+
+// rfbSecurityVNCAuth is the DES challenge-response security type used by the Proxmox VNC proxy, with the ticket as password
+const rfbSecurityVNCAuth = 2
+
+// ticket is an open VNC proxy of a VM
+type ticket struct {
+	vmID    int
+	expires time.Time
+}
+
+// pixelFormat is the RFB pixel format requested by the client
+type pixelFormat struct {
+	BitsPerPixel uint8
+	Depth        uint8
+	BigEndian    uint8
+	TrueColour   uint8
+	RedMax       uint16
+	GreenMax     uint16
+	BlueMax      uint16
+	RedShift     uint8
+	GreenShift   uint8
+	BlueShift    uint8
+	_            [3]byte
+}
+
+// defaultPixelFormat is 32 bit little-endian true colour
+var defaultPixelFormat = pixelFormat{
+	BitsPerPixel: 32, Depth: 24, TrueColour: 1,
+	RedMax: 255, GreenMax: 255, BlueMax: 255,
+	RedShift: 16, GreenShift: 8, BlueShift: 0,
+}
+
+// OpenVNCProxy opens a simulated VNC proxy for a VM, which must be dialled within a few seconds
+func (l *Lab) OpenVNCProxy(ctx context.Context, node string, vmID string) (*platform.VNCTicket, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	vm, err := l.vm(node, vmID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open VNC proxy: %w", err)
+	}
+	for key, t := range l.tickets {
+		if time.Now().After(t.expires) {
+			delete(l.tickets, key)
+		}
+	}
+	value := hex.EncodeToString(b)
+	l.tickets[value] = &ticket{vmID: vm.id, expires: time.Now().Add(ticketTTL)}
+
+	return &platform.VNCTicket{Port: strconv.Itoa(5900 + len(l.tickets)), Ticket: value}, nil
+}
+
+// DialVNCWebSocket connects to a proxy opened by OpenVNCProxy. The returned stream is served by an RFB server
+// showing the sign-in screen of the VM, protected by the ticket like a Proxmox console.
+func (l *Lab) DialVNCWebSocket(ctx context.Context, node string, vmID string, vncTicket *platform.VNCTicket) (io.ReadWriteCloser, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.finishTasks()
+
+	vm, err := l.vm(node, vmID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to VNC websocket: %w", err)
+	}
+	t, ok := l.tickets[vncTicket.Ticket]
+	if !ok || t.vmID != vm.id || time.Now().After(t.expires) {
+		return nil, fmt.Errorf("failed to connect to VNC websocket: invalid or expired ticket")
+	}
+	delete(l.tickets, vncTicket.Ticket)
+	if vm.status != "running" {
+		return nil, fmt.Errorf("failed to connect to VNC websocket: VM %d not running", vm.id)
+	}
+
+	client, server := net.Pipe()
+	go serveRFB(server, vncTicket.Ticket, vm.name, drawScreen(vm.name, vm.hostname))
+	return client, nil
+}
+
+// serveRFB speaks RFB 3.8 on conn until the client goes away. Every non-incremental update request is answered with
+// the requested part of screen; incremental ones are not, since the screen never changes.
+func serveRFB(conn net.Conn, password string, name string, screen []byte) {
+	defer conn.Close()
+
+	if err := handshakeRFB(conn, password, name); err != nil {
+		return
+	}
+
+	format := defaultPixelFormat
+	header := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+
+		var err error
+		switch header[0] {
+		case rfbSetPixelFormat:
+			var msg struct {
+				_      [3]byte
+				Format pixelFormat
+			}
+			err = binary.Read(conn, binary.BigEndian, &msg)
+			format = msg.Format
+		case rfbSetEncodings:
+			var msg struct {
+				_     byte
+				Count uint16
+			}
+			if err = binary.Read(conn, binary.BigEndian, &msg); err == nil {
+				_, err = io.CopyN(io.Discard, conn, 4*int64(msg.Count))
+			}
+		case rfbFramebufferUpdateRequest:
+			var msg struct {
+				Incremental         uint8
+				X, Y, Width, Height uint16
+			}
+			if err = binary.Read(conn, binary.BigEndian, &msg); err == nil && msg.Incremental == 0 {
+				err = writeUpdate(conn, format, screen, int(msg.X), int(msg.Y), int(msg.Width), int(msg.Height))
+			}
+		case rfbKeyEvent:
+			_, err = io.CopyN(io.Discard, conn, 7)
+		case rfbPointerEvent:
+			_, err = io.CopyN(io.Discard, conn, 5)
+		case rfbClientCutText:
+			var msg struct {
+				_      [3]byte
+				Length uint32
+			}
+			if err = binary.Read(conn, binary.BigEndian, &msg); err == nil {
+				_, err = io.CopyN(io.Discard, conn, int64(msg.Length))
+			}
+		default:
+			err = fmt.Errorf("unknown RFB message type %d", header[0])
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handshakeRFB negotiates the protocol version, authenticates the client with VNC authentication and sends ServerInit
+func handshakeRFB(conn net.Conn, password string, name string) error {
+	if _, err := io.WriteString(conn, "RFB 003.008\n"); err != nil {
+		return err
+	}
+	version := make([]byte, 12)
+	if _, err := io.ReadFull(conn, version); err != nil {
+		return err
+	}
+
+	if _, err := conn.Write([]byte{1, rfbSecurityVNCAuth}); err != nil {
+		return err
+	}
+	chosen := make([]byte, 1)
+	if _, err := io.ReadFull(conn, chosen); err != nil {
+		return err
+	}
+
+	challenge := make([]byte, 16)
+	if _, err := rand.Read(challenge); err != nil {
+		return err
+	}
+	if _, err := conn.Write(challenge); err != nil {
+		return err
+	}
+	response := make([]byte, 16)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return err
+	}
+
+	expected, err := vncAuthResponse(password, challenge)
+	if err != nil {
+		return err
+	}
+	if chosen[0] != rfbSecurityVNCAuth || !bytes.Equal(response, expected) {
+		reason := "Authentication failed"
+		result := binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, 1), uint32(len(reason)))
+		conn.Write(append(result, reason...))
+		return errors.New("VNC authentication failed")
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return err
+	}
+
+	// ClientInit 只有共享标志
+	if _, err := io.ReadFull(conn, chosen); err != nil {
+		return err
+	}
+
+	var init bytes.Buffer
+	binary.Write(&init, binary.BigEndian, uint16(screenWidth))
+	binary.Write(&init, binary.BigEndian, uint16(screenHeight))
+	binary.Write(&init, binary.BigEndian, defaultPixelFormat)
+	binary.Write(&init, binary.BigEndian, uint32(len(name)))
+	init.WriteString(name)
+	_, err = conn.Write(init.Bytes())
+	return err
+}
+
+// vncAuthResponse encrypts the challenge with DES, keyed with the first 8 bytes of the password with their bits reversed
+func vncAuthResponse(password string, challenge []byte) ([]byte, error) {
+	key := make([]byte, 8)
+	copy(key, password)
+	for i := range key {
+		key[i] = bits.Reverse8(key[i])
+	}
+
+	cipher, err := des.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	response := make([]byte, len(challenge))
+	for i := 0; i+8 <= len(challenge); i += 8 {
+		cipher.Encrypt(response[i:i+8], challenge[i:i+8])
+	}
+	return response, nil
+}
+
+// writeUpdate sends a FramebufferUpdate with a single raw rectangle of screen, clipped to the screen,
+// in the pixel format of the client
+func writeUpdate(conn net.Conn, format pixelFormat, screen []byte, x, y, width, height int) error {
+	width = max(0, min(width, screenWidth-x))
+	height = max(0, min(height, screenHeight-y))
+
+	bytesPerPixel := max(1, int(format.BitsPerPixel)/8)
+	var order binary.ByteOrder = binary.LittleEndian
+	if format.BigEndian != 0 {
+		order = binary.BigEndian
+	}
+
+	update := make([]byte, 0, 16+width*height*bytesPerPixel)
+	update = append(update, 0, 0)
+	update = binary.BigEndian.AppendUint16(update, 1)
+	for _, v := range []int{x, y, width, height} {
+		update = binary.BigEndian.AppendUint16(update, uint16(v))
+	}
+	update = binary.BigEndian.AppendUint32(update, 0) // Raw 编码
+
+	pixel := make([]byte, 4)
+	for row := y; row < y+height; row++ {
+		for col := x; col < x+width; col++ {
+			rgb := screen[(row*screenWidth+col)*3:]
+			value := uint32(rgb[0])*uint32(format.RedMax)/255<<format.RedShift |
+				uint32(rgb[1])*uint32(format.GreenMax)/255<<format.GreenShift |
+				uint32(rgb[2])*uint32(format.BlueMax)/255<<format.BlueShift
+			switch bytesPerPixel {
+			case 1:
+				pixel[0] = byte(value)
+			case 2:
+				order.PutUint16(pixel, uint16(value))
+			default:
+				order.PutUint32(pixel, value)
+			}
+			update = append(update, pixel[:bytesPerPixel]...)
+		}
+	}
+
+	_, err := conn.Write(update)
+	return err
+}
+
+// Colours of the sign-in screen
+var (
+	desktopColour = [3]byte{0x00, 0x4c, 0x87}
+	taskbarColour = [3]byte{0x1f, 0x1f, 0x1f}
+	textColour    = [3]byte{0xff, 0xff, 0xff}
+	dimColour     = [3]byte{0xb4, 0xc8, 0xdc}
+)
+
+// drawScreen renders the Windows sign-in screen of a VM as RGB bytes
+func drawScreen(name string, hostname string) []byte {
+	screen := make([]byte, screenWidth*screenHeight*3)
+	fill(screen, 0, 0, screenWidth, screenHeight, desktopColour)
+	fill(screen, 0, screenHeight-40, screenWidth, 40, taskbarColour)
+
+	drawCentered(screen, strings.ToUpper(hostname), 220, 6, textColour)
+	drawCentered(screen, "PRESS CTRL+ALT+DEL TO SIGN IN.", 300, 2, dimColour)
+	drawText(screen, strings.ToUpper(name), 16, screenHeight-28, 2, textColour)
+	clock := time.Now().Format("15:04")
+	drawText(screen, clock, screenWidth-16-textWidth(clock, 2), screenHeight-28, 2, textColour)
+	return screen
+}
+
+// fill paints a rectangle
+func fill(screen []byte, x, y, width, height int, colour [3]byte) {
+	for row := y; row < y+height; row++ {
+		for col := x; col < x+width; col++ {
+			copy(screen[(row*screenWidth+col)*3:], colour[:])
+		}
+	}
+}
+
+// textWidth returns the width in pixels of text drawn at scale
+func textWidth(text string, scale int) int {
+	return len(text) * 6 * scale
+}
+
+// drawCentered draws text centred horizontally
+func drawCentered(screen []byte, text string, y int, scale int, colour [3]byte) {
+	drawText(screen, text, (screenWidth-textWidth(text, scale))/2, y, scale, colour)
+}
+
+// drawText draws text with the 5x7 font, each dot scale pixels wide. Characters without a glyph are left blank.
+func drawText(screen []byte, text string, x, y int, scale int, colour [3]byte) {
+	for i, char := range text {
+		glyph := font[char]
+		for row, bits := range glyph {
+			for col := 0; col < 5; col++ {
+				if bits&(0x10>>col) != 0 {
+					fill(screen, x+(i*6+col)*scale, y+row*scale, scale, scale, colour)
+				}
+			}
+		}
+	}
+}
+
+// font is a 5x7 bitmap font of the upper-case letters, the digits and some punctuation. Each row is 5 bits, the MSB left.
+var font = map[rune][7]byte{
+	'A': {0x0E, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'B': {0x1E, 0x11, 0x11, 0x1E, 0x11, 0x11, 0x1E},
+	'C': {0x0E, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0E},
+	'D': {0x1E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x1E},
+	'E': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x1F},
+	'F': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x10},
+	'G': {0x0E, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0F},
+	'H': {0x11, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'I': {0x0E, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'J': {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0C},
+	'K': {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	'L': {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1F},
+	'M': {0x11, 0x1B, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'N': {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},
+	'O': {0x0E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'P': {0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10, 0x10},
+	'Q': {0x0E, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0D},
+	'R': {0x1E, 0x11, 0x11, 0x1E, 0x14, 0x12, 0x11},
+	'S': {0x0F, 0x10, 0x10, 0x0E, 0x01, 0x01, 0x1E},
+	'T': {0x1F, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U': {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'V': {0x11, 0x11, 0x11, 0x11, 0x11, 0x0A, 0x04},
+	'W': {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0A},
+	'X': {0x11, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x11},
+	'Y': {0x11, 0x11, 0x0A, 0x04, 0x04, 0x04, 0x04},
+	'Z': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1F},
+	'0': {0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E},
+	'1': {0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'2': {0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F},
+	'3': {0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E},
+	'4': {0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02},
+	'5': {0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E},
+	'6': {0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E},
+	'7': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E},
+	'9': {0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C},
+	'.': {0x00, 0x00, 0x00, 0x00, 0x00, 0x0C, 0x0C},
+	'-': {0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00},
+	'+': {0x00, 0x04, 0x04, 0x1F, 0x04, 0x04, 0x00},
+	':': {0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x0C, 0x00},
+}
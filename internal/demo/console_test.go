@@ -0,0 +1,171 @@
+package demo
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+)
+
+func newTestLab(t *testing.T) *Lab {
+	t.Helper()
+	t.Setenv("DEMO", "1")
+	config, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("failed to load configuration: %v", err)
+	}
+	return NewLab(config)
+}
+
+// authenticate runs the RFB handshake up to the security result, answering the challenge with password
+func authenticate(t *testing.T, conn io.ReadWriter, password string) uint32 {
+	t.Helper()
+
+	banner := make([]byte, 12)
+	if _, err := io.ReadFull(conn, banner); err != nil || string(banner) != "RFB 003.008\n" {
+		t.Fatalf("banner %q, %v", banner, err)
+	}
+	conn.Write(banner)
+
+	types := make([]byte, 2)
+	if _, err := io.ReadFull(conn, types); err != nil || !bytes.Equal(types, []byte{1, rfbSecurityVNCAuth}) {
+		t.Fatalf("security types %v, %v, want VNC authentication only", types, err)
+	}
+	conn.Write([]byte{rfbSecurityVNCAuth})
+
+	challenge := make([]byte, 16)
+	if _, err := io.ReadFull(conn, challenge); err != nil {
+		t.Fatal(err)
+	}
+	response, err := vncAuthResponse(password, challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write(response)
+
+	var result uint32
+	if err := binary.Read(conn, binary.BigEndian, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestConsole(t *testing.T) {
+	lab := newTestLab(t)
+	ctx := context.Background()
+
+	ticket, err := lab.OpenVNCProxy(ctx, Node, "101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := lab.DialVNCWebSocket(ctx, Node, "101", ticket)
+	if err != nil {
+		t.Fatalf("DialVNCWebSocket: %v", err)
+	}
+	defer conn.Close()
+
+	if result := authenticate(t, conn, ticket.Ticket); result != 0 {
+		t.Fatalf("security result %d with the ticket as password", result)
+	}
+	conn.Write([]byte{1})
+
+	var init struct {
+		Width, Height uint16
+		Format        pixelFormat
+		NameLength    uint32
+	}
+	if err := binary.Read(conn, binary.BigEndian, &init); err != nil {
+		t.Fatal(err)
+	}
+	name := make([]byte, init.NameLength)
+	io.ReadFull(conn, name)
+	if init.Width != screenWidth || init.Height != screenHeight || string(name) != "DC01" {
+		t.Errorf("ServerInit %+v named %q", init, name)
+	}
+
+	// 16 bit big-endian RGB565, then the top left corner of the desktop
+	format := pixelFormat{BitsPerPixel: 16, Depth: 16, BigEndian: 1, TrueColour: 1, RedMax: 31, GreenMax: 63, BlueMax: 31, RedShift: 11, GreenShift: 5}
+	var request bytes.Buffer
+	binary.Write(&request, binary.BigEndian, struct {
+		Type   uint8
+		_      [3]byte
+		Format pixelFormat
+	}{Type: rfbSetPixelFormat, Format: format})
+	binary.Write(&request, binary.BigEndian, []byte{rfbSetEncodings, 0, 0, 1, 0, 0, 0, 0})
+	binary.Write(&request, binary.BigEndian, []byte{rfbPointerEvent, 0, 0, 5, 0, 5})
+	binary.Write(&request, binary.BigEndian, struct {
+		Type, Incremental   uint8
+		X, Y, Width, Height uint16
+	}{Type: rfbFramebufferUpdateRequest, Width: 4, Height: 2})
+	conn.Write(request.Bytes())
+
+	update := make([]byte, 4+12+4*2*2)
+	if _, err := io.ReadFull(conn, update); err != nil {
+		t.Fatal(err)
+	}
+	if binary.BigEndian.Uint16(update[2:]) != 1 || binary.BigEndian.Uint16(update[8:]) != 4 || binary.BigEndian.Uint32(update[12:]) != 0 {
+		t.Errorf("update header %v, want one raw rectangle 4 pixels wide", update[:16])
+	}
+	want := uint16(desktopColour[0])*31/255<<11 | uint16(desktopColour[1])*63/255<<5 | uint16(desktopColour[2])*31/255
+	if pixel := binary.BigEndian.Uint16(update[16:]); pixel != want {
+		t.Errorf("first pixel 0x%04x, want the desktop colour 0x%04x", pixel, want)
+	}
+
+	if _, err := lab.DialVNCWebSocket(ctx, Node, "101", ticket); err == nil {
+		t.Error("a ticket could be used twice")
+	}
+}
+
+func TestConsoleWrongPassword(t *testing.T) {
+	lab := newTestLab(t)
+	ctx := context.Background()
+
+	ticket, err := lab.OpenVNCProxy(ctx, Node, "102")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := lab.DialVNCWebSocket(ctx, Node, "102", ticket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if result := authenticate(t, conn, "wrong"); result != 1 {
+		t.Errorf("security result %d with a wrong password, want 1", result)
+	}
+}
+
+func TestConsoleRefused(t *testing.T) {
+	lab := newTestLab(t)
+	ctx := context.Background()
+
+	ticket, err := lab.OpenVNCProxy(ctx, Node, "101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lab.DialVNCWebSocket(ctx, Node, "102", ticket); err == nil {
+		t.Error("the ticket of DC01 opened the console of DC02")
+	}
+
+	// Templates never run
+	ticket, err = lab.OpenVNCProxy(ctx, Node, "9001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lab.DialVNCWebSocket(ctx, Node, "9001", ticket); err == nil {
+		t.Error("the console of a stopped VM opened")
+	}
+
+	expired, err := lab.OpenVNCProxy(ctx, Node, "101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lab.tickets[expired.Ticket].expires = time.Now().Add(-time.Second)
+	if _, err := lab.DialVNCWebSocket(ctx, Node, "101", expired); err == nil {
+		t.Error("an expired ticket opened a console")
+	}
+}
@@ -0,0 +1,325 @@
+// Package demo simulates a GOAD lab in process, so that the dashboard can be tried without Proxmox and pfSense.
+// Lab implements the hypervisor, the VPN gateway and the service probes the server is built on,
+// so every endpoint, consoles and instances included, goes through the same code as against a real lab.
+package demo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/health"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+)
+
+// Node is the Proxmox node of the simulated lab
+const Node = "pve"
+
+// tickInterval is how often the simulated load and VPN users change
+const tickInterval = 3 * time.Second
+
+// taskDuration is how long rollbacks, power actions and clones take
+const taskDuration = 5 * time.Second
+
+// host is a VM of GOAD, cloned from a template into the shared lab and into each instance
+type host struct {
+	id       int
+	name     string
+	hostname string
+	os       string
+	ip       string
+	mac      string
+	load     float64 // 空闲时的 CPU 使用率
+}
+
+// hosts are the five VMs of GOAD
+var hosts = []host{
+	{101, "DC01", "kingslanding", "Microsoft Windows Server 2019 Datacenter", "192.168.56.10", "BC:24:11:56:00:10", 0.08},
+	{102, "DC02", "winterfell", "Microsoft Windows Server 2019 Datacenter", "192.168.56.11", "BC:24:11:56:00:11", 0.06},
+	{103, "DC03", "meereen", "Microsoft Windows Server 2016 Datacenter", "192.168.56.12", "BC:24:11:56:00:12", 0.06},
+	{104, "SRV02", "castelblack", "Microsoft Windows Server 2019 Datacenter", "192.168.56.22", "BC:24:11:56:00:22", 0.12},
+	{105, "SRV03", "braavos", "Microsoft Windows Server 2016 Datacenter", "192.168.56.23", "BC:24:11:56:00:23", 0.04},
+}
+
+// templateOffset is added to the ID of a GOAD VM to get the ID of its template, e.g. 9001 for DC01
+const templateOffset = 8900
+
+// users are the VPN users that connect and disconnect
+var users = []string{"jon.snow", "arya.stark", "sansa.stark", "samwell.tarly", "daenerys.targaryen", "tyrion.lannister"}
+
+// vm is a simulated VM
+type vm struct {
+	id        int
+	name      string
+	status    string // running, stopped 或 paused
+	template  bool
+	pool      string
+	cpus      int
+	cpu       float64 // CPU 使用率 (0-1)
+	mem       int64
+	maxMem    int64
+	maxDisk   int64
+	started   time.Time
+	bootTime  time.Duration // 启动后 guest agent 和服务就绪所需的时间
+	hostname  string
+	os        string
+	ip        string
+	mac       string
+	bridge    string
+	vlan      int
+	load      float64
+	snapshots []platform.SnapshotInfo
+}
+
+// Lab is a simulated GOAD lab: a Proxmox node running the lab VMs and the templates of the instances,
+// and the pfSense gateway in front of them. The VMs start running; Run makes their load fluctuate and VPN users come and go.
+type Lab struct {
+	mu        sync.Mutex
+	vms       map[int]*vm
+	pools     map[string]string // 资源池及其备注
+	labPool   string
+	tasks     map[string]*task
+	taskCount int
+	tickets   map[string]*ticket
+	labHosts  []config.LabHost
+	lastReset uint64
+	bootedAt  time.Time
+
+	connections    []platform.VPNConnection
+	connectionID   int
+	rules          []rule
+	cpuUsage       float64
+	memUsage       float64
+	gatewayStarted time.Time
+
+	rand *rand.Rand
+}
+
+var (
+	_ platform.Hypervisor = (*Lab)(nil)
+	_ platform.VPNGateway = (*Lab)(nil)
+	_ health.Prober       = (*Lab)(nil)
+)
+
+// NewLab creates the simulated lab. Its shared lab VMs are put in PROXMOX_POOL, its services are probed as LAB_HOSTS.
+func NewLab(config *config.Config) *Lab {
+	now := time.Now()
+	l := &Lab{
+		vms:            map[int]*vm{},
+		pools:          map[string]string{},
+		labPool:        config.GetProxmoxPool(),
+		tasks:          map[string]*task{},
+		tickets:        map[string]*ticket{},
+		labHosts:       config.GetLabHosts(),
+		bootedAt:       now.Add(-9 * 24 * time.Hour),
+		cpuUsage:       6,
+		memUsage:       22,
+		gatewayStarted: now.Add(-9*24*time.Hour + 2*time.Minute),
+		rand:           rand.New(rand.NewSource(now.UnixNano())),
+	}
+	if l.labPool != "" {
+		l.pools[l.labPool] = "GOAD shared lab"
+	}
+
+	// 快照在 GOAD 部署完成后拍摄
+	installed := now.Add(-14 * 24 * time.Hour).Unix()
+	for _, h := range hosts {
+		bootTime := 30 * time.Second
+		if strings.HasPrefix(h.name, "DC") {
+			// 域控制器启动 AD 服务需要更久
+			bootTime = 45 * time.Second
+		}
+
+		template := &vm{
+			id:       h.id + templateOffset,
+			name:     h.name,
+			status:   "stopped",
+			template: true,
+			cpus:     2,
+			maxMem:   4 << 30,
+			maxDisk:  60 << 30,
+			bootTime: bootTime,
+			hostname: h.hostname,
+			os:       h.os,
+			ip:       h.ip,
+			mac:      macAddress(h.id + templateOffset),
+			bridge:   "vmbr1",
+			load:     h.load,
+		}
+		l.vms[template.id] = template
+
+		lab := template.clone(h.id, h.name, l.labPool)
+		lab.mac = h.mac
+		lab.status = "running"
+		lab.started = now.Add(-time.Duration(2*24*3600+l.rand.Intn(3600)) * time.Second)
+		lab.cpu = h.load
+		lab.mem = lab.maxMem / 2
+		lab.snapshots = []platform.SnapshotInfo{
+			{Name: "installed", Description: "Windows installed", SnapTime: installed},
+			{Name: "provisioned", Description: "GOAD provisioned", SnapTime: installed + 3*3600},
+		}
+		l.vms[lab.id] = lab
+	}
+
+	l.rules = []rule{
+		{mode: platform.NetworkModeExam, FirewallRule: platform.FirewallRule{ID: 0, Tracker: 1700000001, Type: "block", Interface: []string{"lan"}, Description: "Block internet access from the lab [exam]", Disabled: true}},
+		{mode: platform.NetworkModeIsolated, FirewallRule: platform.FirewallRule{ID: 1, Tracker: 1700000002, Type: "block", Interface: []string{"openvpn"}, Description: "Block traffic between VPN clients [isolated]", Disabled: true}},
+	}
+	l.connect(users[0])
+	l.connect(users[1])
+
+	return l
+}
+
+// Run simulates activity in the lab until ctx is done
+func (l *Lab) Run(ctx context.Context) {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			l.finishTasks()
+			l.fluctuate()
+			l.churn()
+			l.mu.Unlock()
+		}
+	}
+}
+
+// fluctuate moves the CPU and memory usage of the running VMs towards their idle load.
+// VMs that booted recently are busier, as Windows starts its services. The caller holds l.mu.
+func (l *Lab) fluctuate() {
+	for _, vm := range l.vms {
+		if vm.status != "running" {
+			continue
+		}
+
+		cpu, mem := vm.load, 0.55
+		if booting := time.Since(vm.started); booting < 2*vm.bootTime {
+			cpu, mem = 0.7, 0.3+0.25*float64(booting)/float64(2*vm.bootTime)
+		}
+		vm.cpu = clamp(vm.cpu+(cpu-vm.cpu)*0.3+l.rand.NormFloat64()*0.03, 0.01, 1)
+		ratio := clamp(float64(vm.mem)/float64(vm.maxMem)+(mem-float64(vm.mem)/float64(vm.maxMem))*0.3+l.rand.NormFloat64()*0.01, 0.1, 0.95)
+		vm.mem = int64(ratio * float64(vm.maxMem))
+	}
+
+	l.cpuUsage = math.Round(clamp(4+1.5*float64(len(l.connections))+l.rand.NormFloat64(), 1, 100))
+	l.memUsage = math.Round(clamp(22+l.rand.NormFloat64(), 1, 100))
+}
+
+// churn now and then connects a VPN user or disconnects one. The caller holds l.mu.
+func (l *Lab) churn() {
+	if l.rand.Float64() > 0.1 {
+		return
+	}
+
+	connected := map[string]bool{}
+	for _, conn := range l.connections {
+		connected[conn.Name] = true
+	}
+
+	var online, offline []string
+	for _, user := range users {
+		if connected[user] {
+			online = append(online, user)
+		} else {
+			offline = append(offline, user)
+		}
+	}
+
+	if len(offline) > 0 && (len(online) == 0 || l.rand.Intn(2) == 0) {
+		l.connect(offline[l.rand.Intn(len(offline))])
+		return
+	}
+	l.disconnect(online[l.rand.Intn(len(online))])
+}
+
+// clone returns a stopped linked clone of the VM with a new ID, name and MAC address
+func (v *vm) clone(id int, name string, pool string) *vm {
+	clone := *v
+	clone.id = id
+	clone.name = name
+	clone.pool = pool
+	clone.template = false
+	clone.status = "stopped"
+	clone.started = time.Time{}
+	clone.snapshots = nil
+	clone.mac = macAddress(id)
+	return &clone
+}
+
+// setStatus changes the status of the VM, restarting its uptime when it starts running
+func (v *vm) setStatus(status string) {
+	wasRunning := v.status == "running"
+	v.status = status
+	switch {
+	case status != "running":
+		v.started = time.Time{}
+	case !wasRunning:
+		v.started = time.Now()
+		v.cpu = 0.7
+		v.mem = v.maxMem * 3 / 10
+	}
+}
+
+// booted reports whether the VM has been running for at least d
+func (v *vm) booted(d time.Duration) bool {
+	return v.status == "running" && time.Since(v.started) >= d
+}
+
+// info returns the VM as the hypervisor reports it
+func (v *vm) info() platform.VMInfo {
+	info := platform.VMInfo{
+		ID:       strconv.Itoa(v.id),
+		Name:     v.name,
+		Status:   v.status,
+		CPUs:     float64(v.cpus),
+		MaxMem:   v.maxMem,
+		MaxDisk:  v.maxDisk,
+		Node:     Node,
+		Template: v.template,
+	}
+	if v.status == "running" {
+		uptime := time.Since(v.started)
+		info.CPU = v.cpu
+		info.Memory = float64(v.mem)
+		info.Uptime = int(uptime.Seconds())
+		// 自启动以来的平均速率
+		info.DiskRead = int64(uptime.Seconds() * 200000)
+		info.DiskWrite = int64(uptime.Seconds() * 150000)
+		info.NetIn = int64(uptime.Seconds() * 20000)
+		info.NetOut = int64(uptime.Seconds() * 12000)
+	}
+	return info
+}
+
+// sortedVMs returns the VMs ordered by ID. The caller holds l.mu.
+func (l *Lab) sortedVMs() []*vm {
+	vms := make([]*vm, 0, len(l.vms))
+	for _, vm := range l.vms {
+		vms = append(vms, vm)
+	}
+	sort.Slice(vms, func(i, j int) bool { return vms[i].id < vms[j].id })
+	return vms
+}
+
+// macAddress returns the MAC address Proxmox would generate for a VM, derived from its ID so that it is stable
+func macAddress(id int) string {
+	return fmt.Sprintf("BC:24:11:%02X:%02X:%02X", byte(id>>16), byte(id>>8), byte(id))
+}
+
+// clamp limits v to [min, max]
+func clamp(v, min, max float64) float64 {
+	return math.Max(min, math.Min(max, v))
+}
@@ -0,0 +1,146 @@
+package demo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+)
+
+// rule is a firewall rule of the simulated pfSense, enabled by a network mode
+type rule struct {
+	platform.FirewallRule
+	mode string
+}
+
+// GetOpenVPNConnections returns the connected VPN users
+func (l *Lab) GetOpenVPNConnections(ctx context.Context) ([]platform.VPNConnection, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]platform.VPNConnection{}, l.connections...), nil
+}
+
+// GetSystemStatus returns the resource usage of pfSense, which grows with the VPN users
+func (l *Lab) GetSystemStatus(ctx context.Context) (*platform.SystemStatus, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	uptime := time.Since(l.gatewayStarted)
+	return &platform.SystemStatus{
+		Uptime:     fmt.Sprintf("%d Days %02d Hours %02d Minutes %02d Seconds", int(uptime.Hours())/24, int(uptime.Hours())%24, int(uptime.Minutes())%60, int(uptime.Seconds())%60),
+		CPUUsage:   l.cpuUsage,
+		CPUCount:   2,
+		CPULoadAvg: []float64{l.cpuUsage / 50, l.cpuUsage / 60, l.cpuUsage / 70},
+		MemUsage:   l.memUsage,
+		DiskUsage:  12,
+	}, nil
+}
+
+// GetHealth reports the simulated pfSense as healthy, with its VPN, DNS and DHCP services running
+func (l *Lab) GetHealth(ctx context.Context) *platform.GatewayHealth {
+	system, _ := l.GetSystemStatus(ctx)
+	return &platform.GatewayHealth{
+		State:   platform.HealthHealthy,
+		Reasons: []string{},
+		System:  system,
+		Services: []platform.ServiceStatus{
+			{Name: "openvpn", Description: "OpenVPN server: GOAD lab", Enabled: true, Status: true},
+			{Name: "unbound", Description: "DNS Resolver", Enabled: true, Status: true},
+			{Name: "dhcpd", Description: "DHCP Service", Enabled: true, Status: true},
+		},
+		Gateways: []platform.GatewayStatus{
+			{Name: "WAN_DHCP", MonitorIP: "1.1.1.1", Delay: "1.2ms", Loss: "0.0%", Status: "online", Substatus: "none"},
+		},
+		Interfaces: []platform.InterfaceStatus{
+			{Name: "wan", Descr: "WAN", HWIF: "vtnet0", Status: "up", Enabled: true, IPAddr: "192.0.2.2"},
+			{Name: "lan", Descr: "LAB", HWIF: "vtnet1", Status: "up", Enabled: true, IPAddr: "192.168.56.1"},
+			{Name: "opt1", Descr: "OPENVPN", HWIF: "ovpns1", Status: "up", Enabled: true, IPAddr: "10.8.0.1"},
+		},
+	}
+}
+
+// GetLeaseTable returns the DHCP leases of the shared lab VMs. The instances are on their own VLANs, out of pfSense's reach.
+func (l *Lab) GetLeaseTable(ctx context.Context) (*platform.LeaseTable, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	table := &platform.LeaseTable{Leases: []platform.DHCPLease{}, ARP: []platform.ARPEntry{}}
+	for _, vm := range l.sortedVMs() {
+		if vm.template || vm.pool != l.labPool {
+			continue
+		}
+		online := "offline"
+		if vm.status == "running" {
+			online = "online"
+			table.ARP = append(table.ARP, platform.ARPEntry{IP: vm.ip, MAC: vm.mac, Hostname: vm.hostname, Interface: "vtnet1", Type: "ethernet", Expires: "1200 seconds"})
+		}
+		table.Leases = append(table.Leases, platform.DHCPLease{
+			IP:           vm.ip,
+			MAC:          vm.mac,
+			Hostname:     vm.hostname,
+			Interface:    "lan",
+			ActiveStatus: "active",
+			OnlineStatus: online,
+		})
+	}
+	return table, nil
+}
+
+// GetNetworkModes returns the network modes, each enabling one of the firewall rules, and the active one
+func (l *Lab) GetNetworkModes(ctx context.Context) (*platform.NetworkModes, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	modes := &platform.NetworkModes{Current: platform.NetworkModeCustom}
+	for _, name := range []string{platform.NetworkModeNormal, platform.NetworkModeExam, platform.NetworkModeIsolated} {
+		mode := platform.NetworkMode{Name: name, Rules: []platform.FirewallRule{}}
+		active := true
+		for _, rule := range l.rules {
+			if rule.mode == name {
+				mode.Rules = append(mode.Rules, rule.FirewallRule)
+			}
+			active = active && rule.Disabled == (rule.mode != name)
+		}
+		modes.Modes = append(modes.Modes, mode)
+
+		if modes.Current == platform.NetworkModeCustom && active {
+			modes.Current = name
+		}
+	}
+	return modes, nil
+}
+
+// SetNetworkMode enables the rules of the given mode and disables the others
+func (l *Lab) SetNetworkMode(ctx context.Context, name string) error {
+	if !platform.IsNetworkMode(name) {
+		return fmt.Errorf("unknown network mode %q", name)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range l.rules {
+		l.rules[i].Disabled = l.rules[i].mode != name
+	}
+	return nil
+}
+
+// connect adds a VPN connection for user. The caller holds l.mu.
+func (l *Lab) connect(user string) {
+	l.connectionID++
+	l.connections = append(l.connections, platform.VPNConnection{
+		Id:          l.connectionID,
+		Name:        user,
+		ConnectTime: uint64(time.Now().Unix()),
+	})
+}
+
+// disconnect removes the VPN connection of user. The caller holds l.mu.
+func (l *Lab) disconnect(user string) {
+	for i, conn := range l.connections {
+		if conn.Name == user {
+			l.connections = append(l.connections[:i], l.connections[i+1:]...)
+			return
+		}
+	}
+}
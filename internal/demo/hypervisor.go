@@ -0,0 +1,430 @@
+package demo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/errdefs"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+)
+
+// task is a simulated Proxmox task. Its effect is applied once it finishes.
+type task struct {
+	done   time.Time
+	apply  func()
+	closed bool
+}
+
+// rrdSteps is the sample interval of each RRD timeframe
+var rrdSteps = map[string]time.Duration{
+	platform.TimeframeHour: time.Minute,
+	platform.TimeframeDay:  20 * time.Minute,
+	platform.TimeframeWeek: 3 * time.Hour,
+}
+
+// Resources of the simulated Proxmox node
+const (
+	nodeCPUs   = 32
+	nodeMemory = 128 << 30
+	// nodeOverhead is the memory used by Proxmox itself and the page cache
+	nodeOverhead = 6 << 30
+	storageSize  = 1 << 40
+)
+
+func (l *Lab) GetNodes(ctx context.Context) ([]string, error) {
+	return []string{Node}, nil
+}
+
+// GetNodeStatuses returns the usage of the node, driven by the VMs running on it
+func (l *Lab) GetNodeStatuses(ctx context.Context) ([]platform.NodeStatus, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.finishTasks()
+
+	var cpu float64
+	var mem int64 = nodeOverhead
+	for _, vm := range l.vms {
+		if vm.status == "running" {
+			cpu += vm.cpu * float64(vm.cpus)
+			mem += vm.mem
+		}
+	}
+
+	load := math.Round(cpu*100) / 100
+	return []platform.NodeStatus{{
+		Node:       Node,
+		Status:     platform.NodeOnline,
+		CPU:        cpu / nodeCPUs,
+		CPUs:       nodeCPUs,
+		Memory:     mem,
+		MaxMem:     nodeMemory,
+		Uptime:     int64(time.Since(l.bootedAt).Seconds()),
+		LoadAvg:    []float64{load, load * 0.9, load * 0.8},
+		PVEVersion: "pve-manager/8.2.4/faa83925c9641325",
+		Kernel:     "Linux 6.8.12-1-pve",
+	}}, nil
+}
+
+// GetStorage returns the storages of the node. Every VM and snapshot takes some space on local-lvm.
+func (l *Lab) GetStorage(ctx context.Context) ([]platform.StorageInfo, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var used int64
+	for _, vm := range l.vms {
+		// 链接克隆只占用写入的数据, 每个快照大约增加磁盘大小的十分之一
+		used += vm.maxDisk / 4 * int64(10+len(vm.snapshots)) / 10
+	}
+
+	return []platform.StorageInfo{
+		newStorage("local", "dir", []string{"iso", "vztmpl", "backup"}, storageSize/10, storageSize/4),
+		newStorage("local-lvm", "lvmthin", []string{"images", "rootdir"}, used, storageSize),
+	}, nil
+}
+
+// newStorage returns an active storage of the node
+func newStorage(name string, kind string, content []string, used int64, total int64) platform.StorageInfo {
+	return platform.StorageInfo{
+		Node:         Node,
+		Storage:      name,
+		Type:         kind,
+		Content:      content,
+		Active:       true,
+		Used:         used,
+		Total:        total,
+		Avail:        total - used,
+		UsedFraction: float64(used) / float64(total),
+	}
+}
+
+// GetVMs returns the VMs of the shared lab: the VMs of PROXMOX_POOL or, when it is not set, every VM but templates
+func (l *Lab) GetVMs(ctx context.Context) ([]platform.VMInfo, error) {
+	if l.labPool != "" {
+		return l.GetPoolVMs(ctx, l.labPool)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.finishTasks()
+
+	var vms []platform.VMInfo
+	for _, vm := range l.sortedVMs() {
+		if !vm.template {
+			vms = append(vms, vm.info())
+		}
+	}
+	return vms, nil
+}
+
+// FindVM returns the VM of the shared lab with the given ID, wrapping errdefs.ErrNotFound if there is none
+func (l *Lab) FindVM(ctx context.Context, vmID string) (*platform.VMInfo, error) {
+	vms, err := l.GetVMs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range vms {
+		if vms[i].ID == vmID {
+			return &vms[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("VM %s: %w", vmID, errdefs.ErrNotFound)
+}
+
+// GetVMMACs returns the lower-case MAC address of the single network device of a VM
+func (l *Lab) GetVMMACs(ctx context.Context, node string, vmID string) ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	vm, err := l.vm(node, vmID)
+	if err != nil {
+		return nil, err
+	}
+	return []string{strings.ToLower(vm.mac)}, nil
+}
+
+// GetVMMetrics returns 70 samples of a timeframe that wave around the current usage of the VM.
+// Samples from before the VM started are empty.
+func (l *Lab) GetVMMetrics(ctx context.Context, node string, vmID string, timeframe string, consolidation string) (*platform.VMMetrics, error) {
+	step, ok := rrdSteps[timeframe]
+	if !ok {
+		return nil, fmt.Errorf("failed to get metrics: unknown timeframe %q", timeframe)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.finishTasks()
+
+	vm, err := l.vm(node, vmID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metrics: %w", err)
+	}
+
+	// MAX 取每个采样区间的峰值
+	peak := 1.0
+	if consolidation == platform.ConsolidationMax {
+		peak = 1.3
+	}
+
+	now := time.Now().Truncate(step)
+	points := make([]platform.MetricPoint, 0, 70)
+	for i := 69; i >= 0; i-- {
+		at := now.Add(-time.Duration(i) * step)
+		point := platform.MetricPoint{Time: at.Unix()}
+		if vm.status == "running" && !at.Before(vm.started.Truncate(step)) {
+			wave := peak * (1 + 0.3*math.Sin(float64(at.Unix()/int64(step.Seconds()))/5+float64(vm.id)))
+			point.CPU = metric(math.Min(vm.cpu*wave, 1))
+			point.MaxCPU = metric(float64(vm.cpus))
+			point.Mem = metric(math.Min(float64(vm.mem)*(0.9+0.1*wave), float64(vm.maxMem)))
+			point.MaxMem = metric(float64(vm.maxMem))
+			point.DiskRead = metric(200000 * wave)
+			point.DiskWrite = metric(150000 * wave)
+			point.NetIn = metric(20000 * wave)
+			point.NetOut = metric(12000 * wave)
+		}
+		points = append(points, point)
+	}
+
+	return &platform.VMMetrics{
+		VMID:          vmID,
+		Node:          node,
+		Timeframe:     timeframe,
+		Consolidation: consolidation,
+		Points:        points,
+	}, nil
+}
+
+// metric returns a pointer to a sample value
+func metric(v float64) *float64 {
+	return &v
+}
+
+// AddGuestInfo fills the guest agent fields of the given VMs. The agents answer once the VMs have booted.
+func (l *Lab) AddGuestInfo(ctx context.Context, vms []platform.VMInfo) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.finishTasks()
+
+	for i := range vms {
+		if vms[i].Status != "running" {
+			vms[i].AgentStatus = platform.AgentStatusVMStopped
+			continue
+		}
+		vm, err := l.vm(vms[i].Node, vms[i].ID)
+		if err != nil {
+			vms[i].AgentStatus = platform.AgentStatusError
+			continue
+		}
+		vms[i].AgentStatus = vm.agentStatus()
+		if vms[i].AgentStatus == platform.AgentStatusOK {
+			vms[i].Hostname = vm.hostname
+			vms[i].OS = vm.os
+			vms[i].IPs = []string{vm.ip}
+		}
+	}
+}
+
+// PingAgent reports whether the guest agent of a VM answers
+func (l *Lab) PingAgent(ctx context.Context, node string, vmID string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.finishTasks()
+
+	vm, err := l.vm(node, vmID)
+	if err != nil {
+		return platform.AgentStatusError
+	}
+	return vm.agentStatus()
+}
+
+// agentStatus returns the state of the guest agent, which starts a while after the VM
+func (v *vm) agentStatus() string {
+	if !v.booted(v.bootTime) {
+		return platform.AgentStatusNotRunning
+	}
+	return platform.AgentStatusOK
+}
+
+func (l *Lab) StartVM(ctx context.Context, node string, vmID string) (string, error) {
+	return l.power(node, vmID, "qmstart", "running")
+}
+
+func (l *Lab) StopVM(ctx context.Context, node string, vmID string) (string, error) {
+	return l.power(node, vmID, "qmstop", "stopped")
+}
+
+// ShutdownVM shuts the guest down, which takes as long as any other task
+func (l *Lab) ShutdownVM(ctx context.Context, node string, vmID string, timeout time.Duration, forceStop bool) (string, error) {
+	return l.power(node, vmID, "qmshutdown", "stopped")
+}
+
+// power starts a task changing the status of a VM
+func (l *Lab) power(node string, vmID string, kind string, status string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.finishTasks()
+
+	vm, err := l.vm(node, vmID)
+	if err != nil {
+		return "", err
+	}
+	if vm.template {
+		return "", fmt.Errorf("VM %d is a template", vm.id)
+	}
+	return l.startTask(vm, kind, func() { vm.setStatus(status) }), nil
+}
+
+// WaitForTask waits until a task finishes. Simulated tasks always succeed.
+// An empty UPID, returned by synchronous calls, is treated as already finished.
+func (l *Lab) WaitForTask(ctx context.Context, node string, upid string) error {
+	if upid == "" {
+		return nil
+	}
+
+	for {
+		l.mu.Lock()
+		l.finishTasks()
+		t, ok := l.tasks[upid]
+		closed := ok && t.closed
+		l.mu.Unlock()
+
+		switch {
+		case !ok:
+			return fmt.Errorf("no such task %s", upid)
+		case closed:
+			return nil
+		}
+
+		timer := time.NewTimer(time.Until(t.done))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (l *Lab) StopAllVMs(ctx context.Context) ([]platform.VMOperationResult, error) {
+	return l.forAllVMs(ctx, "qmstop", "stopped")
+}
+
+func (l *Lab) ResetAllVMs(ctx context.Context) ([]platform.VMOperationResult, error) {
+	return l.forAllVMs(ctx, "qmreset", "running")
+}
+
+// SuspendAllVMs suspends every VM
+func (l *Lab) SuspendAllVMs(ctx context.Context) ([]platform.VMOperationResult, error) {
+	return l.forAllVMs(ctx, "qmsuspend", "paused")
+}
+
+// ResumeAllVMs resumes every VM
+func (l *Lab) ResumeAllVMs(ctx context.Context) ([]platform.VMOperationResult, error) {
+	return l.forAllVMs(ctx, "qmresume", "running")
+}
+
+// forAllVMs starts a power task on every VM of the shared lab and collects the outcomes
+func (l *Lab) forAllVMs(ctx context.Context, kind string, status string) ([]platform.VMOperationResult, error) {
+	vms, err := l.GetVMs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VMs: %w", err)
+	}
+
+	results := make([]platform.VMOperationResult, len(vms))
+	for i, vm := range vms {
+		results[i] = platform.VMOperationResult{VMID: vm.ID, Success: true}
+		if _, err := l.power(vm.Node, vm.ID, kind, status); err != nil {
+			results[i] = platform.VMOperationResult{VMID: vm.ID, Success: false, Message: err.Error()}
+		}
+	}
+	return results, nil
+}
+
+// ResetLab rolls every VM of the shared lab back to its latest snapshot, which leaves it stopped,
+// and waits for the rollbacks to finish
+func (l *Lab) ResetLab(ctx context.Context) ([]platform.VMOperationResult, error) {
+	atomic.StoreUint64(&l.lastReset, uint64(time.Now().Unix()))
+
+	vms, err := l.GetVMs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VMs: %w", err)
+	}
+
+	results := make([]platform.VMOperationResult, len(vms))
+	var wg sync.WaitGroup
+	for i, info := range vms {
+		results[i] = platform.VMOperationResult{VMID: info.ID}
+
+		l.mu.Lock()
+		vm, err := l.vm(info.Node, info.ID)
+		var upid string
+		switch {
+		case err != nil:
+		case len(vm.snapshots) == 0:
+			err = fmt.Errorf("no snapshots found")
+		default:
+			upid = l.startTask(vm, "qmrollback", func() { vm.setStatus("stopped") })
+		}
+		l.mu.Unlock()
+		if err != nil {
+			results[i].Message = err.Error()
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := l.WaitForTask(ctx, Node, upid); err != nil {
+				results[i].Message = err.Error()
+				return
+			}
+			results[i].Success = true
+		}(i)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+func (l *Lab) GetLastReset() (uint64, error) {
+	return atomic.LoadUint64(&l.lastReset), nil
+}
+
+// vm returns a VM of the node, failing like Proxmox if there is none. The caller holds l.mu.
+func (l *Lab) vm(node string, vmID string) (*vm, error) {
+	id, err := strconv.Atoi(vmID)
+	vm, ok := l.vms[id]
+	if err != nil || !ok || node != Node {
+		return nil, fmt.Errorf("Configuration file 'nodes/%s/qemu-server/%s.conf' does not exist", node, vmID)
+	}
+	return vm, nil
+}
+
+// startTask registers a task that applies its effect when it finishes and returns its UPID. The caller holds l.mu.
+func (l *Lab) startTask(vm *vm, kind string, apply func()) string {
+	l.taskCount++
+	upid := fmt.Sprintf("UPID:%s:%08X:%08X:%08X:%s:%d:root@pam:", Node, 1000+l.taskCount, l.taskCount, time.Now().Unix(), kind, vm.id)
+	l.tasks[upid] = &task{done: time.Now().Add(taskDuration), apply: apply}
+	return upid
+}
+
+// finishTasks applies the tasks whose duration has elapsed. The caller holds l.mu.
+func (l *Lab) finishTasks() {
+	now := time.Now()
+	for _, t := range l.tasks {
+		if t.closed || now.Before(t.done) {
+			continue
+		}
+		t.closed = true
+		if t.apply != nil {
+			t.apply()
+		}
+	}
+}
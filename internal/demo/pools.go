@@ -0,0 +1,170 @@
+package demo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/errdefs"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+)
+
+// GetPools returns every pool, the shared lab pool included
+func (l *Lab) GetPools(ctx context.Context) ([]platform.Pool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	pools := make([]platform.Pool, 0, len(l.pools))
+	for id, comment := range l.pools {
+		pools = append(pools, platform.Pool{ID: id, Comment: comment})
+	}
+	sort.Slice(pools, func(i, j int) bool { return pools[i].ID < pools[j].ID })
+	return pools, nil
+}
+
+// GetPoolVMs returns the VMs of a pool, templates excluded
+func (l *Lab) GetPoolVMs(ctx context.Context, pool string) ([]platform.VMInfo, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.finishTasks()
+
+	if _, ok := l.pools[pool]; !ok {
+		return nil, fmt.Errorf("failed to get pool %s: pool '%s' does not exist", pool, pool)
+	}
+
+	var vms []platform.VMInfo
+	for _, vm := range l.sortedVMs() {
+		if vm.pool == pool && !vm.template {
+			vms = append(vms, vm.info())
+		}
+	}
+	return vms, nil
+}
+
+// CreatePool creates a pool
+func (l *Lab) CreatePool(ctx context.Context, pool string, comment string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.pools[pool]; ok {
+		return fmt.Errorf("failed to create pool %s: pool '%s' already exists", pool, pool)
+	}
+	l.pools[pool] = comment
+	return nil
+}
+
+// SetPoolComment replaces the comment of a pool
+func (l *Lab) SetPoolComment(ctx context.Context, pool string, comment string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.pools[pool]; !ok {
+		return fmt.Errorf("failed to update pool %s: pool '%s' does not exist", pool, pool)
+	}
+	l.pools[pool] = comment
+	return nil
+}
+
+// DeletePool deletes an empty pool
+func (l *Lab) DeletePool(ctx context.Context, pool string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.finishTasks()
+
+	if _, ok := l.pools[pool]; !ok {
+		return fmt.Errorf("failed to delete pool %s: pool '%s' does not exist", pool, pool)
+	}
+	for _, vm := range l.vms {
+		if vm.pool == pool {
+			return fmt.Errorf("failed to delete pool %s: pool '%s' is not empty", pool, pool)
+		}
+	}
+	delete(l.pools, pool)
+	return nil
+}
+
+// FindTemplate returns the template VM with the given ID, wrapping errdefs.ErrNotFound if there is none
+func (l *Lab) FindTemplate(ctx context.Context, vmID string) (*platform.VMInfo, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if vm, err := l.vm(Node, vmID); err == nil && vm.template {
+		info := vm.info()
+		return &info, nil
+	}
+	return nil, fmt.Errorf("template %s: %w", vmID, errdefs.ErrNotFound)
+}
+
+// NextVMID returns the lowest free VM ID, starting at 100 like Proxmox
+func (l *Lab) NextVMID(ctx context.Context) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	id := 100
+	for l.vms[id] != nil {
+		id++
+	}
+	return strconv.Itoa(id), nil
+}
+
+// CloneVM creates a linked clone of a template in a pool. The clone exists at once but is locked until the task finishes.
+func (l *Lab) CloneVM(ctx context.Context, node string, templateID string, newID string, name string, pool string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.finishTasks()
+
+	template, err := l.vm(node, templateID)
+	if err != nil {
+		return "", fmt.Errorf("failed to clone VM: %w", err)
+	}
+	id, err := strconv.Atoi(newID)
+	if err != nil {
+		return "", fmt.Errorf("failed to clone VM: invalid VM ID %q", newID)
+	}
+	if l.vms[id] != nil {
+		return "", fmt.Errorf("failed to clone VM: VM %s already exists", newID)
+	}
+	if _, ok := l.pools[pool]; pool != "" && !ok {
+		return "", fmt.Errorf("failed to clone VM: pool '%s' does not exist", pool)
+	}
+
+	clone := template.clone(id, name, pool)
+	l.vms[id] = clone
+	return l.startTask(clone, "qmclone", nil), nil
+}
+
+// SetVMNetwork moves the network device of a VM to bridge, tagged with vlan
+func (l *Lab) SetVMNetwork(ctx context.Context, node string, vmID string, bridge string, vlan int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	vm, err := l.vm(node, vmID)
+	if err != nil {
+		return fmt.Errorf("failed to update VM config: %w", err)
+	}
+	vm.bridge = bridge
+	vm.vlan = vlan
+	return nil
+}
+
+// DeleteVM destroys a stopped VM, removing it from its pool
+func (l *Lab) DeleteVM(ctx context.Context, node string, vmID string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.finishTasks()
+
+	vm, err := l.vm(node, vmID)
+	if err != nil {
+		return "", fmt.Errorf("failed to delete VM: %w", err)
+	}
+	if vm.status != "stopped" {
+		return "", fmt.Errorf("failed to delete VM: VM %s is running - destroy failed", vmID)
+	}
+	if vm.template || vm.pool == l.labPool {
+		return "", fmt.Errorf("failed to delete VM: VM %s is part of the simulated lab", vmID)
+	}
+
+	delete(l.vms, vm.id)
+	return l.startTask(vm, "qmdestroy", nil), nil
+}
@@ -0,0 +1,112 @@
+package demo
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/health"
+)
+
+// adDelay is how long Active Directory takes to answer LDAP and DNS after the other services of a DC are up
+const adDelay = 15 * time.Second
+
+// Ports answered by every lab host and additionally by domain controllers, as probed by health.Checker
+var (
+	memberPorts = []int{445, 3389, 5985}
+	dcPorts     = []int{53, 88, 389, 445, 3389, 5985}
+)
+
+// Host returns the configured lab host with the given VM name
+func (l *Lab) Host(name string) (config.LabHost, bool) {
+	for _, host := range l.labHosts {
+		if strings.EqualFold(host.Name, name) {
+			return host, true
+		}
+	}
+	return config.LabHost{}, false
+}
+
+// Check simulates the probes of all lab hosts
+func (l *Lab) Check(ctx context.Context) *health.LabHealth {
+	hosts := make([]health.HostHealth, len(l.labHosts))
+	for i, host := range l.labHosts {
+		hosts[i] = l.CheckHost(ctx, host)
+	}
+	return health.NewLabHealth(hosts)
+}
+
+// CheckHost simulates the probes of a host from the state of the shared lab VM of the same name.
+// The services answer once the VM has booted; LDAP and DNS of a domain controller a while later.
+func (l *Lab) CheckHost(ctx context.Context, host config.LabHost) health.HostHealth {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.finishTasks()
+
+	var target *vm
+	for _, vm := range l.vms {
+		if !vm.template && vm.pool == l.labPool && strings.EqualFold(vm.name, host.Name) {
+			target = vm
+		}
+	}
+	up := target != nil && target.booted(target.bootTime)
+	adUp := target != nil && target.booted(target.bootTime+adDelay)
+
+	ports := memberPorts
+	if host.Domain != "" {
+		ports = dcPorts
+	}
+
+	probes := make([]health.ProbeResult, 0, len(ports)+2)
+	for _, port := range ports {
+		probe := health.ProbeResult{Name: fmt.Sprintf("tcp/%d", port), OK: up, LatencyMS: l.latency(up)}
+		if !up {
+			probe.Detail = l.dialError(target, host, port)
+		}
+		probes = append(probes, probe)
+	}
+
+	if host.Domain != "" {
+		ldap := health.ProbeResult{Name: "ldap", OK: adUp, LatencyMS: l.latency(adUp)}
+		dns := health.ProbeResult{Name: "dns-srv", OK: adUp, LatencyMS: l.latency(adUp)}
+		switch {
+		case adUp:
+			ldap.Detail = "DC=" + strings.ReplaceAll(host.Domain, ".", ",DC=")
+			dns.Detail = target.hostname + "." + host.Domain
+		case up:
+			ldap.Detail = "domain controller is not synchronized"
+			dns.Detail = fmt.Sprintf("lookup _ldap._tcp.dc._msdcs.%s on %s: server misbehaving", host.Domain, address(host, 53))
+		default:
+			ldap.Detail = l.dialError(target, host, 389)
+			dns.Detail = fmt.Sprintf("lookup _ldap._tcp.dc._msdcs.%s on %s: %s", host.Domain, address(host, 53), l.dialError(target, host, 53))
+		}
+		probes = append(probes, ldap, dns)
+	}
+
+	return health.NewHostHealth(host, probes)
+}
+
+// latency returns the simulated round trip of a probe, which fails at once if the host is not up. The caller holds l.mu.
+func (l *Lab) latency(ok bool) int64 {
+	if !ok {
+		return 0
+	}
+	return 1 + l.rand.Int63n(3)
+}
+
+// dialError returns the error of a connection to a host that is not up: refused while it boots, unreachable while it is stopped
+func (l *Lab) dialError(target *vm, host config.LabHost, port int) string {
+	if target != nil && target.status == "running" {
+		return fmt.Sprintf("dial tcp %s: connect: connection refused", address(host, port))
+	}
+	return fmt.Sprintf("dial tcp %s: connect: no route to host", address(host, port))
+}
+
+// address joins the address of a host with a port
+func address(host config.LabHost, port int) string {
+	return net.JoinHostPort(host.Address, strconv.Itoa(port))
+}
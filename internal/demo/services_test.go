@@ -0,0 +1,54 @@
+package demo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/health"
+)
+
+func TestCheckHost(t *testing.T) {
+	lab := newTestLab(t)
+	dc01, ok := lab.Host("dc01")
+	if !ok || dc01.Domain != "sevenkingdoms.local" {
+		t.Fatalf("lab host DC01 %+v, %v", dc01, ok)
+	}
+
+	tests := []struct {
+		name   string
+		status string
+		uptime time.Duration
+		state  string
+		adOnly bool // 只有 LDAP 和 DNS 探测失败
+	}{
+		{"booted", "running", time.Hour, health.StateHealthy, false},
+		{"starting AD", "running", 50 * time.Second, health.StateDegraded, true},
+		{"booting", "running", 10 * time.Second, health.StateDown, false},
+		{"stopped", "stopped", 0, health.StateDown, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lab.vms[101].status = tt.status
+			lab.vms[101].started = time.Now().Add(-tt.uptime)
+
+			result := lab.CheckHost(context.Background(), dc01)
+			if result.State != tt.state || len(result.Probes) != 8 {
+				t.Fatalf("state %s with %d probes, want %s with 8", result.State, len(result.Probes), tt.state)
+			}
+			if !tt.adOnly {
+				return
+			}
+			for _, probe := range result.Probes {
+				want := probe.Name == "ldap" || probe.Name == "dns-srv"
+				if probe.OK == want {
+					t.Errorf("probe %s: ok %v", probe.Name, probe.OK)
+				}
+			}
+		})
+	}
+
+	if lab := lab.Check(context.Background()); lab.State != health.StateDegraded || len(lab.Hosts) != len(hosts) {
+		t.Errorf("lab %s with %d hosts, want degraded with DC01 stopped", lab.State, len(lab.Hosts))
+	}
+}
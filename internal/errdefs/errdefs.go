@@ -0,0 +1,7 @@
+// Package errdefs defines the errors shared by the subsystems of the dashboard, independent of any backend
+package errdefs
+
+import "errors"
+
+// ErrNotFound is wrapped by errors about objects that do not exist, such as an unknown VM, instance or reservation
+var ErrNotFound = errors.New("not found")
@@ -0,0 +1,94 @@
+// Package fake provides in-process fakes of the Proxmox and pfSense APIs the dashboard talks to.
+// Their state can be scripted and faults injected, so that the dashboard can be exercised without a lab.
+package fake
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault makes the requests matching Method and Path misbehave
+type Fault struct {
+	Method    string        // 为空时匹配所有方法
+	Path      string        // path.Match 模式, Proxmox 为 /api2/json 之后的路径, 例如 /nodes/*/qemu/101/snapshot/*/rollback
+	Status    int           // 返回的 HTTP 状态码, 0 表示正常处理
+	Delay     time.Duration // 处理前的延迟, 超过客户端超时即可模拟超时
+	TaskError string        // 仅 Proxmox: 请求成功, 但任务以该退出状态失败
+	Times     int           // 生效次数, 0 表示一直生效
+}
+
+// matches reports whether the fault applies to a request
+func (f *Fault) matches(method string, p string) bool {
+	if f.Method != "" && !strings.EqualFold(f.Method, method) {
+		return false
+	}
+	ok, err := path.Match(f.Path, p)
+	return err == nil && ok
+}
+
+// faults are the faults injected into a fake server and the requests it received
+type faults struct {
+	mu       sync.Mutex
+	list     []*Fault
+	requests []string
+}
+
+// add injects a fault. Faults added later take precedence.
+func (f *faults) add(fault Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.list = append([]*Fault{&fault}, f.list...)
+}
+
+// clear removes every fault
+func (f *faults) clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.list = nil
+}
+
+// take records a request and returns the fault that applies to it, counting it against its Times
+func (f *faults) take(method string, p string) (Fault, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, method+" "+p)
+	for i, fault := range f.list {
+		if !fault.matches(method, p) {
+			continue
+		}
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				f.list = append(f.list[:i:i], f.list[i+1:]...)
+			}
+		}
+		return *fault, true
+	}
+	return Fault{}, false
+}
+
+// received returns the requests received so far as "METHOD /path"
+func (f *faults) received() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.requests...)
+}
+
+// delay waits for the fault's delay and reports false if the client went away first
+func (fault Fault) delay(r *http.Request) bool {
+	if fault.Delay <= 0 {
+		return true
+	}
+	timer := time.NewTimer(fault.Delay)
+	defer timer.Stop()
+	select {
+	case <-r.Context().Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
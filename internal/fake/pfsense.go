@@ -0,0 +1,328 @@
+package fake
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// OpenVPNConnection is a client connected to a fake OpenVPN server
+type OpenVPNConnection struct {
+	ID          int    `json:"id"`
+	CommonName  string `json:"common_name"`
+	ConnectTime int64  `json:"connect_time_unix"`
+}
+
+// Rule is a firewall rule of the fake pfSense
+type Rule struct {
+	ID          int      `json:"id"`
+	Tracker     int      `json:"tracker"`
+	Type        string   `json:"type"`
+	Interface   []string `json:"interface"`
+	Description string   `json:"descr"`
+	Disabled    bool     `json:"disabled"`
+}
+
+// Lease is a DHCP lease of the fake pfSense
+type Lease struct {
+	IP       string
+	MAC      string
+	Hostname string
+}
+
+// Pfsense is a fake of the pfSense REST API v2 endpoints the dashboard uses: OpenVPN servers and their connections,
+// system status, services, gateways, interfaces, DHCP leases, ARP table and firewall rules
+type Pfsense struct {
+	mu          sync.Mutex
+	server      string
+	connections []OpenVPNConnection
+	nextConnID  int
+	leases      []Lease
+	rules       []Rule
+	cpuUsage    float64
+	memUsage    float64
+	services    map[string]bool
+
+	faults  faults
+	handler http.Handler
+}
+
+// NewPfsense creates a healthy fake pfSense with one OpenVPN server and no connections
+func NewPfsense() *Pfsense {
+	p := &Pfsense{
+		server:   "GOAD VPN",
+		cpuUsage: 5,
+		memUsage: 20,
+		services: map[string]bool{"openvpn": true, "unbound": true, "dhcpd": true},
+	}
+
+	r := chi.NewRouter()
+	r.Post("/api/v2/auth/jwt", p.postJWT)
+	r.Get("/api/v2/status/openvpn/servers", p.getOpenVPNServers)
+	r.Get("/api/v2/status/system", p.getSystem)
+	r.Get("/api/v2/status/services", p.getServices)
+	r.Get("/api/v2/status/gateways", p.getGateways)
+	r.Get("/api/v2/status/interfaces", p.getInterfaces)
+	r.Get("/api/v2/status/dhcp_server/leases", p.getLeases)
+	r.Get("/api/v2/diagnostics/arp_table", p.getARPTable)
+	r.Get("/api/v2/firewall/rules", p.getRules)
+	r.Patch("/api/v2/firewall/rule", p.patchRule)
+	r.Post("/api/v2/firewall/apply", p.applyFirewall)
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		writePfsenseError(w, http.StatusNotFound, "Endpoint not found")
+	})
+	p.handler = r
+	return p
+}
+
+// ServeHTTP serves the pfSense REST API
+func (p *Pfsense) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") == "" && r.Header.Get("X-API-Key") == "" {
+		writePfsenseError(w, http.StatusUnauthorized, "Authentication failed")
+		return
+	}
+
+	if fault, ok := p.faults.take(r.Method, r.URL.Path); ok {
+		if !fault.delay(r) {
+			return
+		}
+		if fault.Status != 0 {
+			writePfsenseError(w, fault.Status, fmt.Sprintf("injected fault on %s", r.URL.Path))
+			return
+		}
+	}
+
+	p.handler.ServeHTTP(w, r)
+}
+
+// Connect connects a VPN client and returns its connection ID
+func (p *Pfsense) Connect(commonName string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.nextConnID++
+	p.connections = append(p.connections, OpenVPNConnection{ID: p.nextConnID, CommonName: commonName, ConnectTime: time.Now().Unix()})
+	return p.nextConnID
+}
+
+// Disconnect disconnects every VPN client with the common name
+func (p *Pfsense) Disconnect(commonName string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	connections := []OpenVPNConnection{}
+	for _, conn := range p.connections {
+		if conn.CommonName != commonName {
+			connections = append(connections, conn)
+		}
+	}
+	p.connections = connections
+}
+
+// Connections returns the connected VPN clients
+func (p *Pfsense) Connections() []OpenVPNConnection {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]OpenVPNConnection(nil), p.connections...)
+}
+
+// AddLease adds a DHCP lease, which also appears in the ARP table
+func (p *Pfsense) AddLease(lease Lease) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.leases = append(p.leases, lease)
+}
+
+// AddRule adds a firewall rule on the LAN interface and returns its ID
+func (p *Pfsense) AddRule(description string, disabled bool) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	id := len(p.rules)
+	p.rules = append(p.rules, Rule{ID: id, Tracker: 1000000000 + id, Type: "block", Interface: []string{"lan"}, Description: description, Disabled: disabled})
+	return id
+}
+
+// Rules returns the firewall rules
+func (p *Pfsense) Rules() []Rule {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Rule(nil), p.rules...)
+}
+
+// SetUsage sets the CPU and memory usage percentages reported by the system status
+func (p *Pfsense) SetUsage(cpu float64, mem float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cpuUsage, p.memUsage = cpu, mem
+}
+
+// SetService starts or stops a service
+func (p *Pfsense) SetService(name string, running bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.services[name] = running
+}
+
+// Inject adds a fault. Faults added later take precedence over earlier ones.
+func (p *Pfsense) Inject(fault Fault) {
+	p.faults.add(fault)
+}
+
+// ClearFaults removes every injected fault
+func (p *Pfsense) ClearFaults() {
+	p.faults.clear()
+}
+
+// Requests returns the requests received so far as "METHOD /path"
+func (p *Pfsense) Requests() []string {
+	return p.faults.received()
+}
+
+func (p *Pfsense) postJWT(w http.ResponseWriter, r *http.Request) {
+	// 未签名的 JWT, 客户端只读取 exp
+	claims, _ := json.Marshal(map[string]int64{"exp": time.Now().Add(time.Hour).Unix()})
+	token := "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(claims) + "."
+	writePfsense(w, map[string]string{"token": token})
+}
+
+func (p *Pfsense) getOpenVPNServers(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	connections := append([]OpenVPNConnection{}, p.connections...)
+	writePfsense(w, []map[string]interface{}{{
+		"id":         0,
+		"name":       p.server,
+		"mode":       "server_tls_user",
+		"port":       "1194",
+		"conns":      connections,
+		"routes":     []interface{}{},
+		"mgmt":       "server1",
+		"vpnid":      1,
+		"local_host": "",
+	}})
+}
+
+func (p *Pfsense) getSystem(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	writePfsense(w, map[string]interface{}{
+		"uptime":       "1 Day 02 Hours 03 Minutes 04 Seconds",
+		"cpu_usage":    p.cpuUsage,
+		"cpu_count":    2,
+		"cpu_load_avg": []float64{0.2, 0.15, 0.1},
+		"mem_usage":    p.memUsage,
+		"swap_usage":   0,
+		"disk_usage":   12,
+		"temp_c":       0,
+	})
+}
+
+func (p *Pfsense) getServices(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	services := []map[string]interface{}{}
+	for _, name := range []string{"dhcpd", "openvpn", "unbound"} {
+		services = append(services, map[string]interface{}{"name": name, "description": name, "enabled": true, "status": p.services[name]})
+	}
+	writePfsense(w, services)
+}
+
+func (p *Pfsense) getGateways(w http.ResponseWriter, r *http.Request) {
+	writePfsense(w, []map[string]string{{
+		"name": "WAN_DHCP", "monitorip": "1.1.1.1", "delay": "1.2ms", "loss": "0.0%", "status": "online", "substatus": "none",
+	}})
+}
+
+func (p *Pfsense) getInterfaces(w http.ResponseWriter, r *http.Request) {
+	writePfsense(w, []map[string]interface{}{
+		{"name": "wan", "descr": "WAN", "hwif": "vtnet0", "status": "up", "enable": true, "ipaddr": "192.0.2.2"},
+		{"name": "lan", "descr": "LAB", "hwif": "vtnet1", "status": "up", "enable": true, "ipaddr": "192.168.56.1"},
+	})
+}
+
+func (p *Pfsense) getLeases(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	leases := []map[string]string{}
+	for _, lease := range p.leases {
+		leases = append(leases, map[string]string{
+			"ip": lease.IP, "mac": lease.MAC, "hostname": lease.Hostname, "if": "lan",
+			"active_status": "active", "online_status": "online",
+		})
+	}
+	writePfsense(w, leases)
+}
+
+func (p *Pfsense) getARPTable(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entries := []map[string]string{}
+	for _, lease := range p.leases {
+		entries = append(entries, map[string]string{
+			"ip_address": lease.IP, "mac_address": lease.MAC, "hostname": lease.Hostname, "interface": "vtnet1", "type": "ethernet",
+		})
+	}
+	writePfsense(w, entries)
+}
+
+func (p *Pfsense) getRules(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	writePfsense(w, append([]Rule{}, p.rules...))
+}
+
+func (p *Pfsense) patchRule(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ID       *int  `json:"id"`
+		Disabled *bool `json:"disabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.ID == nil {
+		writePfsenseError(w, http.StatusBadRequest, "Field `id` is required")
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if *body.ID < 0 || *body.ID >= len(p.rules) {
+		writePfsenseError(w, http.StatusNotFound, "Object with ID does not exist")
+		return
+	}
+	rule := &p.rules[*body.ID]
+	if body.Disabled != nil {
+		rule.Disabled = *body.Disabled
+	}
+	writePfsense(w, *rule)
+}
+
+func (p *Pfsense) applyFirewall(w http.ResponseWriter, r *http.Request) {
+	writePfsense(w, map[string]bool{"applied": true})
+}
+
+// writePfsense writes a successful pfSense response envelope
+func writePfsense(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code":        http.StatusOK,
+		"status":      "ok",
+		"response_id": "SUCCESS",
+		"message":     "",
+		"data":        data,
+	})
+}
+
+// writePfsenseError writes a pfSense error envelope, which repeats the status code in its body
+func writePfsenseError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code":        status,
+		"status":      strings.ToLower(http.StatusText(status)),
+		"response_id": "FAKE_ERROR",
+		"message":     message,
+		"data":        []interface{}{},
+	})
+}
@@ -0,0 +1,244 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Pool is a resource pool of the fake Proxmox
+type Pool struct {
+	ID      string
+	Comment string
+}
+
+// AddPool adds a pool, replacing any pool with the same ID
+func (p *Proxmox) AddPool(pool Pool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pools[pool.ID] = &pool
+}
+
+// Pools returns the pools ordered by ID
+func (p *Proxmox) Pools() []Pool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.finishTasks()
+
+	pools := make([]Pool, 0, len(p.pools))
+	for _, pool := range p.pools {
+		pools = append(pools, *pool)
+	}
+	sort.Slice(pools, func(i, j int) bool { return pools[i].ID < pools[j].ID })
+	return pools
+}
+
+func (p *Proxmox) getPools(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pools := []map[string]interface{}{}
+	for _, pool := range p.pools {
+		pools = append(pools, map[string]interface{}{"poolid": pool.ID, "comment": pool.Comment})
+	}
+	writePVE(w, pools)
+}
+
+func (p *Proxmox) createPool(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ID      string `json:"poolid"`
+		Comment string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.ID == "" {
+		writePVEError(w, http.StatusBadRequest, "Parameter verification failed. poolid: property is missing")
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.pools[body.ID]; ok {
+		writePVEError(w, http.StatusInternalServerError, fmt.Sprintf("create pool failed: pool '%s' already exists", body.ID))
+		return
+	}
+	p.pools[body.ID] = &Pool{ID: body.ID, Comment: body.Comment}
+	writePVE(w, nil)
+}
+
+func (p *Proxmox) getPool(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pool, ok := p.pool(w, r)
+	if !ok {
+		return
+	}
+
+	members := []map[string]interface{}{}
+	for _, vm := range p.sortedVMs() {
+		if vm.Pool == pool.ID {
+			members = append(members, map[string]interface{}{"type": "qemu", "vmid": vm.ID, "name": vm.Name, "node": vm.Node, "status": vm.Status})
+		}
+	}
+	writePVE(w, map[string]interface{}{"comment": pool.Comment, "members": members})
+}
+
+func (p *Proxmox) updatePool(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Comment *string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writePVEError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pool, ok := p.pool(w, r)
+	if !ok {
+		return
+	}
+	if body.Comment != nil {
+		pool.Comment = *body.Comment
+	}
+	writePVE(w, nil)
+}
+
+func (p *Proxmox) deletePool(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pool, ok := p.pool(w, r)
+	if !ok {
+		return
+	}
+	for _, vm := range p.vms {
+		if vm.Pool == pool.ID {
+			writePVEError(w, http.StatusInternalServerError, fmt.Sprintf("delete pool failed: pool '%s' is not empty", pool.ID))
+			return
+		}
+	}
+	delete(p.pools, pool.ID)
+	writePVE(w, nil)
+}
+
+// getNextID returns the lowest free VM ID, as Proxmox does
+func (p *Proxmox) getNextID(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	id := 100
+	for p.vms[id] != nil {
+		id++
+	}
+	writePVE(w, id)
+}
+
+// clone creates a linked clone of a template at once. A fault failing the task leaves no clone behind.
+func (p *Proxmox) clone(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		NewID json.Number `json:"newid"`
+		Name  string      `json:"name"`
+		Pool  string      `json:"pool"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writePVEError(w, http.StatusBadRequest, "Parameter verification failed. newid: property is missing")
+		return
+	}
+	newID, err := strconv.Atoi(body.NewID.String())
+	if err != nil || newID < 100 {
+		writePVEError(w, http.StatusBadRequest, "Parameter verification failed. newid: invalid format")
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	template, ok := p.vm(w, r)
+	if !ok {
+		return
+	}
+	switch {
+	case !template.Template:
+		writePVEError(w, http.StatusInternalServerError, "Linked clone feature is not supported for drive 'scsi0'")
+		return
+	case p.vms[newID] != nil:
+		writePVEError(w, http.StatusInternalServerError, fmt.Sprintf("unable to create VM %d: config file already exists", newID))
+		return
+	case body.Pool != "" && p.pools[body.Pool] == nil:
+		writePVEError(w, http.StatusInternalServerError, fmt.Sprintf("pool '%s' does not exist", body.Pool))
+		return
+	}
+
+	if taskError(r.Context()) == "" {
+		vm := *template
+		vm.ID = newID
+		vm.Name = body.Name
+		vm.Pool = body.Pool
+		vm.Template = false
+		vm.Status = "stopped"
+		vm.MAC = fmt.Sprintf("bc:24:11:%02x:%02x:%02x", newID>>16&0xff, newID>>8&0xff, newID&0xff)
+		vm.Snapshots = nil
+		p.vms[newID] = &vm
+	}
+	writePVE(w, p.startTask(r, template, "qmclone", nil))
+}
+
+// updateVMConfig changes the bridge and VLAN tag of net0
+func (p *Proxmox) updateVMConfig(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writePVEError(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	vm, ok := p.vm(w, r)
+	if !ok {
+		return
+	}
+
+	if net, ok := body["net0"].(string); ok {
+		vm.Bridge, vm.VLAN = "", 0
+		for _, option := range strings.Split(net, ",") {
+			key, value, _ := strings.Cut(option, "=")
+			switch key {
+			case "bridge":
+				vm.Bridge = value
+			case "tag":
+				vm.VLAN, _ = strconv.Atoi(value)
+			}
+		}
+	}
+	writePVE(w, nil)
+}
+
+// deleteVM destroys a stopped VM, removing it from its pool
+func (p *Proxmox) deleteVM(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	vm, ok := p.vm(w, r)
+	if !ok {
+		return
+	}
+	if vm.Status != "stopped" {
+		writePVEError(w, http.StatusInternalServerError, fmt.Sprintf("VM %d is running - destroy failed", vm.ID))
+		return
+	}
+
+	writePVE(w, p.startTask(r, vm, "qmdestroy", func() {
+		delete(p.vms, vm.ID)
+	}))
+}
+
+// pool resolves the pool of the request. The caller holds p.mu.
+func (p *Proxmox) pool(w http.ResponseWriter, r *http.Request) (*Pool, bool) {
+	pool, ok := p.pools[chi.URLParam(r, "pool")]
+	if !ok {
+		writePVEError(w, http.StatusInternalServerError, fmt.Sprintf("pool '%s' does not exist", chi.URLParam(r, "pool")))
+		return nil, false
+	}
+	return pool, true
+}
@@ -0,0 +1,634 @@
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// VM is the state of a fake Proxmox VM
+type VM struct {
+	ID        int
+	Name      string
+	Node      string
+	Status    string // running, stopped 或 paused
+	Template  bool
+	CPUs      int
+	CPU       float64 // CPU 使用率 (0-1)
+	MaxMem    int64
+	Mem       int64
+	MaxDisk   int64
+	Uptime    int64         // 运行时间 (秒), 随时间增长
+	Agent     bool          // 是否配置了 QEMU guest agent
+	BootTime  time.Duration // 启动后 guest agent 开始响应所需的时间
+	OS        string        // guest agent 报告的操作系统
+	IP        string        // guest agent 报告的 IP
+	MAC       string        // net0 的 MAC 地址
+	Bridge    string        // net0 的网桥, 为空时为 vmbr1
+	VLAN      int           // net0 的 VLAN 标签, 0 表示不打标签
+	Pool      string        // 所属的资源池
+	Snapshots []Snapshot
+
+	started time.Time
+}
+
+// Snapshot is a snapshot of a fake VM
+type Snapshot struct {
+	Name        string
+	Description string
+	SnapTime    int64
+}
+
+// task is a task started on the fake Proxmox. Its effect is applied once it finishes.
+type task struct {
+	node   string
+	done   time.Time
+	exit   string
+	apply  func()
+	closed bool
+}
+
+// taskErrorKey carries the exit status injected by a fault to the task the request starts
+type taskErrorKey struct{}
+
+func withTaskError(ctx context.Context, exit string) context.Context {
+	return context.WithValue(ctx, taskErrorKey{}, exit)
+}
+
+func taskError(ctx context.Context) string {
+	exit, _ := ctx.Value(taskErrorKey{}).(string)
+	return exit
+}
+
+// Proxmox is a fake of the Proxmox VE /api2/json endpoints the dashboard uses:
+// nodes, storage, VMs and their RRD data, snapshots and rollbacks, power actions, tasks, the guest agent,
+// and the pools, linked clones and VM deletion behind lab instances
+type Proxmox struct {
+	mu           sync.Mutex
+	nodes        []string
+	vms          map[int]*VM
+	pools        map[string]*Pool
+	tasks        map[string]*task
+	taskCount    int
+	taskDuration time.Duration
+
+	faults  faults
+	handler http.Handler
+}
+
+// NewProxmox creates a fake Proxmox with the given nodes and no VMs
+func NewProxmox(nodes ...string) *Proxmox {
+	p := &Proxmox{
+		nodes: nodes,
+		vms:   map[int]*VM{},
+		pools: map[string]*Pool{},
+		tasks: map[string]*task{},
+	}
+
+	r := chi.NewRouter()
+	r.Get("/nodes", p.getNodes)
+	r.Get("/nodes/{node}/status", p.getNodeStatus)
+	r.Get("/nodes/{node}/storage", p.getStorage)
+	r.Get("/nodes/{node}/qemu", p.getVMs)
+	r.Get("/nodes/{node}/qemu/{vmid}/config", p.getVMConfig)
+	r.Put("/nodes/{node}/qemu/{vmid}/config", p.updateVMConfig)
+	r.Post("/nodes/{node}/qemu/{vmid}/clone", p.clone)
+	r.Delete("/nodes/{node}/qemu/{vmid}", p.deleteVM)
+	r.Get("/nodes/{node}/qemu/{vmid}/rrddata", p.getRRDData)
+	r.Get("/nodes/{node}/qemu/{vmid}/snapshot", p.getSnapshots)
+	r.Post("/nodes/{node}/qemu/{vmid}/snapshot/{snapshot}/rollback", p.rollback)
+	r.Post("/nodes/{node}/qemu/{vmid}/status/{action}", p.power)
+	r.Post("/nodes/{node}/qemu/{vmid}/agent/ping", p.agentPing)
+	r.Get("/nodes/{node}/qemu/{vmid}/agent/{command}", p.agentCommand)
+	r.Get("/nodes/{node}/tasks/{upid}/status", p.getTaskStatus)
+	r.Get("/pools", p.getPools)
+	r.Post("/pools", p.createPool)
+	r.Get("/pools/{pool}", p.getPool)
+	r.Put("/pools/{pool}", p.updatePool)
+	r.Delete("/pools/{pool}", p.deletePool)
+	r.Get("/cluster/nextid", p.getNextID)
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		writePVEError(w, http.StatusNotImplemented, fmt.Sprintf("Method '%s %s' not implemented", r.Method, r.URL.Path))
+	})
+	p.handler = http.StripPrefix("/api2/json", r)
+	return p
+}
+
+// ServeHTTP serves the Proxmox API under /api2/json
+func (p *Proxmox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "PVEAPIToken=") {
+		writePVEError(w, http.StatusUnauthorized, "authentication failure")
+		return
+	}
+
+	fault, ok := p.faults.take(r.Method, strings.TrimPrefix(r.URL.Path, "/api2/json"))
+	if ok {
+		if !fault.delay(r) {
+			return
+		}
+		if fault.Status != 0 {
+			writePVEError(w, fault.Status, fmt.Sprintf("injected fault on %s", r.URL.Path))
+			return
+		}
+		if fault.TaskError != "" {
+			r = r.WithContext(withTaskError(r.Context(), fault.TaskError))
+		}
+	}
+
+	p.mu.Lock()
+	p.finishTasks()
+	p.mu.Unlock()
+
+	p.handler.ServeHTTP(w, r)
+}
+
+// AddVM adds a VM, replacing any VM with the same ID
+func (p *Proxmox) AddVM(vm VM) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if vm.Status == "" {
+		vm.Status = "stopped"
+	}
+	if vm.Status == "running" {
+		vm.started = time.Now().Add(-time.Duration(vm.Uptime) * time.Second)
+	}
+	p.vms[vm.ID] = &vm
+}
+
+// VM returns a copy of the current state of a VM
+func (p *Proxmox) VM(id int) (VM, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.finishTasks()
+	vm, ok := p.vms[id]
+	if !ok {
+		return VM{}, false
+	}
+	state := *vm
+	state.Uptime = vm.uptime()
+	return state, true
+}
+
+// UpdateVM changes the state of a VM in place, for example to power it off behind the dashboard's back
+func (p *Proxmox) UpdateVM(id int, fn func(vm *VM)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if vm, ok := p.vms[id]; ok {
+		running := vm.Status == "running"
+		fn(vm)
+		vm.setStatus(vm.Status, running)
+	}
+}
+
+// SetTaskDuration sets how long tasks run before they finish and take effect. The default is 0, tasks finish at once.
+func (p *Proxmox) SetTaskDuration(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.taskDuration = d
+}
+
+// Inject adds a fault. Faults added later take precedence over earlier ones.
+func (p *Proxmox) Inject(fault Fault) {
+	p.faults.add(fault)
+}
+
+// ClearFaults removes every injected fault
+func (p *Proxmox) ClearFaults() {
+	p.faults.clear()
+}
+
+// Requests returns the requests received so far as "METHOD /path", the path relative to /api2/json
+func (p *Proxmox) Requests() []string {
+	return p.faults.received()
+}
+
+func (p *Proxmox) getNodes(w http.ResponseWriter, r *http.Request) {
+	nodes := []map[string]interface{}{}
+	for _, node := range p.nodes {
+		nodes = append(nodes, map[string]interface{}{"node": node, "status": "online", "type": "node"})
+	}
+	writePVE(w, nodes)
+}
+
+func (p *Proxmox) getNodeStatus(w http.ResponseWriter, r *http.Request) {
+	node, ok := p.node(w, r)
+	if !ok {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var cpu float64
+	var cpus int
+	var mem, maxMem int64
+	for _, vm := range p.vms {
+		if vm.Node != node {
+			continue
+		}
+		if vm.Status == "running" {
+			cpu += vm.CPU * float64(vm.CPUs)
+			mem += vm.Mem
+		}
+		cpus += vm.CPUs
+		maxMem += vm.MaxMem
+	}
+	if cpus > 0 {
+		cpu /= float64(cpus)
+	}
+
+	writePVE(w, map[string]interface{}{
+		"cpu":        cpu,
+		"cpuinfo":    map[string]interface{}{"cpus": cpus},
+		"memory":     map[string]interface{}{"used": mem, "total": maxMem},
+		"uptime":     86400,
+		"loadavg":    []string{"0.50", "0.40", "0.30"},
+		"pveversion": "pve-manager/8.2.4/faa83925c9641325",
+		"kversion":   "Linux 6.8.12-1-pve",
+	})
+}
+
+func (p *Proxmox) getStorage(w http.ResponseWriter, r *http.Request) {
+	node, ok := p.node(w, r)
+	if !ok {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var used int64
+	for _, vm := range p.vms {
+		if vm.Node == node {
+			// 每个快照大约增加磁盘大小的十分之一
+			used += vm.MaxDisk / 2 * int64(10+len(vm.Snapshots)) / 10
+		}
+	}
+
+	const total = 1 << 40
+	writePVE(w, []map[string]interface{}{
+		{"storage": "local", "type": "dir", "content": "iso,vztmpl,backup", "shared": 0, "active": 1, "used": int64(total / 10), "total": int64(total / 4), "avail": int64(total/4 - total/10)},
+		{"storage": "local-lvm", "type": "lvmthin", "content": "images,rootdir", "shared": 0, "active": 1, "used": used, "total": int64(total), "avail": total - used},
+	})
+}
+
+// rrdSteps is the sample interval of each RRD timeframe
+var rrdSteps = map[string]time.Duration{
+	"hour": time.Minute,
+	"day":  20 * time.Minute,
+	"week": 3 * time.Hour,
+}
+
+// getRRDData answers 70 samples that wave around the current usage of the VM. Samples from before the VM started are empty.
+func (p *Proxmox) getRRDData(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	vm, ok := p.vm(w, r)
+	if !ok {
+		return
+	}
+	step, ok := rrdSteps[r.URL.Query().Get("timeframe")]
+	if !ok {
+		writePVEError(w, http.StatusBadRequest, "parameter verification failed - timeframe")
+		return
+	}
+
+	now := time.Now().Truncate(step)
+	points := []map[string]interface{}{}
+	for i := 69; i >= 0; i-- {
+		at := now.Add(-time.Duration(i) * step)
+		point := map[string]interface{}{"time": at.Unix()}
+		if vm.Status == "running" && !at.Before(vm.started.Truncate(step)) {
+			wave := 1 + 0.3*math.Sin(float64(at.Unix()/int64(step.Seconds()))/5+float64(vm.ID))
+			point["cpu"] = math.Min(vm.CPU*wave, 1)
+			point["maxcpu"] = vm.CPUs
+			point["mem"] = math.Min(float64(vm.Mem)*(0.9+0.1*wave), float64(vm.MaxMem))
+			point["maxmem"] = vm.MaxMem
+			point["diskread"] = 200000 * wave
+			point["diskwrite"] = 150000 * wave
+			point["netin"] = 20000 * wave
+			point["netout"] = 12000 * wave
+		}
+		points = append(points, point)
+	}
+	writePVE(w, points)
+}
+
+func (p *Proxmox) getVMs(w http.ResponseWriter, r *http.Request) {
+	node, ok := p.node(w, r)
+	if !ok {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	vms := []map[string]interface{}{}
+	for _, vm := range p.sortedVMs() {
+		if vm.Node != node {
+			continue
+		}
+		cpu, mem := 0.0, int64(0)
+		if vm.Status == "running" {
+			cpu, mem = vm.CPU, vm.Mem
+		}
+		template := 0
+		if vm.Template {
+			template = 1
+		}
+		vms = append(vms, map[string]interface{}{
+			"vmid":     vm.ID,
+			"name":     vm.Name,
+			"status":   vm.Status,
+			"cpu":      cpu,
+			"cpus":     vm.CPUs,
+			"mem":      mem,
+			"maxmem":   vm.MaxMem,
+			"disk":     0,
+			"maxdisk":  vm.MaxDisk,
+			"uptime":   vm.uptime(),
+			"template": template,
+		})
+	}
+	writePVE(w, vms)
+}
+
+func (p *Proxmox) getVMConfig(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	vm, ok := p.vm(w, r)
+	if !ok {
+		return
+	}
+
+	vmConfig := map[string]interface{}{"name": vm.Name, "cores": vm.CPUs, "memory": vm.MaxMem >> 20}
+	if vm.MAC != "" {
+		bridge := vm.Bridge
+		if bridge == "" {
+			bridge = "vmbr1"
+		}
+		net := "virtio=" + strings.ToUpper(vm.MAC) + ",bridge=" + bridge
+		if vm.VLAN != 0 {
+			net += fmt.Sprintf(",tag=%d", vm.VLAN)
+		}
+		vmConfig["net0"] = net
+	}
+	if vm.Agent {
+		vmConfig["agent"] = "1"
+	}
+	writePVE(w, vmConfig)
+}
+
+func (p *Proxmox) getSnapshots(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	vm, ok := p.vm(w, r)
+	if !ok {
+		return
+	}
+
+	snapshots := []map[string]interface{}{}
+	for _, snapshot := range vm.Snapshots {
+		snapshots = append(snapshots, map[string]interface{}{
+			"name":        snapshot.Name,
+			"description": snapshot.Description,
+			"snaptime":    snapshot.SnapTime,
+		})
+	}
+	writePVE(w, snapshots)
+}
+
+// rollback restores a snapshot taken without RAM, which leaves the VM stopped
+func (p *Proxmox) rollback(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	vm, ok := p.vm(w, r)
+	if !ok {
+		return
+	}
+
+	name := chi.URLParam(r, "snapshot")
+	found := false
+	for _, snapshot := range vm.Snapshots {
+		found = found || snapshot.Name == name
+	}
+	if !found {
+		writePVEError(w, http.StatusInternalServerError, fmt.Sprintf("snapshot '%s' does not exist", name))
+		return
+	}
+
+	writePVE(w, p.startTask(r, vm, "qmrollback", func() {
+		vm.setStatus("stopped", vm.Status == "running")
+	}))
+}
+
+// power runs a status action: start, stop, shutdown, reset, suspend or resume
+func (p *Proxmox) power(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	vm, ok := p.vm(w, r)
+	if !ok {
+		return
+	}
+
+	var status string
+	switch chi.URLParam(r, "action") {
+	case "start", "resume", "reset":
+		status = "running"
+	case "stop", "shutdown":
+		status = "stopped"
+	case "suspend":
+		status = "paused"
+	default:
+		writePVEError(w, http.StatusNotImplemented, "unknown status action")
+		return
+	}
+
+	writePVE(w, p.startTask(r, vm, "qm"+chi.URLParam(r, "action"), func() {
+		vm.setStatus(status, vm.Status == "running")
+	}))
+}
+
+func (p *Proxmox) agentPing(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	vm, ok := p.agent(w, r)
+	if !ok {
+		return
+	}
+	writePVE(w, map[string]interface{}{"result": map[string]interface{}{}, "vmid": vm.ID})
+}
+
+func (p *Proxmox) agentCommand(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	vm, ok := p.agent(w, r)
+	if !ok {
+		return
+	}
+
+	var result interface{}
+	switch chi.URLParam(r, "command") {
+	case "get-host-name":
+		result = map[string]string{"host-name": strings.ToLower(vm.Name)}
+	case "get-osinfo":
+		result = map[string]string{"pretty-name": vm.OS}
+	case "network-get-interfaces":
+		addresses := []map[string]string{{"ip-address": "127.0.0.1"}}
+		if vm.IP != "" {
+			addresses = append(addresses, map[string]string{"ip-address": vm.IP})
+		}
+		result = []map[string]interface{}{{"name": "Ethernet", "ip-addresses": addresses}}
+	default:
+		writePVEError(w, http.StatusNotImplemented, "unknown agent command")
+		return
+	}
+	writePVE(w, map[string]interface{}{"result": result})
+}
+
+func (p *Proxmox) getTaskStatus(w http.ResponseWriter, r *http.Request) {
+	upid, err := url.PathUnescape(chi.URLParam(r, "upid"))
+	if err != nil {
+		writePVEError(w, http.StatusBadRequest, "invalid upid")
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t, ok := p.tasks[upid]
+	if !ok || t.node != chi.URLParam(r, "node") {
+		writePVEError(w, http.StatusInternalServerError, "no such task")
+		return
+	}
+
+	if !t.closed {
+		writePVE(w, map[string]string{"status": "running", "upid": upid})
+		return
+	}
+	writePVE(w, map[string]string{"status": "stopped", "exitstatus": t.exit, "upid": upid})
+}
+
+// startTask registers a task that applies its effect when it finishes, unless a fault makes it fail, and returns its UPID
+func (p *Proxmox) startTask(r *http.Request, vm *VM, kind string, apply func()) string {
+	p.taskCount++
+	upid := fmt.Sprintf("UPID:%s:%08X:%08X:%08X:%s:%d:root@pam:", vm.Node, 1000+p.taskCount, p.taskCount, time.Now().Unix(), kind, vm.ID)
+
+	t := &task{node: vm.Node, done: time.Now().Add(p.taskDuration), exit: "OK", apply: apply}
+	if exit := taskError(r.Context()); exit != "" {
+		t.exit = exit
+		t.apply = nil
+	}
+	p.tasks[upid] = t
+	p.finishTasks()
+	return upid
+}
+
+// finishTasks applies the tasks whose duration has elapsed. The caller holds p.mu.
+func (p *Proxmox) finishTasks() {
+	now := time.Now()
+	for _, t := range p.tasks {
+		if t.closed || now.Before(t.done) {
+			continue
+		}
+		t.closed = true
+		if t.apply != nil {
+			t.apply()
+		}
+	}
+}
+
+// sortedVMs returns the VMs ordered by ID. The caller holds p.mu.
+func (p *Proxmox) sortedVMs() []*VM {
+	vms := make([]*VM, 0, len(p.vms))
+	for _, vm := range p.vms {
+		vms = append(vms, vm)
+	}
+	sort.Slice(vms, func(i, j int) bool { return vms[i].ID < vms[j].ID })
+	return vms
+}
+
+// node resolves the node of the request
+func (p *Proxmox) node(w http.ResponseWriter, r *http.Request) (string, bool) {
+	node := chi.URLParam(r, "node")
+	for _, n := range p.nodes {
+		if n == node {
+			return node, true
+		}
+	}
+	writePVEError(w, http.StatusInternalServerError, fmt.Sprintf("hostname lookup '%s' failed", node))
+	return "", false
+}
+
+// vm resolves the VM of the request on its node. The caller holds p.mu.
+func (p *Proxmox) vm(w http.ResponseWriter, r *http.Request) (*VM, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "vmid"))
+	if err != nil {
+		writePVEError(w, http.StatusBadRequest, "invalid vmid")
+		return nil, false
+	}
+	vm, ok := p.vms[id]
+	if !ok || vm.Node != chi.URLParam(r, "node") {
+		writePVEError(w, http.StatusInternalServerError, fmt.Sprintf("Configuration file 'nodes/%s/qemu-server/%d.conf' does not exist", chi.URLParam(r, "node"), id))
+		return nil, false
+	}
+	return vm, true
+}
+
+// agent resolves the VM of a guest agent request, failing like Proxmox when the agent cannot answer. The caller holds p.mu.
+func (p *Proxmox) agent(w http.ResponseWriter, r *http.Request) (*VM, bool) {
+	vm, ok := p.vm(w, r)
+	if !ok {
+		return nil, false
+	}
+	switch {
+	case !vm.Agent:
+		writePVEError(w, http.StatusInternalServerError, "No QEMU guest agent configured")
+		return nil, false
+	case vm.Status != "running":
+		writePVEError(w, http.StatusInternalServerError, fmt.Sprintf("VM %d is not running", vm.ID))
+		return nil, false
+	case time.Since(vm.started) < vm.BootTime:
+		writePVEError(w, http.StatusInternalServerError, "QEMU guest agent is not running")
+		return nil, false
+	}
+	return vm, true
+}
+
+// setStatus changes the status of the VM, restarting its uptime when it starts running
+func (vm *VM) setStatus(status string, wasRunning bool) {
+	vm.Status = status
+	switch {
+	case status != "running":
+		vm.started = time.Time{}
+	case !wasRunning:
+		vm.started = time.Now()
+	}
+}
+
+// uptime returns the seconds the VM has been running
+func (vm *VM) uptime() int64 {
+	if vm.Status != "running" || vm.started.IsZero() {
+		return 0
+	}
+	return int64(time.Since(vm.started).Seconds())
+}
+
+// writePVE writes a Proxmox response envelope
+func writePVE(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+// writePVEError writes a Proxmox error, which carries the message in the status line and the body
+func writePVEError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": nil, "message": message})
+}
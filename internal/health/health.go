@@ -0,0 +1,273 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+)
+
+// Summarised health states
+const (
+	StateHealthy  = "healthy"
+	StateDegraded = "degraded"
+	StateDown     = "down"
+)
+
+// Ports probed on every lab host and additionally on domain controllers
+var (
+	memberPorts = []int{445, 3389, 5985}
+	dcPorts     = []int{53, 88, 389, 445, 3389, 5985}
+)
+
+// DialFunc opens a connection, matching net.Dialer.DialContext
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// ProbeResult is the outcome of a single probe
+type ProbeResult struct {
+	Name      string `json:"name"` // 如 tcp/445, ldap, dns-srv
+	OK        bool   `json:"ok"`
+	LatencyMS int64  `json:"latency_ms"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// HostHealth is the service health of a lab host
+type HostHealth struct {
+	Name    string        `json:"name"`
+	Address string        `json:"address"`
+	Domain  string        `json:"domain,omitempty"`
+	State   string        `json:"state"`
+	Probes  []ProbeResult `json:"probes"`
+}
+
+// LabHealth is the service health of all configured lab hosts
+type LabHealth struct {
+	State     string       `json:"state"`
+	CheckedAt int64        `json:"checked_at"`
+	Hosts     []HostHealth `json:"hosts"`
+}
+
+// Prober checks the services of the lab hosts. It is implemented by *Checker and by the simulated lab of the demo mode.
+type Prober interface {
+	// Host returns the configured lab host with the given VM name
+	Host(name string) (config.LabHost, bool)
+	// Check checks all lab hosts
+	Check(ctx context.Context) *LabHealth
+	// CheckHost checks the services of a single host
+	CheckHost(ctx context.Context, host config.LabHost) HostHealth
+}
+
+var _ Prober = (*Checker)(nil)
+
+// NewHostHealth summarises the probes of a host: healthy if all succeeded, down if all failed, degraded otherwise
+func NewHostHealth(host config.LabHost, probes []ProbeResult) HostHealth {
+	failed := 0
+	for _, probe := range probes {
+		if !probe.OK {
+			failed++
+		}
+	}
+
+	state := StateHealthy
+	switch {
+	case failed == len(probes):
+		state = StateDown
+	case failed > 0:
+		state = StateDegraded
+	}
+
+	return HostHealth{
+		Name:    host.Name,
+		Address: host.Address,
+		Domain:  host.Domain,
+		State:   state,
+		Probes:  probes,
+	}
+}
+
+// NewLabHealth summarises the health of the lab hosts: down if all are down, degraded if any is not healthy
+func NewLabHealth(hosts []HostHealth) *LabHealth {
+	lab := &LabHealth{
+		State:     StateHealthy,
+		CheckedAt: time.Now().Unix(),
+		Hosts:     hosts,
+	}
+
+	down := 0
+	for _, host := range hosts {
+		switch host.State {
+		case StateDown:
+			down++
+			lab.State = StateDegraded
+		case StateDegraded:
+			lab.State = StateDegraded
+		}
+	}
+	if len(hosts) > 0 && down == len(hosts) {
+		lab.State = StateDown
+	}
+
+	return lab
+}
+
+// Checker probes the services of the lab hosts
+type Checker struct {
+	Hosts   []config.LabHost
+	Timeout time.Duration
+	// Dial opens every probe connection, including DNS and LDAP. It can be replaced to point probes at fake listeners.
+	Dial DialFunc
+	// Port maps a well-known port to the port actually dialed. It is the identity unless replaced.
+	Port func(port int) int
+}
+
+// NewChecker creates a checker for the lab hosts in the application config
+func NewChecker(config *config.Config) *Checker {
+	dialer := &net.Dialer{}
+	return &Checker{
+		Hosts:   config.GetLabHosts(),
+		Timeout: config.GetLabHealthTimeout(),
+		Dial:    dialer.DialContext,
+		Port:    func(port int) int { return port },
+	}
+}
+
+// Host returns the configured lab host with the given VM name
+func (c *Checker) Host(name string) (config.LabHost, bool) {
+	for _, host := range c.Hosts {
+		if strings.EqualFold(host.Name, name) {
+			return host, true
+		}
+	}
+	return config.LabHost{}, false
+}
+
+// Check probes all lab hosts concurrently
+func (c *Checker) Check(ctx context.Context) *LabHealth {
+	hosts := make([]HostHealth, len(c.Hosts))
+	var wg sync.WaitGroup
+	for i, host := range c.Hosts {
+		wg.Add(1)
+		go func(i int, host config.LabHost) {
+			defer wg.Done()
+			hosts[i] = c.CheckHost(ctx, host)
+		}(i, host)
+	}
+	wg.Wait()
+
+	return NewLabHealth(hosts)
+}
+
+// CheckHost probes the TCP ports of a host and, for domain controllers, LDAP and DNS
+func (c *Checker) CheckHost(ctx context.Context, host config.LabHost) HostHealth {
+	ports := memberPorts
+	if host.Domain != "" {
+		ports = dcPorts
+	}
+
+	probes := make([]func() ProbeResult, 0, len(ports)+2)
+	for _, port := range ports {
+		port := port
+		probes = append(probes, func() ProbeResult { return c.probeTCP(ctx, host, port) })
+	}
+	if host.Domain != "" {
+		probes = append(probes,
+			func() ProbeResult { return c.probeLDAP(ctx, host) },
+			func() ProbeResult { return c.probeDNS(ctx, host) },
+		)
+	}
+
+	results := make([]ProbeResult, len(probes))
+	var wg sync.WaitGroup
+	for i, probe := range probes {
+		wg.Add(1)
+		go func(i int, probe func() ProbeResult) {
+			defer wg.Done()
+			results[i] = probe()
+		}(i, probe)
+	}
+	wg.Wait()
+
+	return NewHostHealth(host, results)
+}
+
+// address joins the host address with the dialed port for a well-known port
+func (c *Checker) address(host config.LabHost, port int) string {
+	return net.JoinHostPort(host.Address, strconv.Itoa(c.Port(port)))
+}
+
+// run times a probe bounded by the probe timeout
+func (c *Checker) run(ctx context.Context, name string, probe func(ctx context.Context) (string, error)) ProbeResult {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	detail, err := probe(ctx)
+	result := ProbeResult{
+		Name:      name,
+		OK:        err == nil,
+		LatencyMS: time.Since(start).Milliseconds(),
+		Detail:    detail,
+	}
+	if err != nil {
+		result.Detail = err.Error()
+	}
+	return result
+}
+
+func (c *Checker) probeTCP(ctx context.Context, host config.LabHost, port int) ProbeResult {
+	return c.run(ctx, fmt.Sprintf("tcp/%d", port), func(ctx context.Context) (string, error) {
+		conn, err := c.Dial(ctx, "tcp", c.address(host, port))
+		if err != nil {
+			return "", err
+		}
+		conn.Close()
+		return "", nil
+	})
+}
+
+// probeLDAP reads the rootDSE and checks that the DC serves a naming context and is synchronized
+func (c *Checker) probeLDAP(ctx context.Context, host config.LabHost) ProbeResult {
+	return c.run(ctx, "ldap", func(ctx context.Context) (string, error) {
+		attributes, err := queryRootDSE(ctx, c.Dial, c.address(host, 389))
+		if err != nil {
+			return "", err
+		}
+		namingContexts := attributes["defaultNamingContext"]
+		if len(namingContexts) == 0 {
+			return "", fmt.Errorf("rootDSE has no defaultNamingContext")
+		}
+		if synced := attributes["isSynchronized"]; len(synced) > 0 && !strings.EqualFold(synced[0], "TRUE") {
+			return "", fmt.Errorf("domain controller is not synchronized")
+		}
+		return namingContexts[0], nil
+	})
+}
+
+// probeDNS asks the DC itself for the _ldap._tcp.dc._msdcs SRV records of its domain
+func (c *Checker) probeDNS(ctx context.Context, host config.LabHost) ProbeResult {
+	return c.run(ctx, "dns-srv", func(ctx context.Context) (string, error) {
+		resolver := &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return c.Dial(ctx, network, c.address(host, 53))
+			},
+		}
+		_, records, err := resolver.LookupSRV(ctx, "ldap", "tcp", "dc._msdcs."+host.Domain)
+		if err != nil {
+			return "", err
+		}
+		if len(records) == 0 {
+			return "", fmt.Errorf("no SRV records for _ldap._tcp.dc._msdcs.%s", host.Domain)
+		}
+		targets := make([]string, len(records))
+		for i, record := range records {
+			targets[i] = strings.TrimSuffix(record.Target, ".")
+		}
+		return strings.Join(targets, ", "), nil
+	})
+}
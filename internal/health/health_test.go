@@ -0,0 +1,150 @@
+package health
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// serveDNS answers SRV queries for the domain controllers of domain over UDP on conn and NXDOMAIN anything else
+func serveDNS(t *testing.T, conn net.PacketConn, domain string, target string) {
+	t.Helper()
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) != 1 {
+				continue
+			}
+			question := query.Questions[0]
+
+			reply := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true},
+				Questions: query.Questions,
+			}
+			if question.Type == dnsmessage.TypeSRV && strings.EqualFold(question.Name.String(), "_ldap._tcp.dc._msdcs."+domain+".") {
+				reply.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeSRV, Class: dnsmessage.ClassINET, TTL: 600},
+					Body:   &dnsmessage.SRVResource{Priority: 0, Weight: 100, Port: 389, Target: dnsmessage.MustNewName(target + ".")},
+				}}
+			} else {
+				reply.RCode = dnsmessage.RCodeNameError
+			}
+			packed, err := reply.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(packed, addr)
+		}
+	}()
+}
+
+// listen accepts and closes TCP connections, standing in for a service port
+func listen(t *testing.T, address string) int {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestCheckHostDomainController(t *testing.T) {
+	dns, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dnsPort := dns.LocalAddr().(*net.UDPAddr).Port
+	serveDNS(t, dns, "sevenkingdoms.local", "kingslanding.sevenkingdoms.local")
+
+	ldapAddress := serveLDAP(t, append(searchEntry(1, map[string]string{
+		"defaultNamingContext": "DC=sevenkingdoms,DC=local",
+		"isSynchronized":       "TRUE",
+	}), searchDone(1, 0)...))
+	_, ldapPort, _ := net.SplitHostPort(ldapAddress)
+
+	ports := map[int]int{53: listen(t, "127.0.0.1:"+strconv.Itoa(dnsPort)), 389: mustAtoi(t, ldapPort)}
+	for _, port := range []int{88, 445, 3389, 5985} {
+		ports[port] = listen(t, "127.0.0.1:0")
+	}
+	unused, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := unused.Addr().(*net.TCPAddr).Port
+	unused.Close()
+
+	checker := &Checker{
+		Timeout: time.Second,
+		Dial:    (&net.Dialer{}).DialContext,
+		Port: func(port int) int {
+			if mapped, ok := ports[port]; ok {
+				return mapped
+			}
+			return closed
+		},
+	}
+
+	host := config.LabHost{Name: "DC01", Address: "127.0.0.1", Domain: "sevenkingdoms.local"}
+	health := checker.CheckHost(context.Background(), host)
+	if health.State != StateHealthy {
+		t.Fatalf("state %s, want healthy: %+v", health.State, health.Probes)
+	}
+	for _, probe := range health.Probes {
+		switch probe.Name {
+		case "ldap":
+			if probe.Detail != "DC=sevenkingdoms,DC=local" {
+				t.Errorf("ldap detail %q", probe.Detail)
+			}
+		case "dns-srv":
+			if probe.Detail != "kingslanding.sevenkingdoms.local" {
+				t.Errorf("dns-srv detail %q", probe.Detail)
+			}
+		}
+	}
+
+	// Another domain has no SRV records on this DC, and nothing listens on WinRM
+	delete(ports, 5985)
+	host.Domain = "essos.local"
+	health = checker.CheckHost(context.Background(), host)
+	if health.State != StateDegraded {
+		t.Errorf("state %s, want degraded: %+v", health.State, health.Probes)
+	}
+	for _, probe := range health.Probes {
+		if (probe.Name == "dns-srv" || probe.Name == "tcp/5985") && probe.OK {
+			t.Errorf("%s passed, want it to fail", probe.Name)
+		}
+	}
+}
+
+func mustAtoi(t *testing.T, s string) int {
+	t.Helper()
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
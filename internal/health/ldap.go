@@ -0,0 +1,190 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// BER tags used by the rootDSE search
+const (
+	berSequence         = 0x30
+	berSet              = 0x31
+	berInteger          = 0x02
+	berOctetString      = 0x04
+	berBoolean          = 0x01
+	berEnumerated       = 0x0a
+	ldapSearchRequest   = 0x63 // [APPLICATION 3]
+	ldapSearchResEntry  = 0x64 // [APPLICATION 4]
+	ldapSearchResDone   = 0x65 // [APPLICATION 5]
+	ldapFilterPresent   = 0x87 // [7] present
+	ldapMaxMessageBytes = 1 << 20
+)
+
+// rootDSEAttributes are requested from the rootDSE of a domain controller
+var rootDSEAttributes = []string{"defaultNamingContext", "dnsHostName", "isSynchronized"}
+
+// queryRootDSE performs an anonymous base search of the rootDSE and returns its attributes
+func queryRootDSE(ctx context.Context, dial DialFunc, address string) (map[string][]string, error) {
+	conn, err := dial(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+	}
+
+	if _, err := conn.Write(rootDSERequest(1)); err != nil {
+		return nil, fmt.Errorf("failed to send search request: %w", err)
+	}
+
+	attributes := map[string][]string{}
+	for {
+		tag, message, err := readTLV(conn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read search response: %w", err)
+		}
+		if tag != berSequence {
+			return nil, fmt.Errorf("unexpected LDAP message tag 0x%02x", tag)
+		}
+
+		r := bytes.NewReader(message)
+		if _, _, err := readTLV(r); err != nil { // messageID
+			return nil, err
+		}
+		opTag, op, err := readTLV(r)
+		if err != nil {
+			return nil, err
+		}
+
+		switch opTag {
+		case ldapSearchResEntry:
+			if err := parseSearchEntry(op, attributes); err != nil {
+				return nil, err
+			}
+		case ldapSearchResDone:
+			_, code, err := readTLV(bytes.NewReader(op))
+			if err != nil {
+				return nil, err
+			}
+			if len(code) != 1 || code[0] != 0 {
+				return nil, fmt.Errorf("search failed with result code %v", code)
+			}
+			return attributes, nil
+		default:
+			return nil, fmt.Errorf("unexpected LDAP operation tag 0x%02x", opTag)
+		}
+	}
+}
+
+// rootDSERequest encodes a SearchRequest for base "" with filter (objectClass=*)
+func rootDSERequest(messageID byte) []byte {
+	var attrs []byte
+	for _, attr := range rootDSEAttributes {
+		attrs = append(attrs, tlv(berOctetString, []byte(attr))...)
+	}
+
+	search := bytes.Join([][]byte{
+		tlv(berOctetString, nil),                      // baseObject
+		tlv(berEnumerated, []byte{0}),                 // scope: baseObject
+		tlv(berEnumerated, []byte{0}),                 // derefAliases: never
+		tlv(berInteger, []byte{0}),                    // sizeLimit
+		tlv(berInteger, []byte{0}),                    // timeLimit
+		tlv(berBoolean, []byte{0}),                    // typesOnly
+		tlv(ldapFilterPresent, []byte("objectClass")), // filter
+		tlv(berSequence, attrs),                       // attributes
+	}, nil)
+
+	return tlv(berSequence, append(tlv(berInteger, []byte{messageID}), tlv(ldapSearchRequest, search)...))
+}
+
+// parseSearchEntry collects the attributes of a SearchResultEntry
+func parseSearchEntry(entry []byte, attributes map[string][]string) error {
+	r := bytes.NewReader(entry)
+	if _, _, err := readTLV(r); err != nil { // objectName
+		return err
+	}
+	_, list, err := readTLV(r)
+	if err != nil {
+		return err
+	}
+
+	lr := bytes.NewReader(list)
+	for lr.Len() > 0 {
+		_, attr, err := readTLV(lr)
+		if err != nil {
+			return err
+		}
+		ar := bytes.NewReader(attr)
+		_, name, err := readTLV(ar)
+		if err != nil {
+			return err
+		}
+		_, values, err := readTLV(ar)
+		if err != nil {
+			return err
+		}
+		vr := bytes.NewReader(values)
+		for vr.Len() > 0 {
+			_, value, err := readTLV(vr)
+			if err != nil {
+				return err
+			}
+			attributes[string(name)] = append(attributes[string(name)], string(value))
+		}
+	}
+	return nil
+}
+
+// tlv encodes a BER tag-length-value
+func tlv(tag byte, value []byte) []byte {
+	out := []byte{tag}
+	switch n := len(value); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xff:
+		out = append(out, 0x81, byte(n))
+	default:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	}
+	return append(out, value...)
+}
+
+// readTLV reads a BER tag-length-value with a single byte tag
+func readTLV(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	tag, length := header[0], int(header[1])
+	if length&0x80 != 0 {
+		size := length & 0x7f
+		if size == 0 || size > 4 {
+			return 0, nil, fmt.Errorf("unsupported BER length encoding")
+		}
+		raw := make([]byte, size)
+		if _, err := io.ReadFull(r, raw); err != nil {
+			return 0, nil, err
+		}
+		length = 0
+		for _, b := range raw {
+			length = length<<8 | int(b)
+		}
+	}
+	if length > ldapMaxMessageBytes {
+		return 0, nil, fmt.Errorf("LDAP message too large")
+	}
+
+	value := make([]byte, length)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return 0, nil, err
+	}
+	return tag, value, nil
+}
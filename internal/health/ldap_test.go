@@ -0,0 +1,139 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveLDAP answers every search request with reply and closes the connection
+func serveLDAP(t *testing.T, reply []byte) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if _, _, err := readTLV(conn); err != nil {
+					return
+				}
+				conn.Write(reply)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// searchEntry encodes a SearchResultEntry message with the given attributes
+func searchEntry(messageID byte, attributes map[string]string) []byte {
+	var list []byte
+	for name, value := range attributes {
+		list = append(list, tlv(berSequence, append(tlv(berOctetString, []byte(name)), tlv(berSet, tlv(berOctetString, []byte(value)))...))...)
+	}
+	entry := append(tlv(berOctetString, nil), tlv(berSequence, list)...)
+	return tlv(berSequence, append(tlv(berInteger, []byte{messageID}), tlv(ldapSearchResEntry, entry)...))
+}
+
+// searchDone encodes a SearchResultDone message with the given result code
+func searchDone(messageID byte, code byte) []byte {
+	done := bytes.Join([][]byte{tlv(berEnumerated, []byte{code}), tlv(berOctetString, nil), tlv(berOctetString, nil)}, nil)
+	return tlv(berSequence, append(tlv(berInteger, []byte{messageID}), tlv(ldapSearchResDone, done)...))
+}
+
+func TestQueryRootDSE(t *testing.T) {
+	// A long DNS host name makes the entry use the two byte long form length
+	hostName := strings.Repeat("a", 300) + ".sevenkingdoms.local"
+	reply := append(searchEntry(1, map[string]string{
+		"defaultNamingContext": "DC=sevenkingdoms,DC=local",
+		"dnsHostName":          hostName,
+		"isSynchronized":       "TRUE",
+	}), searchDone(1, 0)...)
+	address := serveLDAP(t, reply)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	attributes, err := queryRootDSE(ctx, (&net.Dialer{}).DialContext, address)
+	if err != nil {
+		t.Fatalf("queryRootDSE: %v", err)
+	}
+	if got := attributes["defaultNamingContext"]; len(got) != 1 || got[0] != "DC=sevenkingdoms,DC=local" {
+		t.Errorf("defaultNamingContext %v", got)
+	}
+	if got := attributes["dnsHostName"]; len(got) != 1 || got[0] != hostName {
+		t.Errorf("dnsHostName of %d values, want the long host name", len(got))
+	}
+}
+
+func TestQueryRootDSEFailures(t *testing.T) {
+	entry := searchEntry(1, map[string]string{"defaultNamingContext": "DC=north,DC=sevenkingdoms,DC=local"})
+	tests := []struct {
+		name  string
+		reply []byte
+	}{
+		{"truncated entry", entry[:len(entry)-5]},
+		{"truncated length", entry[:1]},
+		{"no search done", entry},
+		{"search failed", searchDone(1, 49)},
+		{"unexpected operation", tlv(berSequence, append(tlv(berInteger, []byte{1}), tlv(0x61, nil)...))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := serveLDAP(t, tt.reply)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			if _, err := queryRootDSE(ctx, (&net.Dialer{}).DialContext, address); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestReadTLV(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []byte
+		length  int
+		wantErr bool
+	}{
+		{"short form", []byte{berOctetString, 0x02, 'o', 'k'}, 2, false},
+		{"one byte long form", append([]byte{berOctetString, 0x81, 0x90}, make([]byte, 0x90)...), 0x90, false},
+		{"two byte long form", append([]byte{berOctetString, 0x82, 0x01, 0x2c}, make([]byte, 300)...), 300, false},
+		{"four byte long form", append([]byte{berOctetString, 0x84, 0, 0, 0x01, 0x00}, make([]byte, 256)...), 256, false},
+		{"indefinite length", []byte{berSequence, 0x80, 0, 0}, 0, true},
+		{"length of five bytes", []byte{berOctetString, 0x85, 0, 0, 0, 0, 1, 'x'}, 0, true},
+		{"too large", []byte{berOctetString, 0x84, 0x7f, 0xff, 0xff, 0xff}, 0, true},
+		{"truncated long length", []byte{berOctetString, 0x82, 0x01}, 0, true},
+		{"truncated value", []byte{berOctetString, 0x05, 'a', 'b'}, 0, true},
+		{"empty", nil, 0, true},
+	}
+	for _, tt := range tests {
+		_, value, err := readTLV(bytes.NewReader(tt.input))
+		if (err != nil) != tt.wantErr || len(value) != tt.length {
+			t.Errorf("%s: got %d bytes and error %v, want %d bytes and error %v", tt.name, len(value), err, tt.length, tt.wantErr)
+		}
+	}
+}
+
+func TestTLVRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 0x7f, 0x80, 0xff, 0x100, 0xffff} {
+		value := bytes.Repeat([]byte{'x'}, n)
+		tag, got, err := readTLV(bytes.NewReader(tlv(berOctetString, value)))
+		if err != nil || tag != berOctetString || !bytes.Equal(got, value) {
+			t.Errorf("%d bytes: got tag 0x%02x, %d bytes, error %v", n, tag, len(got), err)
+		}
+	}
+}
@@ -0,0 +1,536 @@
+package instance
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/errdefs"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+)
+
+// Instance states
+const (
+	StateProvisioning = "provisioning"
+	StateReady        = "ready"
+	StateFailed       = "failed"
+	StateDeleting     = "deleting"
+)
+
+// reapInterval is how often expired instances are looked for
+const reapInterval = time.Minute
+
+var (
+	// ErrNotConfigured is returned when INSTANCE_TEMPLATES is not set
+	ErrNotConfigured = errors.New("lab instances are not configured")
+	// ErrLimitReached is returned when a user already holds INSTANCE_MAX_PER_USER instances
+	ErrLimitReached = errors.New("instance limit reached, delete an instance first")
+	// ErrNoVLAN is returned when every VLAN in INSTANCE_VLANS is taken
+	ErrNoVLAN = errors.New("no free VLAN left for a new instance")
+	// ErrBusy is returned when an instance is still being provisioned or torn down
+	ErrBusy = errors.New("instance is being provisioned or torn down, try again later")
+)
+
+// Instance is a private copy of the lab, made of linked clones of the templates in a dedicated pool and VLAN
+type Instance struct {
+	ID        string            `json:"id"`
+	Pool      string            `json:"pool"`
+	Owner     string            `json:"owner"`
+	State     string            `json:"state"`
+	VLAN      int               `json:"vlan"`
+	CreatedAt int64             `json:"created_at"`
+	ExpiresAt int64             `json:"expires_at"`
+	Error     string            `json:"error,omitempty"`
+	VMs       []platform.VMInfo `json:"vms"`
+}
+
+// record is the instance metadata kept as JSON in the pool comment, so that instances survive restarts
+type record struct {
+	Owner     string `json:"owner"`
+	State     string `json:"state"`
+	VLAN      int    `json:"vlan"`
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt int64  `json:"expires_at"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Manager provisions, tracks and tears down lab instances
+type Manager struct {
+	hypervisor platform.Hypervisor
+	templates  []string
+	prefix     string
+	bridge     string
+	vlanFirst  int
+	vlanLast   int
+	ttl        time.Duration
+	maxPerUser int
+
+	mu   sync.Mutex      // 串行化创建, 避免重复分配 VLAN
+	busy map[string]bool // 正在创建或删除的实例
+
+	cloneMu sync.Mutex // 串行化 VMID 分配与克隆
+
+	running sync.WaitGroup // 后台创建与删除任务
+}
+
+// NewManager creates an instance manager using the application config
+func NewManager(config *config.Config, hypervisor platform.Hypervisor) *Manager {
+	first, last := config.GetInstanceVLANs()
+	return &Manager{
+		hypervisor: hypervisor,
+		templates:  config.GetInstanceTemplates(),
+		prefix:     config.GetInstancePoolPrefix(),
+		bridge:     config.GetInstanceBridge(),
+		vlanFirst:  first,
+		vlanLast:   last,
+		ttl:        config.GetInstanceTTL(),
+		maxPerUser: config.GetInstanceMaxPerUser(),
+		busy:       map[string]bool{},
+	}
+}
+
+// Enabled reports whether instances are configured
+func (m *Manager) Enabled() bool {
+	return len(m.templates) > 0
+}
+
+// List returns every instance, oldest first
+func (m *Manager) List(ctx context.Context) ([]Instance, error) {
+	if !m.Enabled() {
+		return nil, ErrNotConfigured
+	}
+
+	records, err := m.records(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := []Instance{}
+	for id, rec := range records {
+		instance, err := m.instance(ctx, id, rec)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, *instance)
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].CreatedAt < instances[j].CreatedAt })
+
+	return instances, nil
+}
+
+// Get returns an instance, wrapping errdefs.ErrNotFound if there is none
+func (m *Manager) Get(ctx context.Context, id string) (*Instance, error) {
+	if !m.Enabled() {
+		return nil, ErrNotConfigured
+	}
+
+	records, err := m.records(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rec, ok := records[id]
+	if !ok {
+		return nil, fmt.Errorf("instance %s: %w", id, errdefs.ErrNotFound)
+	}
+	return m.instance(ctx, id, rec)
+}
+
+// FindVM returns the instance holding a VM and the VM itself, wrapping errdefs.ErrNotFound if no instance has it
+func (m *Manager) FindVM(ctx context.Context, vmID string) (*Instance, *platform.VMInfo, error) {
+	instances, err := m.List(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range instances {
+		for j := range instances[i].VMs {
+			if instances[i].VMs[j].ID == vmID {
+				return &instances[i], &instances[i].VMs[j], nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("VM %s: %w", vmID, errdefs.ErrNotFound)
+}
+
+// Create reserves a pool and VLAN for owner and clones the templates into it in the background.
+// When limited, owner may not hold more than INSTANCE_MAX_PER_USER instances.
+func (m *Manager) Create(ctx context.Context, owner string, limited bool) (*Instance, error) {
+	if !m.Enabled() {
+		return nil, ErrNotConfigured
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	records, err := m.records(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	held := 0
+	taken := map[int]bool{}
+	for _, rec := range records {
+		taken[rec.VLAN] = true
+		if rec.Owner == owner && rec.State != StateDeleting {
+			held++
+		}
+	}
+	if limited && held >= m.maxPerUser {
+		return nil, ErrLimitReached
+	}
+
+	vlan := 0
+	for tag := m.vlanFirst; tag <= m.vlanLast; tag++ {
+		if !taken[tag] {
+			vlan = tag
+			break
+		}
+	}
+	if vlan == 0 {
+		return nil, ErrNoVLAN
+	}
+
+	// Regenerated on the unlikely collision, which Proxmox would only report as a failure to create the pool
+	var id string
+	for {
+		id, err = newInstanceID(owner)
+		if err != nil {
+			return nil, err
+		}
+		if _, taken := records[id]; !taken {
+			break
+		}
+	}
+
+	now := time.Now()
+	rec := record{
+		Owner:     owner,
+		State:     StateProvisioning,
+		VLAN:      vlan,
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(m.ttl).Unix(),
+	}
+	comment, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.hypervisor.CreatePool(ctx, m.prefix+id, string(comment)); err != nil {
+		return nil, err
+	}
+
+	m.busy[id] = true
+	m.background(func() { m.provision(context.WithoutCancel(ctx), id, rec) })
+
+	return &Instance{
+		ID:        id,
+		Pool:      m.prefix + id,
+		Owner:     rec.Owner,
+		State:     rec.State,
+		VLAN:      rec.VLAN,
+		CreatedAt: rec.CreatedAt,
+		ExpiresAt: rec.ExpiresAt,
+		VMs:       []platform.VMInfo{},
+	}, nil
+}
+
+// Delete tears an instance down in the background
+func (m *Manager) Delete(ctx context.Context, id string) (*Instance, error) {
+	instance, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if m.busy[id] {
+		m.mu.Unlock()
+		return nil, ErrBusy
+	}
+	m.busy[id] = true
+	m.mu.Unlock()
+
+	rec := recordOf(instance)
+	rec.State = StateDeleting
+	if err := m.save(ctx, id, rec); err != nil {
+		m.done(id)
+		return nil, err
+	}
+	instance.State = StateDeleting
+
+	m.background(func() { m.teardown(context.WithoutCancel(ctx), id) })
+
+	return instance, nil
+}
+
+// Run tears down expired instances, and retries failed teardowns, until ctx is cancelled
+func (m *Manager) Run(ctx context.Context) {
+	if !m.Enabled() {
+		return
+	}
+
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.reap(ctx)
+		}
+	}
+}
+
+// reap deletes the instances past their expiry
+func (m *Manager) reap(ctx context.Context) {
+	records, err := m.records(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to list instances", "error", err)
+		return
+	}
+
+	now := time.Now().Unix()
+	for id, rec := range records {
+		if rec.State != StateDeleting && rec.ExpiresAt > now {
+			continue
+		}
+
+		m.mu.Lock()
+		busy := m.busy[id]
+		m.busy[id] = true
+		m.mu.Unlock()
+		if busy {
+			continue
+		}
+
+		if rec.State != StateDeleting {
+			slog.InfoContext(ctx, "instance expired, tearing it down", "instance", id, "owner", rec.Owner)
+			rec.State = StateDeleting
+			if err := m.save(ctx, id, rec); err != nil {
+				slog.WarnContext(ctx, "failed to mark instance as deleting", "instance", id, "error", err)
+				m.done(id)
+				continue
+			}
+		}
+		m.background(func() { m.teardown(context.WithoutCancel(ctx), id) })
+	}
+}
+
+// background runs fn in a goroutine tracked by Wait
+func (m *Manager) background(fn func()) {
+	m.running.Add(1)
+	go func() {
+		defer m.running.Done()
+		fn()
+	}()
+}
+
+// Wait blocks until the instances being provisioned or torn down are done
+func (m *Manager) Wait() {
+	m.running.Wait()
+}
+
+// provision clones every template into the instance pool, moves their NICs to the instance VLAN and starts them
+func (m *Manager) provision(ctx context.Context, id string, rec record) {
+	defer m.done(id)
+
+	pool := m.prefix + id
+	err := func() error {
+		for _, templateID := range m.templates {
+			template, err := m.hypervisor.FindTemplate(ctx, templateID)
+			if err != nil {
+				return err
+			}
+
+			vmID, err := m.clone(ctx, template, id, pool)
+			if err != nil {
+				return err
+			}
+
+			if err := m.hypervisor.SetVMNetwork(ctx, template.Node, vmID, m.bridge, rec.VLAN); err != nil {
+				return err
+			}
+
+			upid, err := m.hypervisor.StartVM(ctx, template.Node, vmID)
+			if err == nil {
+				err = m.hypervisor.WaitForTask(ctx, template.Node, upid)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+
+	rec.State = StateReady
+	if err != nil {
+		slog.WarnContext(ctx, "provisioning instance failed", "instance", id, "error", err)
+		rec.State = StateFailed
+		rec.Error = "provisioning failed, delete the instance and try again"
+	} else {
+		slog.InfoContext(ctx, "instance is ready", "instance", id, "owner", rec.Owner, "vlan", rec.VLAN)
+	}
+	if err := m.save(ctx, id, rec); err != nil {
+		slog.WarnContext(ctx, "failed to save instance state", "instance", id, "error", err)
+	}
+}
+
+// clone creates a linked clone of a template and waits for it
+func (m *Manager) clone(ctx context.Context, template *platform.VMInfo, id string, pool string) (string, error) {
+	// The VM ID is only taken once the clone task has started
+	m.cloneMu.Lock()
+	vmID, err := m.hypervisor.NextVMID(ctx)
+	var upid string
+	if err == nil {
+		upid, err = m.hypervisor.CloneVM(ctx, template.Node, template.ID, vmID, fmt.Sprintf("%s-%s", template.Name, id), pool)
+	}
+	m.cloneMu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	if err := m.hypervisor.WaitForTask(ctx, template.Node, upid); err != nil {
+		return "", err
+	}
+	return vmID, nil
+}
+
+// teardown stops and destroys the VMs of an instance and deletes its pool
+func (m *Manager) teardown(ctx context.Context, id string) {
+	defer m.done(id)
+
+	pool := m.prefix + id
+	vms, err := m.hypervisor.GetPoolVMs(ctx, pool)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to list instance VMs", "instance", id, "error", err)
+		return
+	}
+
+	for _, vm := range vms {
+		if vm.Status == "running" {
+			upid, err := m.hypervisor.StopVM(ctx, vm.Node, vm.ID)
+			if err == nil {
+				err = m.hypervisor.WaitForTask(ctx, vm.Node, upid)
+			}
+			if err != nil {
+				slog.WarnContext(ctx, "failed to stop instance VM", "instance", id, "vmid", vm.ID, "error", err)
+				return
+			}
+		}
+
+		upid, err := m.hypervisor.DeleteVM(ctx, vm.Node, vm.ID)
+		if err == nil {
+			err = m.hypervisor.WaitForTask(ctx, vm.Node, upid)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "failed to delete instance VM", "instance", id, "vmid", vm.ID, "error", err)
+			return
+		}
+	}
+
+	if err := m.hypervisor.DeletePool(ctx, pool); err != nil {
+		slog.WarnContext(ctx, "failed to delete instance pool", "instance", id, "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "instance torn down", "instance", id)
+}
+
+// records reads the instance metadata from the pool comments
+func (m *Manager) records(ctx context.Context) (map[string]record, error) {
+	pools, err := m.hypervisor.GetPools(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	records := map[string]record{}
+	for _, pool := range pools {
+		if !strings.HasPrefix(pool.ID, m.prefix) {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal([]byte(pool.Comment), &rec); err != nil || rec.Owner == "" {
+			// Pools sharing the prefix but not created by the dashboard are left alone
+			continue
+		}
+		records[strings.TrimPrefix(pool.ID, m.prefix)] = rec
+	}
+	return records, nil
+}
+
+// instance combines a record with the current VMs of the pool
+func (m *Manager) instance(ctx context.Context, id string, rec record) (*Instance, error) {
+	vms, err := m.hypervisor.GetPoolVMs(ctx, m.prefix+id)
+	if err != nil {
+		return nil, err
+	}
+	if vms == nil {
+		vms = []platform.VMInfo{}
+	}
+
+	return &Instance{
+		ID:        id,
+		Pool:      m.prefix + id,
+		Owner:     rec.Owner,
+		State:     rec.State,
+		VLAN:      rec.VLAN,
+		CreatedAt: rec.CreatedAt,
+		ExpiresAt: rec.ExpiresAt,
+		Error:     rec.Error,
+		VMs:       vms,
+	}, nil
+}
+
+// save writes the record of an instance to its pool comment
+func (m *Manager) save(ctx context.Context, id string, rec record) error {
+	comment, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return m.hypervisor.SetPoolComment(ctx, m.prefix+id, string(comment))
+}
+
+// done marks an instance as no longer being provisioned or torn down
+func (m *Manager) done(id string) {
+	m.mu.Lock()
+	delete(m.busy, id)
+	m.mu.Unlock()
+}
+
+// recordOf returns the metadata of an instance
+func recordOf(instance *Instance) record {
+	return record{
+		Owner:     instance.Owner,
+		State:     instance.State,
+		VLAN:      instance.VLAN,
+		CreatedAt: instance.CreatedAt,
+		ExpiresAt: instance.ExpiresAt,
+		Error:     instance.Error,
+	}
+}
+
+// unsafeID matches the characters not allowed in pool names
+var unsafeID = regexp.MustCompile(`[^A-Za-z0-9-]+`)
+
+// newInstanceID returns an ID such as "alice-3f2a9c01" that is also valid in pool and VM names
+func newInstanceID(owner string) (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	name := strings.ToLower(unsafeID.ReplaceAllString(owner, ""))
+	if len(name) > 16 {
+		name = name[:16]
+	}
+	if name == "" {
+		name = "lab"
+	}
+	return name + "-" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,185 @@
+package instance
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/fake"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform/proxmox"
+)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// newTestManager returns a manager cloning the templates DC01 (9001) and SRV02 (9002) of a fake Proxmox
+// into instances on VLANs 100 and 101
+func newTestManager(t *testing.T) (*Manager, *fake.Proxmox) {
+	t.Helper()
+
+	pve := fake.NewProxmox("pve1")
+	pve.AddVM(fake.VM{ID: 9001, Name: "DC01", Node: "pve1", Template: true, CPUs: 2, MaxMem: 4 << 30, Mem: 2 << 30, MAC: "bc:24:11:00:90:01"})
+	pve.AddVM(fake.VM{ID: 9002, Name: "SRV02", Node: "pve1", Template: true, CPUs: 2, MaxMem: 4 << 30, Mem: 2 << 30, MAC: "bc:24:11:00:90:02"})
+	server := httptest.NewServer(pve)
+	t.Cleanup(server.Close)
+
+	for name, value := range map[string]string{
+		"PROXMOX_URL":            server.URL,
+		"PROXMOX_USERNAME":       "dashboard",
+		"PROXMOX_REALM":          "pve",
+		"PROXMOX_API_TOKEN_NAME": "test",
+		"PROXMOX_API_TOKEN":      "secret",
+		"PROXMOX_POOL":           "goad",
+		"PFSENSE_URL":            "http://127.0.0.1:1",
+		"PFSENSE_USERNAME":       "admin",
+		"PFSENSE_PASSWORD":       "pfsense",
+		"UPSTREAM_RETRIES":       "0",
+		"INSTANCE_TEMPLATES":     "9001,9002",
+		"INSTANCE_BRIDGE":        "vmbr2",
+		"INSTANCE_VLANS":         "100-101",
+	} {
+		t.Setenv(name, value)
+	}
+	config, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("failed to load configuration: %v", err)
+	}
+	client, err := proxmox.NewPVEClientFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(config, client)
+	t.Cleanup(m.Wait)
+	return m, pve
+}
+
+func TestCreate(t *testing.T) {
+	m, pve := newTestManager(t)
+	ctx := context.Background()
+
+	instance, err := m.Create(ctx, "alice", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instance.State != StateProvisioning || instance.VLAN != 100 || !strings.HasPrefix(instance.ID, "alice-") {
+		t.Errorf("new instance %+v, want alice's provisioning on VLAN 100", instance)
+	}
+	m.Wait()
+
+	instance, err = m.Get(ctx, instance.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instance.State != StateReady || len(instance.VMs) != 2 {
+		t.Fatalf("instance %s with %d VMs: %s, want ready with 2", instance.State, len(instance.VMs), instance.Error)
+	}
+	for i, name := range []string{"DC01", "SRV02"} {
+		vm := instance.VMs[i]
+		if vm.Name != name+"-"+instance.ID || vm.Status != "running" {
+			t.Errorf("VM %+v, want a running clone of %s", vm, name)
+		}
+	}
+	if vm, _ := pve.VM(100); vm.Bridge != "vmbr2" || vm.VLAN != 100 {
+		t.Errorf("clone on %s VLAN %d, want vmbr2 VLAN 100", vm.Bridge, vm.VLAN)
+	}
+
+	if instance, err := m.Create(ctx, "bob", true); err != nil || instance.VLAN != 101 {
+		t.Fatalf("second instance %+v, %v, want VLAN 101", instance, err)
+	}
+	if _, err := m.Create(ctx, "carol", true); !errors.Is(err, ErrNoVLAN) {
+		t.Errorf("third instance: got %v, want %v", err, ErrNoVLAN)
+	}
+}
+
+func TestCreateLimit(t *testing.T) {
+	m, _ := newTestManager(t)
+	ctx := context.Background()
+
+	if _, err := m.Create(ctx, "alice", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Create(ctx, "alice", true); !errors.Is(err, ErrLimitReached) {
+		t.Errorf("second instance of a student: got %v, want %v", err, ErrLimitReached)
+	}
+	// Admins are not limited
+	if _, err := m.Create(ctx, "alice", false); err != nil {
+		t.Errorf("unlimited instance: %v", err)
+	}
+}
+
+func TestFailedClone(t *testing.T) {
+	m, pve := newTestManager(t)
+	ctx := context.Background()
+	pve.Inject(fake.Fault{Method: "POST", Path: "/nodes/*/qemu/9002/clone", TaskError: "clone failed: storage is full", Times: 1})
+
+	instance, err := m.Create(ctx, "alice", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Wait()
+
+	instance, err = m.Get(ctx, instance.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instance.State != StateFailed || instance.Error == "" || len(instance.VMs) != 1 {
+		t.Fatalf("instance %s with %d VMs: %q, want failed after the first clone", instance.State, len(instance.VMs), instance.Error)
+	}
+
+	if _, err := m.Delete(ctx, instance.ID); err != nil {
+		t.Fatal(err)
+	}
+	m.Wait()
+
+	if pools := pve.Pools(); len(pools) != 0 {
+		t.Errorf("pools %+v left after the teardown", pools)
+	}
+	if _, ok := pve.VM(100); ok {
+		t.Error("the clone of DC01 was not destroyed")
+	}
+	// The VLAN of the deleted instance is free again
+	if instance, err := m.Create(ctx, "alice", true); err != nil || instance.VLAN != 100 {
+		t.Errorf("instance after the teardown %+v, %v, want VLAN 100", instance, err)
+	}
+}
+
+func TestReap(t *testing.T) {
+	m, pve := newTestManager(t)
+	ctx := context.Background()
+
+	m.ttl = -time.Minute
+	expired, err := m.Create(ctx, "alice", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ttl = time.Hour
+	kept, err := m.Create(ctx, "bob", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Wait()
+
+	m.reap(ctx)
+	m.Wait()
+
+	pools := pve.Pools()
+	if len(pools) != 1 || pools[0].ID != kept.Pool {
+		t.Errorf("pools %+v after reaping, want only %s", pools, kept.Pool)
+	}
+	if _, err := m.Get(ctx, expired.ID); err == nil {
+		t.Errorf("expired instance %s still exists", expired.ID)
+	}
+	if instance, err := m.Get(ctx, kept.ID); err != nil || instance.State != StateReady || len(instance.VMs) != 2 {
+		t.Errorf("instance %+v, %v, want bob's kept ready", instance, err)
+	}
+}
@@ -0,0 +1,267 @@
+package lab
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job kinds
+const (
+	JobReset    = "reset"
+	JobStart    = "start"
+	JobShutdown = "shutdown"
+)
+
+// Job states
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobDegraded  = "degraded"
+	JobFailed    = "failed"
+)
+
+// Power outcomes reported in VMResult.Power
+const (
+	PowerStarted   = "started"
+	PowerShutdown  = "shutdown"
+	PowerStopped   = "stopped" // ACPI 关机失败后强制停止
+	PowerUnchanged = "unchanged"
+	PowerFailed    = "failed"
+)
+
+// maxJobs is the number of jobs kept in memory
+const maxJobs = 20
+
+// ErrJobInProgress is returned when a job is requested while another one is running
+var ErrJobInProgress = errors.New("another lab job is already in progress")
+
+// VMResult is the outcome of a job for a single VM
+type VMResult struct {
+	VMID     string `json:"vmid"`
+	Name     string `json:"name"`
+	Group    string `json:"group"`
+	Rollback string `json:"rollback,omitempty"` // ok 或 failed, 仅限重置任务
+	Power    string `json:"power,omitempty"`
+	Agent    string `json:"agent,omitempty"`    // 最终的 guest agent 状态
+	Services string `json:"services,omitempty"` // 服务健康状态, 仅限配置在 LAB_HOSTS 中的主机
+	Detail   string `json:"detail,omitempty"`
+}
+
+// Job tracks a long running lab operation such as a reset
+type Job struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind"`
+	Status     string     `json:"status"`
+	Step       string     `json:"step"`
+	StartedAt  int64      `json:"started_at"`
+	FinishedAt int64      `json:"finished_at,omitempty"`
+	Message    string     `json:"message,omitempty"`
+	VMs        []VMResult `json:"vms"`
+}
+
+// JobManager runs lab jobs one at a time and keeps the recent ones
+type JobManager struct {
+	mu       sync.Mutex
+	jobs     []*Job // 按开始时间排序, 最新的在最后
+	onFinish []func(job Job)
+	running  sync.WaitGroup
+	timeout  time.Duration // 超时的任务会被取消并标记为失败
+	blocked  bool          // Exclusive 正在执行, 不允许启动任务
+	released time.Time     // 最后一次 Exclusive 结束的时间
+}
+
+// NewJobManager creates an empty job manager whose jobs are cancelled after timeout
+func NewJobManager(timeout time.Duration) *JobManager {
+	return &JobManager{timeout: timeout}
+}
+
+// Timeout returns how long a job may run before it is cancelled
+func (m *JobManager) Timeout() time.Duration {
+	return m.timeout
+}
+
+// start registers a job and runs fn in the background. fn reports progress through the tracker.
+// The job runs with ctx, so it must not be tied to the lifetime of an HTTP request,
+// bounded by the timeout so that a task stuck upstream cannot block later jobs.
+func (m *JobManager) start(ctx context.Context, kind string, fn func(ctx context.Context, t *tracker) (string, string)) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.busy() {
+		return nil, ErrJobInProgress
+	}
+
+	job := &Job{
+		ID:        newJobID(),
+		Kind:      kind,
+		Status:    JobRunning,
+		StartedAt: time.Now().Unix(),
+		VMs:       []VMResult{},
+	}
+	m.jobs = append(m.jobs, job)
+	if len(m.jobs) > maxJobs {
+		m.jobs = m.jobs[len(m.jobs)-maxJobs:]
+	}
+
+	m.running.Add(1)
+	go func() {
+		defer m.running.Done()
+		ctx, cancel := context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+
+		t := &tracker{manager: m, job: job}
+		status, message := fn(ctx, t)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			status = JobFailed
+			message = strings.TrimSuffix(fmt.Sprintf("timed out after %s; %s", m.timeout, message), "; ")
+		}
+		t.update(func(job *Job) {
+			job.Status = status
+			job.Step = "done"
+			job.Message = message
+			job.FinishedAt = time.Now().Unix()
+		})
+		slog.InfoContext(ctx, "lab job finished", "kind", kind, "job_id", job.ID, "status", status, "message", message)
+
+		m.mu.Lock()
+		finished, listeners := *job.copy(), m.onFinish
+		m.mu.Unlock()
+		for _, fn := range listeners {
+			fn(finished)
+		}
+	}()
+
+	return job.copy(), nil
+}
+
+// Exclusive runs fn unless a job is running and keeps jobs from starting until fn returns.
+// Power operations outside of jobs use it so that they cannot interfere with a reset.
+func (m *JobManager) Exclusive(fn func() error) error {
+	m.mu.Lock()
+	if m.busy() {
+		m.mu.Unlock()
+		return ErrJobInProgress
+	}
+	m.blocked = true
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		m.blocked = false
+		m.released = time.Now()
+		m.mu.Unlock()
+	}()
+	return fn()
+}
+
+// BusySince reports whether a job or an exclusive operation was running at any time since t.
+// The notifications use it to tell planned power changes from unexpected ones.
+func (m *JobManager) BusySince(t time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.busy() || !m.released.Before(t) {
+		return true
+	}
+	for _, job := range m.jobs {
+		if job.FinishedAt >= t.Unix() {
+			return true
+		}
+	}
+	return false
+}
+
+// busy reports whether a job or an exclusive operation is running. The caller must hold m.mu.
+func (m *JobManager) busy() bool {
+	return m.blocked || (len(m.jobs) > 0 && m.jobs[len(m.jobs)-1].Status == JobRunning)
+}
+
+// OnFinish registers fn to be called with every job once it has finished
+func (m *JobManager) OnFinish(fn func(job Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onFinish = append(m.onFinish, fn)
+}
+
+// Wait blocks until the running job has finished
+func (m *JobManager) Wait() {
+	m.running.Wait()
+}
+
+// Jobs returns snapshots of the recent jobs, newest first
+func (m *JobManager) Jobs() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]Job, 0, len(m.jobs))
+	for i := len(m.jobs) - 1; i >= 0; i-- {
+		jobs = append(jobs, *m.jobs[i].copy())
+	}
+	return jobs
+}
+
+// Get returns a snapshot of the job with the given ID
+func (m *JobManager) Get(id string) (*Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, job := range m.jobs {
+		if job.ID == id {
+			return job.copy(), true
+		}
+	}
+	return nil, false
+}
+
+// tracker lets a running job update its state under the manager lock
+type tracker struct {
+	manager *JobManager
+	job     *Job
+}
+
+func (t *tracker) update(fn func(job *Job)) {
+	t.manager.mu.Lock()
+	defer t.manager.mu.Unlock()
+	fn(t.job)
+}
+
+func (t *tracker) step(step string) {
+	t.update(func(job *Job) { job.Step = step })
+}
+
+// vm updates the result of the VM with the given ID
+func (t *tracker) vm(vmID string, fn func(vm *VMResult)) {
+	t.update(func(job *Job) {
+		for i := range job.VMs {
+			if job.VMs[i].VMID == vmID {
+				fn(&job.VMs[i])
+			}
+		}
+	})
+}
+
+// results returns a copy of the VM results
+func (t *tracker) results() []VMResult {
+	t.manager.mu.Lock()
+	defer t.manager.mu.Unlock()
+	return append([]VMResult(nil), t.job.VMs...)
+}
+
+func (j *Job) copy() *Job {
+	c := *j
+	c.VMs = append([]VMResult(nil), j.VMs...)
+	return &c
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
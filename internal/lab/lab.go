@@ -0,0 +1,322 @@
+package lab
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/health"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+)
+
+// readyPollInterval is how often guest agents and services are polled while waiting for VMs to come up
+const readyPollInterval = 5 * time.Second
+
+// defaultGroup collects the VMs not listed in any boot group. It starts last and shuts down first.
+const defaultGroup = "others"
+
+// Lab orchestrates the lab VMs: starting and shutting them down in boot groups, resetting them and checking readiness
+type Lab struct {
+	hypervisor      platform.Hypervisor
+	checker         health.Prober
+	groups          []config.BootGroup
+	readyTimeout    time.Duration
+	shutdownTimeout time.Duration
+	jobs            *JobManager
+}
+
+// bootStep is a boot group resolved to the VMs it contains
+type bootStep struct {
+	group config.BootGroup
+	vms   []platform.VMInfo
+}
+
+// NewLab creates a lab orchestrator using the application config
+func NewLab(config *config.Config, hypervisor platform.Hypervisor, checker health.Prober) *Lab {
+	return &Lab{
+		hypervisor:      hypervisor,
+		checker:         checker,
+		groups:          config.GetLabBootGroups(),
+		readyTimeout:    config.GetLabReadyTimeout(),
+		shutdownTimeout: config.GetLabShutdownTimeout(),
+		jobs:            NewJobManager(config.GetLabJobTimeout()),
+	}
+}
+
+// Jobs returns the manager tracking the lab jobs
+func (l *Lab) Jobs() *JobManager {
+	return l.jobs
+}
+
+// StartAll starts a job that starts the stopped VMs group by group
+func (l *Lab) StartAll(ctx context.Context) (*Job, error) {
+	return l.jobs.start(ctx, JobStart, func(ctx context.Context, t *tracker) (string, string) {
+		steps, err := l.plan(ctx, t)
+		if err != nil {
+			return JobFailed, err.Error()
+		}
+		l.startGroups(ctx, t, steps)
+		return summarizePower(t.results())
+	})
+}
+
+// ShutdownOptions control how a shutdown job powers VMs off
+type ShutdownOptions struct {
+	Timeout   time.Duration // 等待 ACPI 关机的时间, 0 表示使用 LAB_SHUTDOWN_TIMEOUT
+	ForceStop bool          // 超时后强制停止
+}
+
+// ShutdownAll starts a job that shuts the running VMs down group by group in reverse boot order
+func (l *Lab) ShutdownAll(ctx context.Context, opts ShutdownOptions) (*Job, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = l.shutdownTimeout
+	}
+
+	return l.jobs.start(ctx, JobShutdown, func(ctx context.Context, t *tracker) (string, string) {
+		steps, err := l.plan(ctx, t)
+		if err != nil {
+			return JobFailed, err.Error()
+		}
+		l.shutdownGroups(ctx, t, steps, opts)
+		return summarizePower(t.results())
+	})
+}
+
+// plan lists the VMs, resolves the boot groups and registers the VMs in the job
+func (l *Lab) plan(ctx context.Context, t *tracker) ([]bootStep, error) {
+	t.step("plan")
+
+	vms, err := l.hypervisor.GetVMs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := l.bootSteps(vms)
+
+	results := []VMResult{}
+	for _, step := range steps {
+		for _, vm := range step.vms {
+			results = append(results, VMResult{VMID: vm.ID, Name: vm.Name, Group: step.group.Name})
+		}
+	}
+	t.update(func(job *Job) { job.VMs = results })
+
+	return steps, nil
+}
+
+// bootSteps assigns the VMs to the configured boot groups, in boot order
+func (l *Lab) bootSteps(vms []platform.VMInfo) []bootStep {
+	used := make([]bool, len(vms))
+	var steps []bootStep
+
+	for _, group := range l.groups {
+		step := bootStep{group: group}
+		for _, name := range group.VMs {
+			for i, vm := range vms {
+				if !used[i] && strings.EqualFold(vm.Name, name) {
+					step.vms = append(step.vms, vm)
+					used[i] = true
+				}
+			}
+		}
+		steps = append(steps, step)
+	}
+
+	rest := bootStep{group: config.BootGroup{Name: defaultGroup}}
+	for i, vm := range vms {
+		if !used[i] {
+			rest.vms = append(rest.vms, vm)
+		}
+	}
+	if len(rest.vms) > 0 {
+		steps = append(steps, rest)
+	}
+
+	return steps
+}
+
+// startGroups starts the stopped VMs of each group concurrently, then waits as configured before the next group
+func (l *Lab) startGroups(ctx context.Context, t *tracker, steps []bootStep) {
+	for _, step := range steps {
+		t.step("start " + step.group.Name)
+
+		started := 0
+		var mu sync.Mutex
+		forEach(step.vms, func(vm platform.VMInfo) {
+			if vm.Status == "running" {
+				t.vm(vm.ID, func(r *VMResult) { r.Power = PowerUnchanged })
+				return
+			}
+
+			upid, err := l.hypervisor.StartVM(ctx, vm.Node, vm.ID)
+			if err == nil {
+				err = l.hypervisor.WaitForTask(ctx, vm.Node, upid)
+			}
+			t.vm(vm.ID, func(r *VMResult) {
+				if err != nil {
+					r.Power = PowerFailed
+					r.Detail = fmt.Sprintf("failed to start: %v", err)
+					return
+				}
+				r.Power = PowerStarted
+			})
+			if err == nil {
+				mu.Lock()
+				started++
+				mu.Unlock()
+			}
+		})
+
+		switch {
+		case step.group.WaitReady:
+			t.step("wait for " + step.group.Name)
+			l.waitReady(ctx, t, step.vms)
+		case step.group.Delay > 0 && started > 0:
+			t.step("wait for " + step.group.Name)
+			sleep(ctx, step.group.Delay)
+		}
+	}
+}
+
+// shutdownGroups shuts the running VMs of each group down concurrently, in reverse boot order.
+// With ForceStop, a VM that does not power off through ACPI within the timeout is stopped.
+func (l *Lab) shutdownGroups(ctx context.Context, t *tracker, steps []bootStep, opts ShutdownOptions) {
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := steps[i]
+		t.step("shutdown " + step.group.Name)
+
+		forEach(step.vms, func(vm platform.VMInfo) {
+			if vm.Status != "running" {
+				t.vm(vm.ID, func(r *VMResult) { r.Power = PowerUnchanged })
+				return
+			}
+
+			power, detail := l.shutdownVM(ctx, vm, opts)
+			t.vm(vm.ID, func(r *VMResult) {
+				r.Power = power
+				r.Detail = detail
+			})
+		})
+	}
+}
+
+// shutdownVM shuts a VM down gracefully and, if allowed, falls back to stopping it
+func (l *Lab) shutdownVM(ctx context.Context, vm platform.VMInfo, opts ShutdownOptions) (string, string) {
+	upid, err := l.hypervisor.ShutdownVM(ctx, vm.Node, vm.ID, opts.Timeout, false)
+	if err == nil {
+		err = l.hypervisor.WaitForTask(ctx, vm.Node, upid)
+	}
+	if err == nil {
+		return PowerShutdown, ""
+	}
+	if !opts.ForceStop {
+		return PowerFailed, fmt.Sprintf("shutdown failed: %v", err)
+	}
+
+	shutdownErr := err
+	upid, err = l.hypervisor.StopVM(ctx, vm.Node, vm.ID)
+	if err == nil {
+		err = l.hypervisor.WaitForTask(ctx, vm.Node, upid)
+	}
+	if err != nil {
+		return PowerFailed, fmt.Sprintf("shutdown failed: %v; stop failed: %v", shutdownErr, err)
+	}
+	return PowerStopped, fmt.Sprintf("stopped after shutdown failed: %v", shutdownErr)
+}
+
+// waitReady waits, bounded by the ready timeout, until the guest agents answer and the service probes pass
+func (l *Lab) waitReady(ctx context.Context, t *tracker, vms []platform.VMInfo) {
+	ctx, cancel := context.WithTimeout(ctx, l.readyTimeout)
+	defer cancel()
+
+	forEach(vms, func(vm platform.VMInfo) {
+		agent, services := l.waitVMReady(ctx, vm)
+		t.vm(vm.ID, func(r *VMResult) {
+			r.Agent = agent
+			r.Services = services
+		})
+	})
+}
+
+// waitVMReady polls the guest agent and, for configured lab hosts, the service probes of a VM
+func (l *Lab) waitVMReady(ctx context.Context, vm platform.VMInfo) (string, string) {
+	var agent string
+	for {
+		agent = l.hypervisor.PingAgent(ctx, vm.Node, vm.ID)
+		if agent == platform.AgentStatusOK || agent == platform.AgentStatusNotConfigured || !sleep(ctx, readyPollInterval) {
+			break
+		}
+	}
+
+	host, ok := l.checker.Host(vm.Name)
+	if !ok {
+		return agent, ""
+	}
+
+	var services string
+	for {
+		services = l.checker.CheckHost(ctx, host).State
+		if services == health.StateHealthy || !sleep(ctx, readyPollInterval) {
+			break
+		}
+	}
+
+	return agent, services
+}
+
+// ready reports whether a VM result shows a working guest and services
+func (r VMResult) ready() bool {
+	agentOK := r.Agent == platform.AgentStatusOK || r.Agent == platform.AgentStatusNotConfigured
+	return agentOK && (r.Services == "" || r.Services == health.StateHealthy)
+}
+
+// summarizePower derives the status of a start or shutdown job from the VM results
+func summarizePower(vms []VMResult) (string, string) {
+	var failed, notReady []string
+	for _, vm := range vms {
+		switch {
+		case vm.Power == PowerFailed:
+			failed = append(failed, vm.Name)
+		case vm.Agent != "" && !vm.ready():
+			notReady = append(notReady, vm.Name)
+		}
+	}
+
+	switch {
+	case len(vms) > 0 && len(failed) == len(vms):
+		return JobFailed, "failed for all VMs"
+	case len(failed) > 0:
+		return JobDegraded, "failed for " + strings.Join(failed, ", ")
+	case len(notReady) > 0:
+		return JobDegraded, "not ready: " + strings.Join(notReady, ", ")
+	}
+	return JobSucceeded, ""
+}
+
+// forEach runs fn for every VM concurrently and waits for all of them
+func forEach(vms []platform.VMInfo, fn func(vm platform.VMInfo)) {
+	var wg sync.WaitGroup
+	for _, vm := range vms {
+		wg.Add(1)
+		go func(vm platform.VMInfo) {
+			defer wg.Done()
+			fn(vm)
+		}(vm)
+	}
+	wg.Wait()
+}
+
+// sleep waits for d and reports false if ctx ended first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
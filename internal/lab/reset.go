@@ -0,0 +1,72 @@
+package lab
+
+import (
+	"context"
+	"strings"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+)
+
+// Reset starts a job that rolls every VM back to its latest snapshot, starts the VMs left stopped in boot order
+// and waits for guest agents and service probes to pass
+func (l *Lab) Reset(ctx context.Context) (*Job, error) {
+	return l.jobs.start(ctx, JobReset, func(ctx context.Context, t *tracker) (string, string) {
+		t.step("rollback")
+		rollbacks, err := l.hypervisor.ResetLab(ctx)
+		if err != nil {
+			return JobFailed, err.Error()
+		}
+
+		// Rolling back to a snapshot without RAM leaves the VM stopped, so the state is read again
+		steps, err := l.plan(ctx, t)
+		if err != nil {
+			return JobFailed, err.Error()
+		}
+
+		for _, rollback := range rollbacks {
+			rollback := rollback
+			t.vm(rollback.VMID, func(r *VMResult) {
+				r.Rollback = "ok"
+				if !rollback.Success {
+					r.Rollback = "failed"
+					r.Detail = rollback.Message
+				}
+			})
+		}
+
+		l.startGroups(ctx, t, steps)
+
+		// Groups without a readiness wait are verified once everything has been started
+		t.step("verify")
+		var pending []platform.VMInfo
+		for _, step := range steps {
+			if !step.group.WaitReady {
+				pending = append(pending, step.vms...)
+			}
+		}
+		l.waitReady(ctx, t, pending)
+
+		return summarizeReset(t.results())
+	})
+}
+
+// summarizeReset derives the status of a reset job: failed when a rollback failed, degraded when the lab did not fully come back
+func summarizeReset(vms []VMResult) (string, string) {
+	var failed, degraded []string
+	for _, vm := range vms {
+		switch {
+		case vm.Rollback != "ok":
+			failed = append(failed, vm.Name)
+		case vm.Power == PowerFailed || !vm.ready():
+			degraded = append(degraded, vm.Name)
+		}
+	}
+
+	switch {
+	case len(failed) > 0:
+		return JobFailed, "rollback failed for " + strings.Join(failed, ", ")
+	case len(degraded) > 0:
+		return JobDegraded, "not healthy after reset: " + strings.Join(degraded, ", ")
+	}
+	return JobSucceeded, ""
+}
@@ -0,0 +1,159 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Redacted replaces secrets in logs
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute key fragments whose values are always redacted
+var sensitiveKeys = []string{"password", "token", "secret", "authorization", "apikey", "api_key", "cookie", "ticket", "signature"}
+
+// sensitiveValues match secrets embedded in messages and errors, such as an echoed Authorization header
+var sensitiveValues = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`PVEAPIToken=[^\s"',]+`), "PVEAPIToken=" + Redacted},
+	{regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`), "${1} " + Redacted},
+	{regexp.MustCompile(`(?i)(\b(?:password|passwd|token|secret|api[_-]?key|ticket)(?:=|"\s*:\s*)"?)[^\s"',&]+`), "${1}" + Redacted},
+	{regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`), "${1}" + Redacted + "@"},
+}
+
+// New creates a logger writing to w at the configured level and format.
+// Records logged with a request context carry its request ID, and secrets are redacted.
+func New(config *config.Config, w io.Writer) *slog.Logger {
+	options := &slog.HandlerOptions{
+		Level:       parseLevel(config.GetLogLevel()),
+		ReplaceAttr: redactAttr,
+	}
+	return slog.New(contextHandler{newHandler(config.GetLogFormat(), w, options)})
+}
+
+// newHandler creates the handler of a LOG_FORMAT
+func newHandler(format string, w io.Writer, options *slog.HandlerOptions) slog.Handler {
+	if format == config.LogText {
+		return slog.NewTextHandler(w, options)
+	}
+	return slog.NewJSONHandler(w, options)
+}
+
+// parseLevel maps LOG_LEVEL to a slog level
+func parseLevel(level string) slog.Level {
+	switch level {
+	case config.LogDebug:
+		return slog.LevelDebug
+	case config.LogWarn:
+		return slog.LevelWarn
+	case config.LogError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Redact masks the secrets embedded in s
+func Redact(s string) string {
+	for _, v := range sensitiveValues {
+		s = v.pattern.ReplaceAllString(s, v.replacement)
+	}
+	return s
+}
+
+// redactAttr masks attributes named like secrets and secrets embedded in strings and errors
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(a.Key, Redacted)
+		}
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, Redact(err.Error()))
+		}
+	}
+	return a
+}
+
+// contextHandler adds the request ID of the record's context and masks the secrets embedded in its message
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	// Scrubbed here so that the message does not depend on the handler passing it to ReplaceAttr
+	r.Message = Redact(r.Message)
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// RequestID returns the ID of the request ctx belongs to, or an empty string.
+// Background jobs started by a request keep its ID.
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	return middleware.GetReqID(ctx)
+}
+
+// Middleware logs every request once it has been served, and returns its ID in the X-Request-Id header.
+// Probes are logged at debug level to keep them out of the way.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := RequestID(r.Context()); id != "" {
+			w.Header().Set(middleware.RequestIDHeader, id)
+		}
+
+		started := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			level := slog.LevelInfo
+			switch {
+			case status >= 500:
+				level = slog.LevelError
+			case r.URL.Path == "/healthz" || r.URL.Path == "/readyz":
+				level = slog.LevelDebug
+			}
+			slog.Log(r.Context(), level, "request served",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", status,
+				"bytes", ww.BytesWritten(),
+				"duration_ms", time.Since(started).Milliseconds(),
+				"remote", r.RemoteAddr,
+			)
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
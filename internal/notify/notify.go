@@ -0,0 +1,253 @@
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	EventResetSucceeded  = "reset.succeeded"
+	EventResetDegraded   = "reset.degraded"
+	EventResetFailed     = "reset.failed"
+	EventVMStarted       = "vm.started"
+	EventVMStopped       = "vm.stopped"
+	EventVMStatusChanged = "vm.status_changed"
+	EventVMDown          = "vm.down"
+	EventVPNConnected    = "vpn.connected"
+	EventVPNDisconnected = "vpn.disconnected"
+	EventPfsenseHealthy  = "pfsense.healthy"
+	EventPfsenseDegraded = "pfsense.degraded"
+	EventPfsenseDown     = "pfsense.down"
+	EventMaintenance     = "maintenance.scheduled"
+	EventTest            = "test"
+)
+
+// Event severities
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Delivery states
+const (
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// queueSize is the number of events waiting for delivery before new ones are dropped
+const queueSize = 100
+
+// maxDeliveries is the number of deliveries kept in the log
+const maxDeliveries = 200
+
+// retryBackoff is the delay before the first retry of a failed delivery, doubled on every attempt
+const retryBackoff = 2 * time.Second
+
+// Event is something that happened in the lab
+type Event struct {
+	ID       string                 `json:"id"`
+	Type     string                 `json:"type"`
+	Time     int64                  `json:"time"` // Unix 时间戳
+	Severity string                 `json:"severity"`
+	Summary  string                 `json:"summary"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+}
+
+// Notifier delivers events to an outside system
+type Notifier interface {
+	// Name identifies the notifier in the delivery log without revealing secrets
+	Name() string
+	// Accepts reports whether the notifier subscribes to an event type
+	Accepts(eventType string) bool
+	// Send delivers an event once
+	Send(ctx context.Context, event Event) error
+}
+
+// Delivery is an entry of the delivery log
+type Delivery struct {
+	EventID   string `json:"event_id"`
+	EventType string `json:"event_type"`
+	Notifier  string `json:"notifier"`
+	Status    string `json:"status"`
+	Attempts  int    `json:"attempts"`
+	Error     string `json:"error,omitempty"`
+	Time      int64  `json:"time"` // 最后一次尝试的 Unix 时间戳
+}
+
+// Hub queues events and delivers them to every subscribed notifier, retrying failed deliveries
+type Hub struct {
+	notifiers []subscriber
+	backoff   time.Duration
+	queue     chan Event
+
+	mu         sync.Mutex
+	deliveries []Delivery // 最新的在最后
+}
+
+// subscriber is a notifier with the number of times its failed deliveries are retried
+type subscriber struct {
+	Notifier
+	retries int
+}
+
+// NewHub creates a hub without notifiers
+func NewHub() *Hub {
+	return &Hub{
+		backoff: retryBackoff,
+		queue:   make(chan Event, queueSize),
+	}
+}
+
+// Add registers a notifier whose failed deliveries are retried the given number of times. It must be called before Run.
+func (h *Hub) Add(n Notifier, retries int) {
+	h.notifiers = append(h.notifiers, subscriber{Notifier: n, retries: retries})
+}
+
+// Enabled reports whether any notifiers are registered
+func (h *Hub) Enabled() bool {
+	return len(h.notifiers) > 0
+}
+
+// Publish queues an event for delivery. Events are dropped when the queue is full.
+func (h *Hub) Publish(event Event) Event {
+	if !h.Enabled() {
+		return event
+	}
+	if event.ID == "" {
+		event.ID = newEventID()
+	}
+	if event.Time == 0 {
+		event.Time = time.Now().Unix()
+	}
+	if event.Severity == "" {
+		event.Severity = SeverityInfo
+	}
+
+	select {
+	case h.queue <- event:
+	default:
+		slog.Warn("notification queue is full, dropping event", "event_type", event.Type, "event_id", event.ID)
+	}
+	return event
+}
+
+// Run delivers queued events until ctx is cancelled. It then sends the events still queued
+// once, without retrying, and waits for the deliveries in flight.
+func (h *Hub) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case event := <-h.queue:
+					h.dispatch(ctx, &wg, event)
+				default:
+					return
+				}
+			}
+		case event := <-h.queue:
+			h.dispatch(ctx, &wg, event)
+		}
+	}
+}
+
+// dispatch delivers an event to every subscribed notifier in the background
+func (h *Hub) dispatch(ctx context.Context, wg *sync.WaitGroup, event Event) {
+	for _, s := range h.notifiers {
+		if !s.Accepts(event.Type) {
+			continue
+		}
+		wg.Add(1)
+		go func(s subscriber) {
+			defer wg.Done()
+			h.deliver(ctx, s, event)
+		}(s)
+	}
+}
+
+// Deliveries returns the delivery log, newest first
+func (h *Hub) Deliveries() []Delivery {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	deliveries := make([]Delivery, 0, len(h.deliveries))
+	for i := len(h.deliveries) - 1; i >= 0; i-- {
+		deliveries = append(deliveries, h.deliveries[i])
+	}
+	return deliveries
+}
+
+// deliver sends an event to a notifier, retrying with exponential backoff, and logs the outcome
+func (h *Hub) deliver(ctx context.Context, s subscriber, event Event) {
+	delivery := Delivery{EventID: event.ID, EventType: event.Type, Notifier: s.Name()}
+
+	backoff := h.backoff
+	for attempt := 0; attempt <= s.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		delivery.Attempts++
+		err := s.Send(context.WithoutCancel(ctx), event)
+		if err == nil {
+			delivery.Status = DeliveryDelivered
+			delivery.Error = ""
+			break
+		}
+		delivery.Status = DeliveryFailed
+		delivery.Error = err.Error()
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	delivery.Time = time.Now().Unix()
+
+	if delivery.Status == DeliveryFailed {
+		slog.Warn("failed to deliver notification", "event_type", event.Type, "event_id", event.ID, "notifier", delivery.Notifier, "attempts", delivery.Attempts, "error", delivery.Error)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.deliveries = append(h.deliveries, delivery)
+	if len(h.deliveries) > maxDeliveries {
+		h.deliveries = h.deliveries[len(h.deliveries)-maxDeliveries:]
+	}
+}
+
+// Match reports whether an event type matches one of the patterns, such as "reset.failed", "vm.*" or "*".
+// An empty pattern list matches every event.
+func Match(patterns []string, eventType string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if pattern == "*" || pattern == eventType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(eventType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// newEventID returns a random event ID
+func newEventID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
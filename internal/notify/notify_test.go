@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+)
+
+// newHub creates a hub delivering to notifiers with the given retries
+func newHub(retries int, notifiers ...Notifier) *Hub {
+	h := NewHub()
+	for _, n := range notifiers {
+		h.Add(n, retries)
+	}
+	return h
+}
+
+// runHub runs h until the test ends
+func runHub(t *testing.T, h *Hub) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		h.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// waitDeliveries waits until the hub has logged n deliveries
+func waitDeliveries(t *testing.T, h *Hub, n int) []Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if deliveries := h.Deliveries(); len(deliveries) >= n {
+			return deliveries
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("fewer than %d deliveries logged", n)
+	return nil
+}
+
+func TestHubRetries(t *testing.T) {
+	rc, url := newReceiver(t, http.StatusInternalServerError, http.StatusBadGateway)
+	h := newHub(3, NewWebhook(config.Webhook{Format: config.WebhookJSON, URL: url}, "", nil))
+	h.backoff = 20 * time.Millisecond
+	runHub(t, h)
+
+	start := time.Now()
+	event := h.Publish(Event{Type: EventResetFailed, Summary: "Lab reset failed"})
+	delivery := waitDeliveries(t, h, 1)[0]
+
+	if delivery.Status != DeliveryDelivered || delivery.Attempts != 3 || delivery.EventID != event.ID {
+		t.Errorf("delivery %+v, want delivered on the third attempt", delivery)
+	}
+	// Two retries wait 20ms and then 40ms
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("delivered after %s, want the backoff to double", elapsed)
+	}
+	if requests := rc.received(); len(requests) != 3 || requests[0].header.Get("X-GOAD-Delivery") != requests[2].header.Get("X-GOAD-Delivery") {
+		t.Errorf("%d requests, want 3 for the same delivery", len(requests))
+	}
+}
+
+func TestHubGivesUp(t *testing.T) {
+	rc, url := newReceiver(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	h := newHub(1, NewWebhook(config.Webhook{Format: config.WebhookJSON, URL: url}, "", nil))
+	h.backoff = time.Millisecond
+	runHub(t, h)
+
+	h.Publish(Event{Type: EventVMDown})
+	delivery := waitDeliveries(t, h, 1)[0]
+
+	if delivery.Status != DeliveryFailed || delivery.Attempts != 2 || !strings.Contains(delivery.Error, "status 500") {
+		t.Errorf("delivery %+v, want failed after 2 attempts", delivery)
+	}
+	if requests := rc.received(); len(requests) != 2 {
+		t.Errorf("%d requests, want 2", len(requests))
+	}
+}
+
+func TestHubSkipsUnsubscribedNotifiers(t *testing.T) {
+	rc, url := newReceiver(t)
+	h := newHub(0, NewWebhook(config.Webhook{Format: config.WebhookJSON, URL: url}, "", []string{"reset.*"}))
+	runHub(t, h)
+
+	h.Publish(Event{Type: EventVPNConnected})
+	h.Publish(Event{Type: EventResetSucceeded})
+	waitDeliveries(t, h, 1)
+
+	if requests := rc.received(); len(requests) != 1 || requests[0].header.Get("X-GOAD-Event") != EventResetSucceeded {
+		t.Errorf("got %d requests, want only the reset", len(requests))
+	}
+}
+
+// accepting is a notifier that delivers every event
+type accepting struct{}
+
+func (accepting) Name() string                      { return "accepting" }
+func (accepting) Accepts(string) bool               { return true }
+func (accepting) Send(context.Context, Event) error { return nil }
+
+func TestDeliveryLogCap(t *testing.T) {
+	h := newHub(0, accepting{})
+	for i := 0; i < maxDeliveries+5; i++ {
+		h.deliver(context.Background(), subscriber{Notifier: accepting{}}, Event{ID: fmt.Sprint(i), Type: EventTest})
+	}
+
+	deliveries := h.Deliveries()
+	if len(deliveries) != maxDeliveries {
+		t.Fatalf("%d deliveries logged, want %d", len(deliveries), maxDeliveries)
+	}
+	if newest, oldest := deliveries[0].EventID, deliveries[len(deliveries)-1].EventID; newest != fmt.Sprint(maxDeliveries+4) || oldest != "5" {
+		t.Errorf("log runs from %s to %s, want the newest first and the oldest dropped", newest, oldest)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		patterns  []string
+		eventType string
+		want      bool
+	}{
+		{nil, EventVMDown, true},
+		{[]string{"*"}, EventVMDown, true},
+		{[]string{"vm.*"}, EventVMDown, true},
+		{[]string{"vm.down"}, EventVMDown, true},
+		{[]string{"vm.stopped", "reset.*"}, EventVMDown, false},
+		{[]string{"vpn.*"}, EventVMDown, false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.patterns, tt.eventType); got != tt.want {
+			t.Errorf("Match(%v, %s) = %v, want %v", tt.patterns, tt.eventType, got, tt.want)
+		}
+	}
+}
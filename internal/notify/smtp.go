@@ -0,0 +1,250 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+)
+
+// smtpTimeout bounds a whole SMTP conversation
+const smtpTimeout = 30 * time.Second
+
+// defaultSubjectTemplate is used when SMTP_SUBJECT_TEMPLATE is not set
+const defaultSubjectTemplate = `[GOAD] {{.Summary}}`
+
+// defaultBodyTemplate is used when SMTP_BODY_TEMPLATE_FILE is not set
+const defaultBodyTemplate = `{{.Summary}}
+
+Event:    {{.Type}}
+Severity: {{.Severity}}
+Time:     {{formatTime .Time}}
+{{range $key, $value := .Data}}
+{{$key}}: {{json $value}}{{end}}
+
+--
+Sent by the GOAD Dashboard
+`
+
+// templateFuncs are available in the subject and body templates
+var templateFuncs = template.FuncMap{
+	"formatTime": func(unix int64) string {
+		return time.Unix(unix, 0).Format(time.RFC1123Z)
+	},
+	"json": func(value interface{}) string {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(b)
+	},
+	"upper": strings.ToUpper,
+}
+
+// Mailer sends templated emails through an SMTP server
+type Mailer struct {
+	host      string
+	port      string
+	security  string
+	username  string
+	password  string
+	from      string
+	tlsConfig *tls.Config
+	subject   *template.Template
+	body      *template.Template
+}
+
+// NewMailerFromConfig creates a mailer using the application config. It returns nil when SMTP_HOST is not set.
+func NewMailerFromConfig(config *config.Config) (*Mailer, error) {
+	if config.GetSMTPHost() == "" {
+		return nil, nil
+	}
+
+	subjectTemplate := config.GetSMTPSubjectTemplate()
+	if subjectTemplate == "" {
+		subjectTemplate = defaultSubjectTemplate
+	}
+	subject, err := template.New("subject").Funcs(templateFuncs).Parse(subjectTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SMTP_SUBJECT_TEMPLATE: %w", err)
+	}
+
+	bodyTemplate := config.GetSMTPBodyTemplate()
+	if bodyTemplate == "" {
+		bodyTemplate = defaultBodyTemplate
+	}
+	body, err := template.New("body").Funcs(templateFuncs).Parse(bodyTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SMTP_BODY_TEMPLATE_FILE: %w", err)
+	}
+
+	return &Mailer{
+		host:     config.GetSMTPHost(),
+		port:     config.GetSMTPPort(),
+		security: config.GetSMTPSecurity(),
+		username: config.GetSMTPUsername(),
+		password: config.GetSMTPPassword(),
+		from:     config.GetSMTPFrom(),
+		tlsConfig: &tls.Config{
+			ServerName:         config.GetSMTPHost(),
+			InsecureSkipVerify: config.GetSMTPInsecure(),
+		},
+		subject: subject,
+		body:    body,
+	}, nil
+}
+
+// NewEmailsFromConfig creates a notifier for every configured email recipient
+func NewEmailsFromConfig(config *config.Config) ([]Notifier, error) {
+	mailer, err := NewMailerFromConfig(config)
+	if err != nil || mailer == nil {
+		return nil, err
+	}
+
+	var notifiers []Notifier
+	for _, recipient := range config.GetSMTPRecipients() {
+		notifiers = append(notifiers, &Email{Address: recipient.Address, Events: recipient.Events, mailer: mailer})
+	}
+	return notifiers, nil
+}
+
+// Email sends events to a single recipient, so that every recipient has its own subscriptions and delivery log
+type Email struct {
+	Address string
+	Events  []string
+	mailer  *Mailer
+}
+
+// Name returns the recipient address
+func (e *Email) Name() string {
+	return "email " + e.Address
+}
+
+// Accepts reports whether the recipient subscribes to an event type
+func (e *Email) Accepts(eventType string) bool {
+	return eventType == EventTest || Match(e.Events, eventType)
+}
+
+// Send mails an event to the recipient
+func (e *Email) Send(ctx context.Context, event Event) error {
+	message, err := e.mailer.message(e.Address, event)
+	if err != nil {
+		return err
+	}
+	return e.mailer.Send(ctx, e.Address, message)
+}
+
+// message renders the templates into a MIME message
+func (m *Mailer) message(to string, event Event) ([]byte, error) {
+	var subject bytes.Buffer
+	if err := m.subject.Execute(&subject, event); err != nil {
+		return nil, fmt.Errorf("failed to render subject: %w", err)
+	}
+	var body bytes.Buffer
+	if err := m.body.Execute(&body, event); err != nil {
+		return nil, fmt.Errorf("failed to render body: %w", err)
+	}
+
+	// 防止模板内容注入邮件头
+	subjectLine := strings.Join(strings.Fields(subject.String()), " ")
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subjectLine))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%s@%s>\r\n", newMessageID(), m.host)
+	fmt.Fprintf(&msg, "X-GOAD-Event: %s\r\n", event.Type)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	msg.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&msg)
+	qp.Write([]byte(strings.ReplaceAll(strings.ReplaceAll(body.String(), "\r\n", "\n"), "\n", "\r\n")))
+	qp.Close()
+
+	return msg.Bytes(), nil
+}
+
+// Send delivers a message to one recipient
+func (m *Mailer) Send(ctx context.Context, to string, message []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	address := net.JoinHostPort(m.host, m.port)
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if m.security == config.SMTPTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: m.tlsConfig}).DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return fmt.Errorf("failed to greet SMTP server: %w", err)
+	}
+	defer client.Close()
+
+	if m.security == config.SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP server does not support STARTTLS")
+		}
+		if err := client.StartTLS(m.tlsConfig); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+
+	if m.username != "" {
+		// PlainAuth refuses to send the password over an unencrypted connection, except to localhost
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return fmt.Errorf("failed to authenticate to SMTP server: %w", err)
+		}
+	}
+
+	if err := client.Mail(m.from); err != nil {
+		return fmt.Errorf("SMTP server rejected the sender: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("SMTP server rejected the recipient: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("SMTP server rejected the message: %w", err)
+	}
+	return client.Quit()
+}
+
+// newMessageID returns a random Message-ID local part
+func newMessageID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,296 @@
+package notify
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+)
+
+// smtpMessage is a message accepted by the fake SMTP server
+type smtpMessage struct {
+	from string
+	to   string
+	user string // 认证的用户, 未认证时为空
+	tls  bool
+	data string
+}
+
+// fakeSMTP is an SMTP server speaking just enough of the protocol for net/smtp
+type fakeSMTP struct {
+	address   string
+	tlsConfig *tls.Config
+	startTLS  bool // 是否提供 STARTTLS
+	password  string
+
+	mu       sync.Mutex
+	messages []smtpMessage
+}
+
+// serveSMTP starts a fake SMTP server. With implicitTLS, connections are TLS from the start.
+func serveSMTP(t *testing.T, cert tls.Certificate, implicitTLS bool, startTLS bool) *fakeSMTP {
+	t.Helper()
+
+	f := &fakeSMTP{
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+		startTLS:  startTLS,
+		password:  "hunter2",
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if implicitTLS {
+		listener = tls.NewListener(listener, f.tlsConfig)
+	}
+	t.Cleanup(func() { listener.Close() })
+	f.address = listener.Addr().String()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.handle(conn, implicitTLS)
+		}
+	}()
+	return f
+}
+
+func (f *fakeSMTP) handle(conn net.Conn, secure bool) {
+	defer func() { conn.Close() }()
+
+	tp := textproto.NewConn(conn)
+	msg := smtpMessage{tls: secure}
+	tp.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			lines := []string{"250-fake"}
+			if f.startTLS && !msg.tls {
+				lines = append(lines, "250-STARTTLS")
+			}
+			lines = append(lines, "250 AUTH PLAIN")
+			tp.PrintfLine("%s", strings.Join(lines, "\r\n"))
+		case "STARTTLS":
+			tp.PrintfLine("220 ready to start TLS")
+			tlsConn := tls.Server(conn, f.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			tp = textproto.NewConn(conn)
+			msg.tls = true
+		case "AUTH":
+			mechanism, response, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(response)
+			parts := strings.Split(string(decoded), "\x00")
+			if mechanism != "PLAIN" || len(parts) != 3 || parts[2] != f.password {
+				tp.PrintfLine("535 authentication failed")
+				continue
+			}
+			msg.user = parts[1]
+			tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			msg.from = arg
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			msg.to = arg
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			lines, err := tp.ReadDotLines()
+			if err != nil {
+				return
+			}
+			msg.data = strings.Join(lines, "\r\n")
+			f.mu.Lock()
+			f.messages = append(f.messages, msg)
+			f.mu.Unlock()
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func (f *fakeSMTP) received() []smtpMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]smtpMessage(nil), f.messages...)
+}
+
+// selfSigned returns a certificate for 127.0.0.1 and a pool trusting it
+func selfSigned(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fake smtp"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+// newTestMailer creates a mailer for a fake server with the default templates
+func newTestMailer(t *testing.T, address string, security string, pool *x509.CertPool) *Mailer {
+	t.Helper()
+
+	host, port, _ := net.SplitHostPort(address)
+	return &Mailer{
+		host:      host,
+		port:      port,
+		security:  security,
+		username:  "goad",
+		password:  "hunter2",
+		from:      "goad@example.org",
+		tlsConfig: &tls.Config{ServerName: host, RootCAs: pool},
+		subject:   template.Must(template.New("subject").Funcs(templateFuncs).Parse(defaultSubjectTemplate)),
+		body:      template.Must(template.New("body").Funcs(templateFuncs).Parse(defaultBodyTemplate)),
+	}
+}
+
+func TestEmailSend(t *testing.T) {
+	cert, pool := selfSigned(t)
+	server := serveSMTP(t, cert, false, true)
+	email := &Email{Address: "ops@example.org", mailer: newTestMailer(t, server.address, config.SMTPStartTLS, pool)}
+
+	summary := "Lab reset failed\r\nBcc: victim@example.org"
+	longName := strings.Repeat("é", 100)
+	event := Event{ID: "abc", Type: EventResetFailed, Time: 1700000000, Severity: SeverityCritical, Summary: summary, Data: map[string]interface{}{"vm": longName}}
+	if err := email.Send(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := server.received()
+	if len(messages) != 1 {
+		t.Fatalf("%d messages, want 1", len(messages))
+	}
+	msg := messages[0]
+	if !msg.tls || msg.user != "goad" {
+		t.Errorf("message sent with TLS %v by %q, want STARTTLS and authentication", msg.tls, msg.user)
+	}
+	if msg.from != "FROM:<goad@example.org>" || msg.to != "TO:<ops@example.org>" {
+		t.Errorf("envelope %s %s", msg.from, msg.to)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(msg.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bcc := parsed.Header.Get("Bcc"); bcc != "" {
+		t.Errorf("summary injected a Bcc header: %q", bcc)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != "[GOAD] Lab reset failed Bcc: victim@example.org" {
+		t.Errorf("subject %q (%v)", subject, err)
+	}
+	if parsed.Header.Get("X-GOAD-Event") != EventResetFailed || parsed.Header.Get("Content-Transfer-Encoding") != "quoted-printable" {
+		t.Errorf("headers %v", parsed.Header)
+	}
+
+	encoded, _ := io.ReadAll(parsed.Body)
+	for _, line := range strings.Split(string(encoded), "\r\n") {
+		if len(line) > 76 {
+			t.Errorf("encoded line of %d characters", len(line))
+		}
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(string(encoded))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), longName) || !strings.Contains(string(body), "Event:    reset.failed\r\n") {
+		t.Errorf("body %q", body)
+	}
+}
+
+func TestEmailImplicitTLS(t *testing.T) {
+	cert, pool := selfSigned(t)
+	server := serveSMTP(t, cert, true, false)
+	email := &Email{Address: "ops@example.org", mailer: newTestMailer(t, server.address, config.SMTPTLS, pool)}
+
+	if err := email.Send(context.Background(), Event{Type: EventTest, Summary: "Test"}); err != nil {
+		t.Fatal(err)
+	}
+	if messages := server.received(); len(messages) != 1 || !messages[0].tls {
+		t.Errorf("messages %+v, want one over TLS", messages)
+	}
+}
+
+func TestEmailFailures(t *testing.T) {
+	cert, pool := selfSigned(t)
+
+	// The server does not offer STARTTLS, so nothing may be sent in clear text
+	plain := serveSMTP(t, cert, false, false)
+	email := &Email{Address: "ops@example.org", mailer: newTestMailer(t, plain.address, config.SMTPStartTLS, pool)}
+	if err := email.Send(context.Background(), Event{Type: EventTest}); err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("without STARTTLS: %v", err)
+	}
+
+	// The certificate is not trusted
+	untrusted := serveSMTP(t, cert, false, true)
+	email = &Email{Address: "ops@example.org", mailer: newTestMailer(t, untrusted.address, config.SMTPStartTLS, x509.NewCertPool())}
+	if err := email.Send(context.Background(), Event{Type: EventTest}); err == nil {
+		t.Error("untrusted certificate: expected an error")
+	}
+
+	// The password is wrong
+	wrong := serveSMTP(t, cert, false, true)
+	mailer := newTestMailer(t, wrong.address, config.SMTPStartTLS, pool)
+	mailer.password = "wrong"
+	email = &Email{Address: "ops@example.org", mailer: mailer}
+	if err := email.Send(context.Background(), Event{Type: EventTest}); err == nil || !strings.Contains(err.Error(), "authenticate") {
+		t.Errorf("wrong password: %v", err)
+	}
+
+	for _, server := range []*fakeSMTP{plain, untrusted, wrong} {
+		if messages := server.received(); len(messages) != 0 {
+			t.Errorf("%d messages accepted", len(messages))
+		}
+	}
+}
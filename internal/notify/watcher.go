@@ -0,0 +1,277 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/lab"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/reservation"
+)
+
+// Watcher polls Proxmox, pfSense and the reservations and publishes the changes it sees between two polls
+type Watcher struct {
+	hypervisor   platform.Hypervisor
+	gateway      platform.VPNGateway
+	reservations *reservation.Store
+	jobs         *lab.JobManager
+	hub          *Hub
+	interval     time.Duration
+	downAfter    time.Duration
+	notice       time.Duration
+
+	vms       map[string]platform.VMInfo // 以 VMID 为键, nil 表示尚未轮询
+	polled    time.Time                  // 上一次轮询 VM 的时间
+	downSince map[string]time.Time       // 停止运行的时间, 已报告的 VM 为零值
+	vpn       map[string]platform.VPNConnection
+	pfsense   string
+	announced map[string]bool // 已通知的维护, 以预约 ID 和开始时间为键
+}
+
+// NewWatcher creates a watcher using the application config. VM status changes while lab jobs run are not reported.
+func NewWatcher(config *config.Config, hypervisor platform.Hypervisor, gateway platform.VPNGateway, reservations *reservation.Store, jobs *lab.JobManager, hub *Hub) *Watcher {
+	return &Watcher{
+		hypervisor:   hypervisor,
+		gateway:      gateway,
+		reservations: reservations,
+		jobs:         jobs,
+		hub:          hub,
+		interval:     config.GetNotifyPollInterval(),
+		downAfter:    config.GetVMDownAfter(),
+		notice:       config.GetMaintenanceNotice(),
+		downSince:    map[string]time.Time{},
+		announced:    map[string]bool{},
+	}
+}
+
+// Run polls until ctx is cancelled. The first poll only records the current state.
+func (w *Watcher) Run(ctx context.Context) {
+	if !w.hub.Enabled() {
+		return
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Watcher) poll(ctx context.Context) {
+	w.pollVMs(ctx)
+	w.pollVPN(ctx)
+	w.pollPfsense(ctx)
+	w.pollMaintenance()
+}
+
+// pollVMs publishes VM status transitions, unless a lab job or a power operation of the dashboard ran since the last poll
+func (w *Watcher) pollVMs(ctx context.Context) {
+	polled := time.Now()
+	vms, err := w.hypervisor.GetVMs(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to poll VMs for notifications", "error", err)
+		return
+	}
+
+	current := map[string]platform.VMInfo{}
+	for _, vm := range vms {
+		current[vm.ID] = vm
+	}
+
+	planned := w.jobs.BusySince(w.polled)
+	if w.vms != nil && !planned {
+		for id, vm := range current {
+			before, ok := w.vms[id]
+			if !ok || before.Status == vm.Status {
+				continue
+			}
+			w.hub.Publish(vmEvent(vm, before.Status))
+		}
+	}
+	w.trackDowntime(current, planned)
+	w.vms = current
+	w.polled = polled
+}
+
+// trackDowntime publishes VMs that stopped running and stayed down for longer than downAfter.
+// VMs already down when the dashboard started, or stopped while a lab job or power operation ran, are not reported.
+func (w *Watcher) trackDowntime(current map[string]platform.VMInfo, planned bool) {
+	if w.downAfter == 0 || w.vms == nil {
+		return
+	}
+
+	now := time.Now()
+	for id := range w.downSince {
+		if vm, ok := current[id]; !ok || vm.Status == "running" {
+			delete(w.downSince, id)
+		}
+	}
+
+	for id, vm := range current {
+		if vm.Status == "running" {
+			continue
+		}
+		since, tracked := w.downSince[id]
+		if !tracked {
+			if w.vms[id].Status == "running" && !planned {
+				w.downSince[id] = now
+			}
+			continue
+		}
+		if since.IsZero() || now.Sub(since) < w.downAfter {
+			continue
+		}
+
+		w.hub.Publish(Event{
+			Type:     EventVMDown,
+			Severity: SeverityCritical,
+			Summary:  fmt.Sprintf("VM %s (%s) has been %s for %s", vm.Name, vm.ID, vm.Status, now.Sub(since).Round(time.Minute)),
+			Data:     map[string]interface{}{"vmid": vm.ID, "name": vm.Name, "node": vm.Node, "status": vm.Status, "down_since": since.Unix()},
+		})
+		w.downSince[id] = time.Time{}
+	}
+}
+
+// pollVPN publishes VPN connections and disconnections
+func (w *Watcher) pollVPN(ctx context.Context) {
+	connections, err := w.gateway.GetOpenVPNConnections(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to poll VPN connections for notifications", "error", err)
+		return
+	}
+
+	current := map[string]platform.VPNConnection{}
+	for _, conn := range connections {
+		current[fmt.Sprintf("%s/%d", conn.Name, conn.ConnectTime)] = conn
+	}
+
+	if w.vpn != nil {
+		for key, conn := range current {
+			if _, ok := w.vpn[key]; !ok {
+				w.hub.Publish(Event{
+					Type:    EventVPNConnected,
+					Summary: fmt.Sprintf("%s connected to the lab VPN", conn.Name),
+					Data:    map[string]interface{}{"common_name": conn.Name, "connect_time": conn.ConnectTime},
+				})
+			}
+		}
+		for key, conn := range w.vpn {
+			if _, ok := current[key]; !ok {
+				w.hub.Publish(Event{
+					Type:    EventVPNDisconnected,
+					Summary: fmt.Sprintf("%s disconnected from the lab VPN", conn.Name),
+					Data:    map[string]interface{}{"common_name": conn.Name, "connect_time": conn.ConnectTime},
+				})
+			}
+		}
+	}
+	w.vpn = current
+}
+
+// pollPfsense publishes changes of the overall pfSense health
+func (w *Watcher) pollPfsense(ctx context.Context) {
+	health := w.gateway.GetHealth(ctx)
+
+	if w.pfsense != "" && w.pfsense != health.State {
+		event := Event{
+			Type:     "pfsense." + health.State,
+			Severity: SeverityInfo,
+			Summary:  fmt.Sprintf("pfSense is %s (was %s)", health.State, w.pfsense),
+			Data:     map[string]interface{}{"state": health.State, "previous": w.pfsense, "reasons": health.Reasons},
+		}
+		switch health.State {
+		case platform.HealthDown:
+			event.Severity = SeverityCritical
+		case platform.HealthDegraded:
+			event.Severity = SeverityWarning
+		}
+		w.hub.Publish(event)
+	}
+	w.pfsense = health.State
+}
+
+// pollMaintenance announces the automatic lab resets of reservations starting within the notice period
+func (w *Watcher) pollMaintenance() {
+	now := time.Now()
+	pending := map[string]bool{}
+
+	for _, r := range w.reservations.List() {
+		if !r.AutoReset || r.ResetJob != "" || r.Start <= now.Unix() {
+			continue
+		}
+		key := fmt.Sprintf("%s/%d", r.ID, r.Start)
+		pending[key] = true
+		if w.announced[key] || time.Unix(r.Start, 0).Sub(now) > w.notice {
+			continue
+		}
+
+		title := r.Title
+		if title == "" {
+			title = "reservation"
+		}
+		w.hub.Publish(Event{
+			Type:     EventMaintenance,
+			Severity: SeverityWarning,
+			Summary:  fmt.Sprintf("The lab will be reset at %s for %q of %s", time.Unix(r.Start, 0).Format("2006-01-02 15:04 MST"), title, r.User),
+			Data:     map[string]interface{}{"reservation_id": r.ID, "user": r.User, "title": r.Title, "start": r.Start, "end": r.End},
+		})
+		w.announced[key] = true
+	}
+
+	for key := range w.announced {
+		if !pending[key] {
+			delete(w.announced, key)
+		}
+	}
+}
+
+// vmEvent describes a VM status transition
+func vmEvent(vm platform.VMInfo, previous string) Event {
+	event := Event{
+		Type:     EventVMStatusChanged,
+		Severity: SeverityInfo,
+		Summary:  fmt.Sprintf("VM %s (%s) is %s (was %s)", vm.Name, vm.ID, vm.Status, previous),
+		Data:     map[string]interface{}{"vmid": vm.ID, "name": vm.Name, "node": vm.Node, "status": vm.Status, "previous": previous},
+	}
+	switch {
+	case vm.Status == "running":
+		event.Type = EventVMStarted
+	case previous == "running" && vm.Status == "stopped":
+		event.Type = EventVMStopped
+		event.Severity = SeverityWarning
+	}
+	return event
+}
+
+// JobEvent describes a finished reset job. Other jobs are not reported.
+func JobEvent(job lab.Job) (Event, bool) {
+	if job.Kind != lab.JobReset {
+		return Event{}, false
+	}
+
+	event := Event{
+		Type:     "reset." + job.Status,
+		Severity: SeverityInfo,
+		Summary:  fmt.Sprintf("Lab reset %s", job.Status),
+		Data:     map[string]interface{}{"job_id": job.ID, "status": job.Status, "vms": job.VMs},
+	}
+	if job.Message != "" {
+		event.Summary += ": " + job.Message
+	}
+	switch job.Status {
+	case lab.JobFailed:
+		event.Severity = SeverityCritical
+	case lab.JobDegraded:
+		event.Severity = SeverityWarning
+	}
+	return event, true
+}
@@ -0,0 +1,202 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/lab"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/reservation"
+)
+
+// stubLab serves the VMs, VPN connections and pfSense health the watcher polls
+type stubLab struct {
+	platform.Hypervisor
+	platform.VPNGateway
+
+	mu          sync.Mutex
+	vms         []platform.VMInfo
+	connections []platform.VPNConnection
+	health      string
+}
+
+func (s *stubLab) GetVMs(context.Context) ([]platform.VMInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]platform.VMInfo(nil), s.vms...), nil
+}
+
+func (s *stubLab) GetOpenVPNConnections(context.Context) ([]platform.VPNConnection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]platform.VPNConnection(nil), s.connections...), nil
+}
+
+func (s *stubLab) GetHealth(context.Context) *platform.GatewayHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &platform.GatewayHealth{State: s.health}
+}
+
+func (s *stubLab) setStatus(id string, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.vms {
+		if s.vms[i].ID == id {
+			s.vms[i].Status = status
+		}
+	}
+}
+
+func newTestWatcher(downAfter time.Duration) (*Watcher, *stubLab, *lab.JobManager) {
+	stub := &stubLab{
+		vms: []platform.VMInfo{
+			{ID: "101", Name: "DC01", Node: "pve1", Status: "running"},
+			{ID: "102", Name: "DC02", Node: "pve1", Status: "running"},
+		},
+		health: platform.HealthHealthy,
+	}
+	jobs := lab.NewJobManager(time.Minute)
+	w := &Watcher{
+		hypervisor:   stub,
+		gateway:      stub,
+		reservations: &reservation.Store{},
+		jobs:         jobs,
+		hub:          newHub(0, accepting{}),
+		downAfter:    downAfter,
+		downSince:    map[string]time.Time{},
+		announced:    map[string]bool{},
+	}
+	return w, stub, jobs
+}
+
+// published drains the events queued in the hub
+func published(h *Hub) []Event {
+	var events []Event
+	for {
+		select {
+		case event := <-h.queue:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func eventTypes(events []Event) []string {
+	types := []string{}
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestWatcherTransitions(t *testing.T) {
+	w, stub, _ := newTestWatcher(0)
+	ctx := context.Background()
+
+	// The first poll only records the state
+	w.poll(ctx)
+	if events := published(w.hub); len(events) != 0 {
+		t.Fatalf("first poll published %v", eventTypes(events))
+	}
+
+	stub.setStatus("101", "stopped")
+	stub.mu.Lock()
+	stub.connections = []platform.VPNConnection{{Name: "alice", ConnectTime: 1700000000}}
+	stub.health = platform.HealthDown
+	stub.mu.Unlock()
+	w.poll(ctx)
+
+	events := published(w.hub)
+	want := map[string]string{
+		EventVMStopped:    SeverityWarning,
+		EventVPNConnected: SeverityInfo,
+		EventPfsenseDown:  SeverityCritical,
+	}
+	if len(events) != len(want) {
+		t.Fatalf("published %v, want %d events", eventTypes(events), len(want))
+	}
+	for _, event := range events {
+		if severity, ok := want[event.Type]; !ok || event.Severity != severity {
+			t.Errorf("event %s with severity %s", event.Type, event.Severity)
+		}
+	}
+
+	stub.setStatus("101", "running")
+	stub.mu.Lock()
+	stub.connections = nil
+	stub.mu.Unlock()
+	w.poll(ctx)
+
+	types := eventTypes(published(w.hub))
+	if len(types) != 2 || !contains(types, EventVMStarted) || !contains(types, EventVPNDisconnected) {
+		t.Errorf("published %v, want the VM start and the VPN disconnection", types)
+	}
+}
+
+func TestWatcherIgnoresPlannedChanges(t *testing.T) {
+	w, stub, jobs := newTestWatcher(0)
+	ctx := context.Background()
+	w.poll(ctx)
+
+	// A power operation of the dashboard stops the VM, the watcher polls afterwards
+	jobs.Exclusive(func() error {
+		stub.setStatus("101", "stopped")
+		return nil
+	})
+	w.pollVMs(ctx)
+	if events := published(w.hub); len(events) != 0 {
+		t.Errorf("planned stop published %v", eventTypes(events))
+	}
+
+	// Once nothing ran since the last poll, changes are reported again
+	time.Sleep(time.Millisecond)
+	stub.setStatus("102", "stopped")
+	w.pollVMs(ctx)
+	if types := eventTypes(published(w.hub)); len(types) != 1 || types[0] != EventVMStopped {
+		t.Errorf("published %v, want the unplanned stop", types)
+	}
+}
+
+func TestWatcherDowntime(t *testing.T) {
+	w, stub, jobs := newTestWatcher(time.Millisecond)
+	ctx := context.Background()
+	w.poll(ctx)
+
+	// DC01 is stopped by a lab job, DC02 crashes afterwards
+	jobs.Exclusive(func() error {
+		stub.setStatus("101", "stopped")
+		return nil
+	})
+	w.pollVMs(ctx)
+	time.Sleep(time.Millisecond)
+	stub.setStatus("102", "stopped")
+	w.pollVMs(ctx)
+	published(w.hub)
+
+	time.Sleep(2 * time.Millisecond)
+	w.pollVMs(ctx)
+	events := published(w.hub)
+	if len(events) != 1 || events[0].Type != EventVMDown || events[0].Data["vmid"] != "102" {
+		t.Fatalf("published %v, want DC02 down", eventTypes(events))
+	}
+
+	// Each outage is reported once
+	time.Sleep(2 * time.Millisecond)
+	w.pollVMs(ctx)
+	if events := published(w.hub); len(events) != 0 {
+		t.Errorf("published %v again", eventTypes(events))
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+)
+
+// webhookTimeout bounds a single webhook request
+const webhookTimeout = 10 * time.Second
+
+// Webhook posts events as JSON, either as the raw event or formatted for Slack, Discord or Teams.
+// When a secret is set, requests carry an HMAC-SHA256 signature of "<timestamp>.<body>" in X-GOAD-Signature.
+type Webhook struct {
+	Format string
+	URL    string
+	Secret string
+	Events []string
+	client *http.Client
+}
+
+// NewWebhook creates a webhook notifier
+func NewWebhook(webhook config.Webhook, secret string, events []string) *Webhook {
+	return &Webhook{
+		Format: webhook.Format,
+		URL:    webhook.URL,
+		Secret: secret,
+		Events: events,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// NewWebhooksFromConfig creates a notifier for every configured webhook
+func NewWebhooksFromConfig(config *config.Config) []Notifier {
+	var notifiers []Notifier
+	for _, webhook := range config.GetWebhooks() {
+		notifiers = append(notifiers, NewWebhook(webhook, config.GetWebhookSecret(), config.GetWebhookEvents()))
+	}
+	return notifiers
+}
+
+// Name returns the format and host of the webhook, leaving out the path which often holds a token
+func (w *Webhook) Name() string {
+	host := ""
+	if u, err := url.Parse(w.URL); err == nil {
+		host = u.Host
+	}
+	return fmt.Sprintf("%s webhook %s", w.Format, host)
+}
+
+// Accepts reports whether the webhook subscribes to an event type
+func (w *Webhook) Accepts(eventType string) bool {
+	return eventType == EventTest || Match(w.Events, eventType)
+}
+
+// Send posts an event
+func (w *Webhook) Send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(w.payload(event))
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "GOAD-Dashboard")
+	req.Header.Set("X-GOAD-Event", event.Type)
+	req.Header.Set("X-GOAD-Delivery", event.ID)
+	req.Header.Set("X-GOAD-Timestamp", timestamp)
+	if w.Secret != "" {
+		req.Header.Set("X-GOAD-Signature", "sha256="+Sign(w.Secret, timestamp, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>", as sent in X-GOAD-Signature
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// payload formats an event for the webhook format
+func (w *Webhook) payload(event Event) interface{} {
+	text := fmt.Sprintf("[%s] %s", event.Severity, event.Summary)
+
+	switch w.Format {
+	case config.WebhookSlack:
+		return map[string]interface{}{
+			"text": fmt.Sprintf("%s *%s*", severityEmoji(event.Severity), event.Summary),
+		}
+	case config.WebhookDiscord:
+		return map[string]interface{}{
+			"content": fmt.Sprintf("%s **%s**", severityEmoji(event.Severity), event.Summary),
+		}
+	case config.WebhookTeams:
+		return map[string]interface{}{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    text,
+			"themeColor": severityColor(event.Severity),
+			"title":      "GOAD lab: " + event.Type,
+			"text":       event.Summary,
+		}
+	default:
+		return event
+	}
+}
+
+func severityEmoji(severity string) string {
+	switch severity {
+	case SeverityCritical:
+		return "🔴"
+	case SeverityWarning:
+		return "🟠"
+	default:
+		return "🟢"
+	}
+}
+
+func severityColor(severity string) string {
+	switch severity {
+	case SeverityCritical:
+		return "D13438"
+	case SeverityWarning:
+		return "FFAA44"
+	default:
+		return "107C10"
+	}
+}
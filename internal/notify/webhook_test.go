@@ -0,0 +1,142 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+)
+
+// received is a request captured by a test webhook receiver
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver records the webhook requests it gets and answers with the given statuses in turn, then 204
+type receiver struct {
+	mu       sync.Mutex
+	requests []received
+	statuses []int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, received{header: r.Header.Clone(), body: body})
+	status := http.StatusNoContent
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) received() []received {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]received(nil), rc.requests...)
+}
+
+func newReceiver(t *testing.T, statuses ...int) (*receiver, string) {
+	t.Helper()
+	rc := &receiver{statuses: statuses}
+	server := httptest.NewServer(rc)
+	t.Cleanup(server.Close)
+	return rc, server.URL + "/hooks/secret-token"
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '1700000000.{"a":1}' | openssl dgst -sha256 -hmac secret
+	want := "49f24e537407743fa4a0242bb63b94b9a47ee99cbbe071ccd8a22550ae411686"
+	if got := Sign("secret", "1700000000", []byte(`{"a":1}`)); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+}
+
+func TestWebhookSend(t *testing.T) {
+	rc, url := newReceiver(t)
+	webhook := NewWebhook(config.Webhook{Format: config.WebhookJSON, URL: url}, "secret", nil)
+
+	event := Event{ID: "abc", Type: EventResetFailed, Time: 1700000000, Severity: SeverityCritical, Summary: "Lab reset failed"}
+	if err := webhook.Send(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := rc.received()
+	if len(requests) != 1 {
+		t.Fatalf("%d requests, want 1", len(requests))
+	}
+	req := requests[0]
+	if req.header.Get("X-GOAD-Event") != EventResetFailed || req.header.Get("X-GOAD-Delivery") != "abc" {
+		t.Errorf("event headers %v", req.header)
+	}
+	signature := "sha256=" + Sign("secret", req.header.Get("X-GOAD-Timestamp"), req.body)
+	if req.header.Get("X-GOAD-Signature") != signature {
+		t.Errorf("signature %q, want %q", req.header.Get("X-GOAD-Signature"), signature)
+	}
+
+	var got Event
+	if err := json.Unmarshal(req.body, &got); err != nil || got.ID != "abc" || got.Summary != "Lab reset failed" {
+		t.Errorf("body %s", req.body)
+	}
+
+	if name := webhook.Name(); strings.Contains(name, "secret-token") {
+		t.Errorf("name %q reveals the URL path", name)
+	}
+}
+
+func TestWebhookPayloads(t *testing.T) {
+	event := Event{ID: "abc", Type: EventVMDown, Severity: SeverityCritical, Summary: "VM DC01 (101) has been stopped for 5m"}
+	tests := []struct {
+		format string
+		field  string
+		want   string
+	}{
+		{config.WebhookSlack, "text", "🔴 *VM DC01 (101) has been stopped for 5m*"},
+		{config.WebhookDiscord, "content", "🔴 **VM DC01 (101) has been stopped for 5m**"},
+		{config.WebhookTeams, "themeColor", "D13438"},
+		{config.WebhookTeams, "title", "GOAD lab: vm.down"},
+		{config.WebhookJSON, "type", EventVMDown},
+	}
+	for _, tt := range tests {
+		rc, url := newReceiver(t)
+		if err := NewWebhook(config.Webhook{Format: tt.format, URL: url}, "", nil).Send(context.Background(), event); err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+
+		req := rc.received()[0]
+		if req.header.Get("X-GOAD-Signature") != "" {
+			t.Errorf("%s: signed without a secret", tt.format)
+		}
+		var payload map[string]interface{}
+		if err := json.Unmarshal(req.body, &payload); err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		if payload[tt.field] != tt.want {
+			t.Errorf("%s: %s = %v, want %q", tt.format, tt.field, payload[tt.field], tt.want)
+		}
+	}
+}
+
+func TestWebhookAccepts(t *testing.T) {
+	webhook := NewWebhook(config.Webhook{Format: config.WebhookJSON, URL: "http://127.0.0.1"}, "", []string{"reset.*", "vm.down"})
+	for eventType, want := range map[string]bool{
+		EventResetFailed:  true,
+		EventVMDown:       true,
+		EventTest:         true,
+		EventVMStopped:    false,
+		EventVPNConnected: false,
+	} {
+		if got := webhook.Accepts(eventType); got != want {
+			t.Errorf("Accepts(%s) = %v, want %v", eventType, got, want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package platform
+
+import "strings"
+
+// VPNConnection is a client connected to the OpenVPN server
+type VPNConnection struct {
+	Id          int    `json:"id"`
+	Name        string `json:"common_name"`
+	ConnectTime uint64 `json:"connect_time_unix"`
+}
+
+// Summarised gateway health states
+const (
+	HealthHealthy  = "healthy"
+	HealthDegraded = "degraded"
+	HealthDown     = "down"
+)
+
+// SystemStatus contains pfSense system resource usage
+type SystemStatus struct {
+	Uptime      string    `json:"uptime"`
+	CPUUsage    float64   `json:"cpu_usage"` // CPU 使用率 (%)
+	CPUCount    int       `json:"cpu_count"`
+	CPULoadAvg  []float64 `json:"cpu_load_avg"`
+	MemUsage    float64   `json:"mem_usage"`  // 内存使用率 (%)
+	SwapUsage   float64   `json:"swap_usage"` // 交换分区使用率 (%)
+	DiskUsage   float64   `json:"disk_usage"` // 磁盘使用率 (%)
+	Temperature float64   `json:"temp_c"`
+}
+
+// ServiceStatus contains the state of a pfSense service
+type ServiceStatus struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	Status      bool   `json:"status"` // 服务是否正在运行
+}
+
+// GatewayStatus contains the monitoring state of a gateway
+type GatewayStatus struct {
+	Name      string `json:"name"`
+	MonitorIP string `json:"monitorip"`
+	Delay     string `json:"delay"`
+	Loss      string `json:"loss"`
+	Status    string `json:"status"`
+	Substatus string `json:"substatus"`
+}
+
+// InterfaceStatus contains the state and counters of a network interface
+type InterfaceStatus struct {
+	Name      string `json:"name"`
+	Descr     string `json:"descr"`
+	HWIF      string `json:"hwif"`
+	Status    string `json:"status"`
+	Enabled   bool   `json:"enable"`
+	IPAddr    string `json:"ipaddr"`
+	InBytes   int64  `json:"inbytes"`  // 总流入量 (字节)
+	OutBytes  int64  `json:"outbytes"` // 总流出量 (字节)
+	InPkts    int64  `json:"inpkts"`
+	OutPkts   int64  `json:"outpkts"`
+	InErrors  int64  `json:"inerrs"`
+	OutErrors int64  `json:"outerrs"`
+}
+
+// GatewayHealth summarises the health of the VPN gateway and the details it is derived from
+type GatewayHealth struct {
+	State      string            `json:"state"`
+	Reasons    []string          `json:"reasons"`
+	System     *SystemStatus     `json:"system,omitempty"`
+	Services   []ServiceStatus   `json:"services"`
+	Gateways   []GatewayStatus   `json:"gateways"`
+	Interfaces []InterfaceStatus `json:"interfaces"`
+}
+
+// Mark records a reason and raises the state if it is worse than the current one
+func (h *GatewayHealth) Mark(state string, reason string) {
+	h.Reasons = append(h.Reasons, reason)
+	if state == HealthDown || h.State == HealthHealthy {
+		h.State = state
+	}
+}
+
+// DHCPLease is a lease handed out by the pfSense DHCP server
+type DHCPLease struct {
+	IP           string `json:"ip"`
+	MAC          string `json:"mac"`
+	Hostname     string `json:"hostname"`
+	Interface    string `json:"if"`
+	Starts       string `json:"starts"`
+	Ends         string `json:"ends"`
+	ActiveStatus string `json:"active_status"`
+	OnlineStatus string `json:"online_status"`
+	Description  string `json:"descr"`
+}
+
+// ARPEntry is an entry of the pfSense ARP table
+type ARPEntry struct {
+	IP        string `json:"ip_address"`
+	MAC       string `json:"mac_address"`
+	Hostname  string `json:"hostname"`
+	Interface string `json:"interface"`
+	Type      string `json:"type"`
+	Expires   string `json:"expires"`
+}
+
+// LeaseTable contains the DHCP leases and ARP table of pfSense
+type LeaseTable struct {
+	Leases []DHCPLease `json:"leases"`
+	ARP    []ARPEntry  `json:"arp"`
+}
+
+// IPsByMAC maps lower-case MAC addresses to their current IP address.
+// Active DHCP leases take precedence over ARP entries.
+func (t *LeaseTable) IPsByMAC() map[string]string {
+	ips := map[string]string{}
+	for _, entry := range t.ARP {
+		if entry.MAC != "" && entry.IP != "" {
+			ips[strings.ToLower(entry.MAC)] = entry.IP
+		}
+	}
+	for _, lease := range t.Leases {
+		if lease.MAC != "" && lease.IP != "" && lease.ActiveStatus != "expired" {
+			ips[strings.ToLower(lease.MAC)] = lease.IP
+		}
+	}
+	return ips
+}
+
+// Network modes understood by SetNetworkMode
+const (
+	NetworkModeNormal   = "normal"
+	NetworkModeExam     = "exam"
+	NetworkModeIsolated = "isolated"
+	// NetworkModeCustom is reported when the rule states match none of the modes
+	NetworkModeCustom = "custom"
+)
+
+// FirewallRule is a pfSense firewall rule
+type FirewallRule struct {
+	ID          int      `json:"id"`
+	Tracker     int      `json:"tracker"`
+	Type        string   `json:"type"`
+	Interface   []string `json:"interface"`
+	Description string   `json:"descr"`
+	Disabled    bool     `json:"disabled"`
+}
+
+// NetworkMode describes a named set of firewall rules enabled together
+type NetworkMode struct {
+	Name  string         `json:"name"`
+	Rules []FirewallRule `json:"rules"`
+}
+
+// NetworkModes contains the available network modes and the one currently active
+type NetworkModes struct {
+	Current string        `json:"current"`
+	Modes   []NetworkMode `json:"modes"`
+}
+
+// IsNetworkMode reports whether name is a known network mode
+func IsNetworkMode(name string) bool {
+	switch name {
+	case NetworkModeNormal, NetworkModeExam, NetworkModeIsolated:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package platform
+
+// VMInfo contains information about a virtual machine
+type VMInfo struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Status      string   `json:"status"`
+	CPU         float64  `json:"cpu"`       // 当前 CPU 使用率
+	CPUs        float64  `json:"cpus"`      // 最大可用 CPU 数量
+	Memory      float64  `json:"mem"`       // 当前内存使用
+	MaxMem      int64    `json:"maxmem"`    // 最大内存 (字节)
+	Disk        float64  `json:"disk"`      // 当前磁盘使用率
+	MaxDisk     int64    `json:"maxdisk"`   // 根磁盘大小 (字节)
+	DiskRead    int64    `json:"diskread"`  // 总磁盘读取量 (字节)
+	DiskWrite   int64    `json:"diskwrite"` // 总磁盘写入量 (字节)
+	NetIn       int64    `json:"netin"`     // 总网络流入量 (字节)
+	NetOut      int64    `json:"netout"`    // 总网络流出量 (字节)
+	Uptime      int      `json:"uptime"`    // 运行时间 (秒)
+	Node        string   `json:"node"`
+	MACs        []string `json:"macs,omitempty"`         // 网卡 MAC 地址
+	LabIP       string   `json:"lab_ip,omitempty"`       // 从 pfSense DHCP/ARP 表中查到的 IP
+	IPs         []string `json:"ips,omitempty"`          // QEMU guest agent 报告的 IP
+	Hostname    string   `json:"hostname,omitempty"`     // QEMU guest agent 报告的主机名
+	OS          string   `json:"os,omitempty"`           // QEMU guest agent 报告的操作系统
+	AgentStatus string   `json:"agent_status,omitempty"` // QEMU guest agent 状态
+
+	Template bool `json:"-"` // 模板不属于实验环境, 只用于克隆实例
+}
+
+// SnapshotInfo contains information about a snapshot
+type SnapshotInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	SnapTime    int64  `json:"snaptime"`
+}
+
+// VMOperationResult contains the result of an operation
+type VMOperationResult struct {
+	VMID    string `json:"vmid"`
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// Guest agent states reported in VMInfo.AgentStatus
+const (
+	AgentStatusOK            = "ok"
+	AgentStatusNotRunning    = "not_running"
+	AgentStatusNotConfigured = "not_configured"
+	AgentStatusVMStopped     = "vm_stopped"
+	AgentStatusError         = "error"
+)
+
+// GuestInfo is what the QEMU guest agent reports about a running VM
+type GuestInfo struct {
+	Status   string   `json:"agent_status"`
+	Hostname string   `json:"hostname,omitempty"`
+	OS       string   `json:"os,omitempty"`
+	IPs      []string `json:"ips,omitempty"`
+}
+
+// Node states reported in NodeStatus.Status
+const (
+	NodeOnline      = "online"
+	NodeUnreachable = "unreachable"
+)
+
+// NodeStatus contains the resource usage and version of a Proxmox node
+type NodeStatus struct {
+	Node       string    `json:"node"`
+	Status     string    `json:"status"`
+	CPU        float64   `json:"cpu"`         // CPU 使用率 (0-1)
+	CPUs       int       `json:"cpus"`        // CPU 数量
+	Memory     int64     `json:"mem"`         // 已用内存 (字节)
+	MaxMem     int64     `json:"maxmem"`      // 总内存 (字节)
+	Uptime     int64     `json:"uptime"`      // 运行时间 (秒)
+	LoadAvg    []float64 `json:"loadavg"`     // 1, 5, 15 分钟平均负载
+	PVEVersion string    `json:"pve_version"` // 例如 pve-manager/8.2.4/faa83925c9641325
+	Kernel     string    `json:"kernel"`
+}
+
+// StorageInfo contains the usage of a storage on a node
+type StorageInfo struct {
+	Node         string   `json:"node"`
+	Storage      string   `json:"storage"`
+	Type         string   `json:"type"`
+	Content      []string `json:"content"`
+	Shared       bool     `json:"shared"`
+	Active       bool     `json:"active"`
+	Used         int64    `json:"used"`          // 已用 (字节)
+	Total        int64    `json:"total"`         // 总容量 (字节)
+	Avail        int64    `json:"avail"`         // 可用 (字节)
+	UsedFraction float64  `json:"used_fraction"` // 使用率 (0-1)
+	Warning      string   `json:"warning,omitempty"`
+}
+
+// RRD timeframes supported by the metrics endpoint
+const (
+	TimeframeHour = "hour"
+	TimeframeDay  = "day"
+	TimeframeWeek = "week"
+)
+
+// RRD consolidation functions
+const (
+	ConsolidationAverage = "AVERAGE"
+	ConsolidationMax     = "MAX"
+)
+
+// MetricPoint is a single RRD sample. Fields are null where Proxmox has no data, e.g. while the VM was stopped.
+type MetricPoint struct {
+	Time      int64    `json:"time"`      // Unix 时间戳
+	CPU       *float64 `json:"cpu"`       // CPU 使用率 (0-1)
+	MaxCPU    *float64 `json:"maxcpu"`    // CPU 数量
+	Mem       *float64 `json:"mem"`       // 内存使用 (字节)
+	MaxMem    *float64 `json:"maxmem"`    // 最大内存 (字节)
+	DiskRead  *float64 `json:"diskread"`  // 磁盘读取速率 (字节/秒)
+	DiskWrite *float64 `json:"diskwrite"` // 磁盘写入速率 (字节/秒)
+	NetIn     *float64 `json:"netin"`     // 网络流入速率 (字节/秒)
+	NetOut    *float64 `json:"netout"`    // 网络流出速率 (字节/秒)
+}
+
+// VMMetrics is the resource usage history of a VM
+type VMMetrics struct {
+	VMID          string        `json:"vmid"`
+	Node          string        `json:"node"`
+	Timeframe     string        `json:"timeframe"`
+	Consolidation string        `json:"consolidation"`
+	Points        []MetricPoint `json:"points"`
+}
+
+// IsTimeframe reports whether timeframe is supported by GetVMMetrics
+func IsTimeframe(timeframe string) bool {
+	switch timeframe {
+	case TimeframeHour, TimeframeDay, TimeframeWeek:
+		return true
+	}
+	return false
+}
+
+// Pool is a resource pool, holding the VMs of a lab instance
+type Pool struct {
+	ID      string `json:"poolid"`
+	Comment string `json:"comment"`
+}
+
+// VNCTicket is a VNC proxy opened on a node. The ticket is also the VNC password.
+type VNCTicket struct {
+	Port   string
+	Ticket string
+}
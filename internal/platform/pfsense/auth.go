@@ -0,0 +1,120 @@
+package pfsense
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+)
+
+// jwtRefreshMargin is how long before expiry a JWT is considered stale and refreshed
+const jwtRefreshMargin = 60 * time.Second
+
+// jwtFallbackLifetime is assumed when the token carries no readable exp claim
+const jwtFallbackLifetime = 10 * time.Minute
+
+// jwtToken caches the JWT obtained from the pfSense REST API
+type jwtToken struct {
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+type pfsenseJWTResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    struct {
+		Token string `json:"token"`
+	} `json:"data"`
+}
+
+// authorize adds the configured credentials to an outgoing request
+func (c *PfsenseClient) authorize(ctx context.Context, req *http.Request) error {
+	switch c.AuthMode {
+	case config.PfsenseAuthKey:
+		req.Header.Set("X-API-Key", c.APIKey)
+	case config.PfsenseAuthJWT:
+		token, err := c.getJWT(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	default:
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	return nil
+}
+
+// getJWT returns a cached JWT, requesting a new one when it is missing or about to expire
+func (c *PfsenseClient) getJWT(ctx context.Context) (string, error) {
+	c.jwt.mu.Lock()
+	defer c.jwt.mu.Unlock()
+
+	if c.jwt.token != "" && time.Now().Add(jwtRefreshMargin).Before(c.jwt.expires) {
+		return c.jwt.token, nil
+	}
+
+	resp, err := c.policy.Do(ctx, c.client, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/api/v2/auth/jwt", c.BaseURL), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(c.Username, c.Password)
+		return req, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to request JWT: %w", err)
+	}
+
+	var response pfsenseJWTResponse
+	err = json.Unmarshal(resp.Body, &response)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode JWT response: %w", err)
+	}
+
+	if response.Code != 200 || response.Data.Token == "" {
+		return "", fmt.Errorf("failed to obtain JWT: %s", response.Message)
+	}
+
+	c.jwt.token = response.Data.Token
+	c.jwt.expires = jwtExpiry(response.Data.Token)
+
+	return c.jwt.token, nil
+}
+
+// invalidateJWT drops the cached JWT so the next request obtains a fresh one
+func (c *PfsenseClient) invalidateJWT() {
+	c.jwt.mu.Lock()
+	c.jwt.token = ""
+	c.jwt.mu.Unlock()
+}
+
+// jwtExpiry reads the exp claim of a JWT without verifying its signature
+func jwtExpiry(token string) time.Time {
+	fallback := time.Now().Add(jwtFallbackLifetime)
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fallback
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fallback
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return fallback
+	}
+
+	return time.Unix(claims.Exp, 0)
+}
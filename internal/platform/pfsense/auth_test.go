@@ -0,0 +1,74 @@
+package pfsense
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/fake"
+)
+
+// jwtRequests counts the JWTs requested from the fake so far
+func jwtRequests(pfsense *fake.Pfsense) int {
+	count := 0
+	for _, request := range pfsense.Requests() {
+		if request == "POST /api/v2/auth/jwt" {
+			count++
+		}
+	}
+	return count
+}
+
+func TestJWTRenewal(t *testing.T) {
+	pfsense := fake.NewPfsense()
+	server := httptest.NewServer(pfsense)
+	defer server.Close()
+
+	client := &PfsenseClient{
+		BaseURL:  server.URL,
+		Username: "admin",
+		Password: "pfsense",
+		AuthMode: config.PfsenseAuthJWT,
+		client:   server.Client(),
+	}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetOpenVPNConnections(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := jwtRequests(pfsense); n != 1 {
+		t.Fatalf("%d JWTs requested for two calls, want the first one reused", n)
+	}
+
+	// A token about to expire is refreshed before it is used
+	client.jwt.mu.Lock()
+	client.jwt.expires = time.Now().Add(jwtRefreshMargin / 2)
+	client.jwt.mu.Unlock()
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetOpenVPNConnections(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := jwtRequests(pfsense); n != 2 {
+		t.Fatalf("%d JWTs requested, want one refresh before the token expired", n)
+	}
+
+	// A rejected token is dropped and replaced on the next request
+	pfsense.Inject(fake.Fault{Path: "/api/v2/status/openvpn/servers", Status: http.StatusUnauthorized, Times: 1})
+	if _, err := client.GetOpenVPNConnections(ctx); err == nil {
+		t.Fatal("expected the rejected request to fail")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetOpenVPNConnections(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := jwtRequests(pfsense); n != 3 {
+		t.Errorf("%d JWTs requested, want one new token after the 401", n)
+	}
+}
@@ -1,31 +1,36 @@
 package pfsense
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 
 	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform/upstream"
 )
 
 type PfsenseClient struct {
 	BaseURL  string
 	Username string
 	Password string
+	AuthMode string
+	APIKey   string
 	client   *http.Client
+	policy   upstream.Policy
+	jwt      jwtToken
+	modes    map[string][]string
 }
 
-type PfsenseOpenVPNConnection struct {
-	Id          int    `json:"id"`
-	Name        string `json:"common_name"`
-	ConnectTime uint64 `json:"connect_time_unix"`
-}
+var _ platform.VPNGateway = (*PfsenseClient)(nil)
 
 type PfSenseOpenVPNServer struct {
-	Id          int                        `json:"id"`
-	Name        string                     `json:"name"`
-	Connections []PfsenseOpenVPNConnection `json:"conns"`
+	Id          int                      `json:"id"`
+	Name        string                   `json:"name"`
+	Connections []platform.VPNConnection `json:"conns"`
 }
 
 type PfsenseOpenVPNServerResponse struct {
@@ -36,53 +41,114 @@ type PfsenseOpenVPNServerResponse struct {
 	Data       []PfSenseOpenVPNServer `json:"data"`
 }
 
-func NewPfsenseClient(config *config.Config) *PfsenseClient {
+// pfsenseResponse is the envelope wrapping every pfSense REST API response
+type pfsenseResponse struct {
+	Code       int             `json:"code"`
+	Status     string          `json:"status"`
+	ResponseID string          `json:"response_id"`
+	Message    string          `json:"message"`
+	Data       json.RawMessage `json:"data"`
+}
+
+func NewPfsenseClient(config *config.Config) (*PfsenseClient, error) {
+	client, _, err := upstream.NewHTTPClient(upstream.TLSOptions{
+		CAFile:      config.GetPfsenseCAFile(),
+		Fingerprint: config.GetPfsenseFingerprint(),
+		Insecure:    config.GetPfsenseInsecure(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure pfSense TLS: %w", err)
+	}
+
 	return &PfsenseClient{
 		BaseURL:  config.GetPfsenseURL(),
 		Username: config.GetPfsenseUsername(),
 		Password: config.GetPfsensePassword(),
-		client:   &http.Client{},
-	}
+		AuthMode: config.GetPfsenseAuthMode(),
+		APIKey:   config.GetPfsenseAPIKey(),
+		client:   client,
+		policy: upstream.Policy{
+			Timeout: config.GetPfsenseTimeout(),
+			Retries: config.GetUpstreamRetries(),
+			Backoff: config.GetUpstreamRetryBackoff(),
+		},
+		modes: config.GetPfsenseModeRules(),
+	}, nil
 }
 
-func (c *PfsenseClient) makeRequest(method, path string, body io.Reader) (*http.Response, error) {
-	req, err := http.NewRequest(method, fmt.Sprintf("%s%s", c.BaseURL, path), body)
+func (c *PfsenseClient) makeRequest(ctx context.Context, method, path string, body []byte) (*upstream.Response, error) {
+	resp, err := c.policy.Do(ctx, c.client, func(ctx context.Context) (*http.Request, error) {
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s%s", c.BaseURL, path), reqBody)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if err := c.authorize(ctx, req); err != nil {
+			return nil, err
+		}
+		return req, nil
+	})
 	if err != nil {
-		return nil, err
+		return nil, &upstream.Error{Source: upstream.SourcePfsense, Err: err}
 	}
-	req.SetBasicAuth(c.Username, c.Password)
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, err
+	// A rejected JWT is dropped so that the next request authenticates again
+	if resp.StatusCode == http.StatusUnauthorized && c.AuthMode == config.PfsenseAuthJWT {
+		c.invalidateJWT()
 	}
 	return resp, nil
 }
 
-// GetOpenVPNConnections returns a list of OpenVPN connections to the Pfsense OpenVPN server
-func (c *PfsenseClient) GetOpenVPNConnections() ([]PfsenseOpenVPNConnection, error) {
-	resp, err := c.makeRequest("GET", "/api/v2/status/openvpn/servers", nil)
-	if err != nil {
-		return nil, err
+// doRequest sends a JSON request and decodes the data field of the response envelope into out
+func (c *PfsenseClient) doRequest(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		reqBody = jsonData
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	resp, err := c.makeRequest(ctx, method, path, reqBody)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var response PfsenseOpenVPNServerResponse
-	err = json.Unmarshal(body, &response)
+	var response pfsenseResponse
+	err = json.Unmarshal(resp.Body, &response)
 	if err != nil {
-		return nil, err
+		return &upstream.Error{Source: upstream.SourcePfsense, StatusCode: resp.StatusCode, Detail: fmt.Sprintf("invalid response: %v", err)}
 	}
 
 	if response.Code != 200 {
-		return nil, fmt.Errorf("failed to get OpenVPN clients: %s", response.Message)
+		return &upstream.Error{Source: upstream.SourcePfsense, StatusCode: response.Code, Detail: response.Message}
+	}
+
+	if out != nil && len(response.Data) > 0 {
+		if err := json.Unmarshal(response.Data, out); err != nil {
+			return fmt.Errorf("failed to decode response data: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetOpenVPNConnections returns a list of OpenVPN connections to the Pfsense OpenVPN server
+func (c *PfsenseClient) GetOpenVPNConnections(ctx context.Context) ([]platform.VPNConnection, error) {
+	var servers []PfSenseOpenVPNServer
+	err := c.doRequest(ctx, "GET", "/api/v2/status/openvpn/servers", nil, &servers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get OpenVPN clients: %w", err)
 	}
 
-	connections := []PfsenseOpenVPNConnection{}
-	for _, server := range response.Data {
+	connections := []platform.VPNConnection{}
+	for _, server := range servers {
 		if server.Connections != nil {
 			connections = append(connections, server.Connections...)
 		}
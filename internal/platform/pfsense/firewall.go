@@ -0,0 +1,170 @@
+package pfsense
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+)
+
+// GetFirewallRules returns all firewall rules
+func (c *PfsenseClient) GetFirewallRules(ctx context.Context) ([]platform.FirewallRule, error) {
+	var rules []platform.FirewallRule
+	err := c.doRequest(ctx, "GET", "/api/v2/firewall/rules", nil, &rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get firewall rules: %w", err)
+	}
+	return rules, nil
+}
+
+// SetFirewallRuleDisabled enables or disables a firewall rule. Changes only take effect after ApplyFirewall.
+func (c *PfsenseClient) SetFirewallRuleDisabled(ctx context.Context, id int, disabled bool) error {
+	body := map[string]interface{}{
+		"id":       id,
+		"disabled": disabled,
+	}
+	err := c.doRequest(ctx, "PATCH", "/api/v2/firewall/rule", body, nil)
+	if err != nil {
+		return fmt.Errorf("failed to update firewall rule %d: %w", id, err)
+	}
+	return nil
+}
+
+// ApplyFirewall applies pending firewall changes
+func (c *PfsenseClient) ApplyFirewall(ctx context.Context) error {
+	err := c.doRequest(ctx, "POST", "/api/v2/firewall/apply", nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to apply firewall changes: %w", err)
+	}
+	return nil
+}
+
+// GetNetworkModes returns the configured network modes and detects the active one from the rule states
+func (c *PfsenseClient) GetNetworkModes(ctx context.Context) (*platform.NetworkModes, error) {
+	rules, err := c.GetFirewallRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	modes := &platform.NetworkModes{Current: platform.NetworkModeCustom}
+	for _, name := range []string{platform.NetworkModeNormal, platform.NetworkModeExam, platform.NetworkModeIsolated} {
+		mode := platform.NetworkMode{Name: name, Rules: []platform.FirewallRule{}}
+		wanted := c.modeRuleSet(name, rules)
+		for _, rule := range rules {
+			if wanted[rule.ID] {
+				mode.Rules = append(mode.Rules, rule)
+			}
+		}
+		modes.Modes = append(modes.Modes, mode)
+
+		if modes.Current == platform.NetworkModeCustom && c.modeActive(wanted, rules) {
+			modes.Current = name
+		}
+	}
+
+	return modes, nil
+}
+
+// SetNetworkMode enables the rules of the given mode, disables every other managed rule and applies the changes
+func (c *PfsenseClient) SetNetworkMode(ctx context.Context, name string) error {
+	if !platform.IsNetworkMode(name) {
+		return fmt.Errorf("unknown network mode %q", name)
+	}
+
+	rules, err := c.GetFirewallRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	wanted := c.modeRuleSet(name, rules)
+	managed := c.managedRuleSet(rules)
+
+	var changed []platform.FirewallRule
+	for _, rule := range rules {
+		if !managed[rule.ID] {
+			continue
+		}
+		disabled := !wanted[rule.ID]
+		if rule.Disabled == disabled {
+			continue
+		}
+		if err := c.SetFirewallRuleDisabled(ctx, rule.ID, disabled); err != nil {
+			return c.revertRules(ctx, changed, err)
+		}
+		changed = append(changed, rule)
+	}
+
+	if len(changed) == 0 {
+		return nil
+	}
+
+	if err := c.ApplyFirewall(ctx); err != nil {
+		return c.revertRules(ctx, changed, err)
+	}
+	return nil
+}
+
+// revertRules restores the rules changed before a mode switch failed, or whose changes could not be applied,
+// so that their pending changes are not applied later by someone else. The rules keep the state they had before the switch.
+func (c *PfsenseClient) revertRules(ctx context.Context, changed []platform.FirewallRule, cause error) error {
+	errs := []error{cause}
+	for _, rule := range changed {
+		if err := c.SetFirewallRuleDisabled(ctx, rule.ID, rule.Disabled); err != nil {
+			errs = append(errs, fmt.Errorf("failed to revert: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// modeActive reports whether exactly the wanted managed rules are enabled
+func (c *PfsenseClient) modeActive(wanted map[int]bool, rules []platform.FirewallRule) bool {
+	managed := c.managedRuleSet(rules)
+	for _, rule := range rules {
+		if managed[rule.ID] && rule.Disabled == wanted[rule.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+// modeRuleSet returns the IDs of the rules a mode enables. The normal mode enables none of them.
+func (c *PfsenseClient) modeRuleSet(name string, rules []platform.FirewallRule) map[int]bool {
+	set := map[int]bool{}
+	for _, rule := range rules {
+		if matchRule(rule, c.modes[name]) {
+			set[rule.ID] = true
+		}
+	}
+	return set
+}
+
+// managedRuleSet returns the IDs of all rules referenced by any mode
+func (c *PfsenseClient) managedRuleSet(rules []platform.FirewallRule) map[int]bool {
+	set := map[int]bool{}
+	for name := range c.modes {
+		for id := range c.modeRuleSet(name, rules) {
+			set[id] = true
+		}
+	}
+	return set
+}
+
+// matchRule reports whether a rule is selected by one of the selectors. Numeric selectors match the tracker ID;
+// anything else matches a description equal to it or tagged with it in brackets, e.g. "exam" matches "Block WAN [exam]".
+func matchRule(rule platform.FirewallRule, selectors []string) bool {
+	for _, selector := range selectors {
+		if tracker, err := strconv.Atoi(selector); err == nil {
+			if rule.Tracker == tracker {
+				return true
+			}
+			continue
+		}
+		if rule.Description == selector || strings.Contains(rule.Description, "["+selector+"]") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package pfsense
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+)
+
+func TestMatchRule(t *testing.T) {
+	tests := []struct {
+		description string
+		tracker     int
+		selector    string
+		want        bool
+	}{
+		{"Block internet [exam]", 1, "exam", true},
+		{"exam", 1, "exam", true},
+		{"Allow exam portal", 1, "exam", false},
+		{"Block internet [examiner]", 1, "exam", false},
+		{"Block internet", 1700000001, "1700000001", true},
+		{"1700000001", 1, "1700000001", false},
+	}
+	for _, tt := range tests {
+		rule := platform.FirewallRule{Description: tt.description, Tracker: tt.tracker}
+		if got := matchRule(rule, []string{tt.selector}); got != tt.want {
+			t.Errorf("matchRule(%q, %q) = %v, want %v", tt.description, tt.selector, got, tt.want)
+		}
+	}
+}
+
+// firewall serves the firewall endpoints of pfSense, failing the PATCH requests listed in fail (counted from 1)
+// and, with failApply, every apply
+type firewall struct {
+	mu        sync.Mutex
+	rules     []platform.FirewallRule
+	patches   int
+	fail      map[int]bool
+	failApply bool
+	applied   bool
+}
+
+func (f *firewall) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var data interface{}
+	switch r.Method + " " + r.URL.Path {
+	case "GET /api/v2/firewall/rules":
+		data = f.rules
+	case "PATCH /api/v2/firewall/rule":
+		f.patches++
+		if f.fail[f.patches] {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 500, "message": "failed"})
+			return
+		}
+		var body struct {
+			ID       int  `json:"id"`
+			Disabled bool `json:"disabled"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.rules[body.ID].Disabled = body.Disabled
+	case "POST /api/v2/firewall/apply":
+		if f.failApply {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 500, "message": "filter reload failed"})
+			return
+		}
+		f.applied = true
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "status": "ok", "data": data})
+}
+
+func TestSetNetworkModeRevertsOnFailure(t *testing.T) {
+	fw := &firewall{
+		rules: []platform.FirewallRule{
+			{ID: 0, Description: "Block internet [exam]", Disabled: true},
+			{ID: 1, Description: "Block LAN [exam]", Disabled: true},
+		},
+		fail: map[int]bool{2: true},
+	}
+	server := httptest.NewServer(fw)
+	defer server.Close()
+
+	client := &PfsenseClient{
+		BaseURL:  server.URL,
+		AuthMode: "key",
+		APIKey:   "key",
+		client:   server.Client(),
+		modes:    map[string][]string{platform.NetworkModeExam: {"exam"}},
+	}
+	if err := client.SetNetworkMode(context.Background(), platform.NetworkModeExam); err == nil {
+		t.Fatal("expected the mode switch to fail")
+	}
+
+	if !fw.rules[0].Disabled || !fw.rules[1].Disabled {
+		t.Errorf("rules %+v, want both reverted to disabled", fw.rules)
+	}
+	if fw.applied {
+		t.Error("changes were applied after the switch failed")
+	}
+}
+
+func TestSetNetworkModeRevertsOnApplyFailure(t *testing.T) {
+	fw := &firewall{
+		rules: []platform.FirewallRule{
+			{ID: 0, Description: "Block internet [exam]", Disabled: true},
+			{ID: 1, Description: "Block VPN clients [isolated]", Disabled: false},
+		},
+		failApply: true,
+	}
+	server := httptest.NewServer(fw)
+	defer server.Close()
+
+	client := &PfsenseClient{
+		BaseURL:  server.URL,
+		AuthMode: "key",
+		APIKey:   "key",
+		client:   server.Client(),
+		modes: map[string][]string{
+			platform.NetworkModeExam:     {"exam"},
+			platform.NetworkModeIsolated: {"isolated"},
+		},
+	}
+	if err := client.SetNetworkMode(context.Background(), platform.NetworkModeExam); err == nil {
+		t.Fatal("expected the mode switch to fail")
+	}
+
+	// Both rules were changed, then restored so that no half-finished switch is left pending
+	if fw.patches != 4 || !fw.rules[0].Disabled || fw.rules[1].Disabled {
+		t.Errorf("rules %+v after %d updates, want both changed and reverted", fw.rules, fw.patches)
+	}
+}
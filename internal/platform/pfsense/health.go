@@ -0,0 +1,135 @@
+package pfsense
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+)
+
+// healthServices are the services the lab depends on. A stopped openvpn service takes the lab down.
+var healthServices = []string{"openvpn", "unbound", "dhcpd"}
+
+// healthUsageLimit is the CPU and memory usage percentage above which pfSense is considered degraded
+const healthUsageLimit = 90.0
+
+// GetSystemStatus returns the pfSense system status
+func (c *PfsenseClient) GetSystemStatus(ctx context.Context) (*platform.SystemStatus, error) {
+	var status platform.SystemStatus
+	err := c.doRequest(ctx, "GET", "/api/v2/status/system", nil, &status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get system status: %w", err)
+	}
+	return &status, nil
+}
+
+// GetServices returns the status of all pfSense services
+func (c *PfsenseClient) GetServices(ctx context.Context) ([]platform.ServiceStatus, error) {
+	var services []platform.ServiceStatus
+	err := c.doRequest(ctx, "GET", "/api/v2/status/services", nil, &services)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get services: %w", err)
+	}
+	return services, nil
+}
+
+// GetGateways returns the status of all gateways
+func (c *PfsenseClient) GetGateways(ctx context.Context) ([]platform.GatewayStatus, error) {
+	var gateways []platform.GatewayStatus
+	err := c.doRequest(ctx, "GET", "/api/v2/status/gateways", nil, &gateways)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gateways: %w", err)
+	}
+	return gateways, nil
+}
+
+// GetInterfaces returns the status and counters of all interfaces
+func (c *PfsenseClient) GetInterfaces(ctx context.Context) ([]platform.InterfaceStatus, error) {
+	var interfaces []platform.InterfaceStatus
+	err := c.doRequest(ctx, "GET", "/api/v2/status/interfaces", nil, &interfaces)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get interfaces: %w", err)
+	}
+	return interfaces, nil
+}
+
+// GetHealth queries system, service, gateway and interface status and summarises them.
+// Upstream failures are reported in the summary instead of being returned as errors.
+func (c *PfsenseClient) GetHealth(ctx context.Context) *platform.GatewayHealth {
+	health := &platform.GatewayHealth{
+		State:      platform.HealthHealthy,
+		Reasons:    []string{},
+		Services:   []platform.ServiceStatus{},
+		Gateways:   []platform.GatewayStatus{},
+		Interfaces: []platform.InterfaceStatus{},
+	}
+
+	system, err := c.GetSystemStatus(ctx)
+	if err != nil {
+		health.Mark(platform.HealthDown, err.Error())
+		return health
+	}
+	health.System = system
+	if system.CPUUsage >= healthUsageLimit {
+		health.Mark(platform.HealthDegraded, fmt.Sprintf("CPU usage at %.0f%%", system.CPUUsage))
+	}
+	if system.MemUsage >= healthUsageLimit {
+		health.Mark(platform.HealthDegraded, fmt.Sprintf("memory usage at %.0f%%", system.MemUsage))
+	}
+
+	services, err := c.GetServices(ctx)
+	if err != nil {
+		health.Mark(platform.HealthDegraded, err.Error())
+	}
+	for _, name := range healthServices {
+		found := false
+		for _, service := range services {
+			if service.Name != name {
+				continue
+			}
+			found = true
+			health.Services = append(health.Services, service)
+			if service.Enabled && !service.Status {
+				state := platform.HealthDegraded
+				if name == "openvpn" {
+					state = platform.HealthDown
+				}
+				health.Mark(state, fmt.Sprintf("service %s (%s) is stopped", name, service.Description))
+			}
+		}
+		if !found && err == nil {
+			health.Mark(platform.HealthDegraded, fmt.Sprintf("service %s not found", name))
+		}
+	}
+
+	gateways, err := c.GetGateways(ctx)
+	if err != nil {
+		health.Mark(platform.HealthDegraded, err.Error())
+	}
+	online := 0
+	for _, gateway := range gateways {
+		health.Gateways = append(health.Gateways, gateway)
+		if strings.EqualFold(gateway.Status, "online") || strings.EqualFold(gateway.Status, "none") {
+			online++
+			continue
+		}
+		health.Mark(platform.HealthDegraded, fmt.Sprintf("gateway %s is %s", gateway.Name, gateway.Status))
+	}
+	if len(gateways) > 0 && online == 0 {
+		health.Mark(platform.HealthDown, "all gateways are down")
+	}
+
+	interfaces, err := c.GetInterfaces(ctx)
+	if err != nil {
+		health.Mark(platform.HealthDegraded, err.Error())
+	}
+	for _, iface := range interfaces {
+		health.Interfaces = append(health.Interfaces, iface)
+		if iface.Enabled && !strings.EqualFold(iface.Status, "up") {
+			health.Mark(platform.HealthDegraded, fmt.Sprintf("interface %s (%s) is %s", iface.Descr, iface.HWIF, iface.Status))
+		}
+	}
+
+	return health
+}
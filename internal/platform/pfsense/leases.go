@@ -0,0 +1,43 @@
+package pfsense
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+)
+
+// GetDHCPLeases returns the DHCP server leases
+func (c *PfsenseClient) GetDHCPLeases(ctx context.Context) ([]platform.DHCPLease, error) {
+	leases := []platform.DHCPLease{}
+	err := c.doRequest(ctx, "GET", "/api/v2/status/dhcp_server/leases", nil, &leases)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DHCP leases: %w", err)
+	}
+	return leases, nil
+}
+
+// GetARPTable returns the ARP table
+func (c *PfsenseClient) GetARPTable(ctx context.Context) ([]platform.ARPEntry, error) {
+	entries := []platform.ARPEntry{}
+	err := c.doRequest(ctx, "GET", "/api/v2/diagnostics/arp_table", nil, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ARP table: %w", err)
+	}
+	return entries, nil
+}
+
+// GetLeaseTable returns both the DHCP leases and the ARP table
+func (c *PfsenseClient) GetLeaseTable(ctx context.Context) (*platform.LeaseTable, error) {
+	leases, err := c.GetDHCPLeases(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	arp, err := c.GetARPTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &platform.LeaseTable{Leases: leases, ARP: arp}, nil
+}
@@ -0,0 +1,73 @@
+// Package platform defines the infrastructure the dashboard drives and the types it exchanges with it,
+// so that controllers and lab jobs do not depend on a particular client and can be given another backend or a mock.
+package platform
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Hypervisor runs the lab VMs. It is implemented by *proxmox.PVEClient.
+// Power actions return the ID of a task that can be waited for with WaitForTask.
+type Hypervisor interface {
+	GetNodes(ctx context.Context) ([]string, error)
+	GetNodeStatuses(ctx context.Context) ([]NodeStatus, error)
+	GetStorage(ctx context.Context) ([]StorageInfo, error)
+
+	GetVMs(ctx context.Context) ([]VMInfo, error)
+	FindVM(ctx context.Context, vmID string) (*VMInfo, error)
+	GetVMMACs(ctx context.Context, node string, vmID string) ([]string, error)
+	GetVMMetrics(ctx context.Context, node string, vmID string, timeframe string, consolidation string) (*VMMetrics, error)
+	AddGuestInfo(ctx context.Context, vms []VMInfo)
+	PingAgent(ctx context.Context, node string, vmID string) string
+
+	StartVM(ctx context.Context, node string, vmID string) (string, error)
+	StopVM(ctx context.Context, node string, vmID string) (string, error)
+	ShutdownVM(ctx context.Context, node string, vmID string, timeout time.Duration, forceStop bool) (string, error)
+	WaitForTask(ctx context.Context, node string, upid string) error
+
+	StopAllVMs(ctx context.Context) ([]VMOperationResult, error)
+	ResetAllVMs(ctx context.Context) ([]VMOperationResult, error)
+	SuspendAllVMs(ctx context.Context) ([]VMOperationResult, error)
+	ResumeAllVMs(ctx context.Context) ([]VMOperationResult, error)
+
+	ResetLab(ctx context.Context) ([]VMOperationResult, error)
+	GetLastReset() (uint64, error)
+
+	Consoles
+	Pools
+}
+
+// Consoles opens VNC consoles of the VMs
+type Consoles interface {
+	// OpenVNCProxy opens a VNC proxy that must be dialled within a few seconds
+	OpenVNCProxy(ctx context.Context, node string, vmID string) (*VNCTicket, error)
+	// DialVNCWebSocket connects to a proxy opened by OpenVNCProxy and returns the RFB stream
+	DialVNCWebSocket(ctx context.Context, node string, vmID string, ticket *VNCTicket) (io.ReadWriteCloser, error)
+}
+
+// Pools holds the lab instances: pools of linked clones of the templates, each in its own VLAN
+type Pools interface {
+	GetPools(ctx context.Context) ([]Pool, error)
+	GetPoolVMs(ctx context.Context, pool string) ([]VMInfo, error)
+	CreatePool(ctx context.Context, pool string, comment string) error
+	SetPoolComment(ctx context.Context, pool string, comment string) error
+	DeletePool(ctx context.Context, pool string) error
+
+	FindTemplate(ctx context.Context, vmID string) (*VMInfo, error)
+	NextVMID(ctx context.Context) (string, error)
+	CloneVM(ctx context.Context, node string, templateID string, newID string, name string, pool string) (string, error)
+	SetVMNetwork(ctx context.Context, node string, vmID string, bridge string, vlan int) error
+	DeleteVM(ctx context.Context, node string, vmID string) (string, error)
+}
+
+// VPNGateway is the firewall students reach the lab through. It is implemented by *pfsense.PfsenseClient.
+type VPNGateway interface {
+	GetOpenVPNConnections(ctx context.Context) ([]VPNConnection, error)
+	GetSystemStatus(ctx context.Context) (*SystemStatus, error)
+	GetHealth(ctx context.Context) *GatewayHealth
+	GetLeaseTable(ctx context.Context) (*LeaseTable, error)
+	GetNetworkModes(ctx context.Context) (*NetworkModes, error)
+	SetNetworkMode(ctx context.Context, name string) error
+}
@@ -0,0 +1,204 @@
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform/upstream"
+)
+
+type agentCacheEntry struct {
+	info    *platform.GuestInfo
+	fetched time.Time
+}
+
+// agentCache keeps guest agent results per VMID so that polling the VM list does not query every agent each time
+type agentCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]agentCacheEntry
+}
+
+func (a *agentCache) get(vmID string) (*platform.GuestInfo, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entry, ok := a.entries[vmID]
+	if !ok || time.Since(entry.fetched) >= a.ttl {
+		return nil, false
+	}
+	return entry.info, true
+}
+
+func (a *agentCache) put(vmID string, info *platform.GuestInfo) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries[vmID] = agentCacheEntry{info: info, fetched: time.Now()}
+}
+
+// agentRequest runs a guest agent command and decodes its result into out
+func (c *PVEClient) agentRequest(ctx context.Context, node string, vmID string, command string, out interface{}) error {
+	respBody, err := c.makeRequest(ctx, "GET", fmt.Sprintf("/nodes/%s/qemu/%s/agent/%s", node, vmID, command), nil)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		Data struct {
+			Result json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+
+	err = json.Unmarshal(respBody, &result)
+	if err != nil {
+		return fmt.Errorf("failed to decode agent %s response: %w", command, err)
+	}
+
+	return json.Unmarshal(result.Data.Result, out)
+}
+
+// GetGuestIPs returns the non-loopback, non-link-local addresses reported by the guest agent
+func (c *PVEClient) GetGuestIPs(ctx context.Context, node string, vmID string) ([]string, error) {
+	var interfaces []struct {
+		Name        string `json:"name"`
+		IPAddresses []struct {
+			Address string `json:"ip-address"`
+		} `json:"ip-addresses"`
+	}
+
+	err := c.agentRequest(ctx, node, vmID, "network-get-interfaces", &interfaces)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guest network interfaces: %w", err)
+	}
+
+	ips := []string{}
+	for _, iface := range interfaces {
+		for _, addr := range iface.IPAddresses {
+			ip := net.ParseIP(addr.Address)
+			if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+				continue
+			}
+			ips = append(ips, addr.Address)
+		}
+	}
+
+	return ips, nil
+}
+
+// GetGuestOS returns the pretty OS name reported by the guest agent
+func (c *PVEClient) GetGuestOS(ctx context.Context, node string, vmID string) (string, error) {
+	var osInfo struct {
+		Name       string `json:"name"`
+		PrettyName string `json:"pretty-name"`
+		Version    string `json:"version"`
+	}
+
+	err := c.agentRequest(ctx, node, vmID, "get-osinfo", &osInfo)
+	if err != nil {
+		return "", fmt.Errorf("failed to get guest OS info: %w", err)
+	}
+
+	if osInfo.PrettyName != "" {
+		return osInfo.PrettyName, nil
+	}
+	return strings.TrimSpace(osInfo.Name + " " + osInfo.Version), nil
+}
+
+// GetGuestHostname returns the hostname reported by the guest agent
+func (c *PVEClient) GetGuestHostname(ctx context.Context, node string, vmID string) (string, error) {
+	var hostname struct {
+		HostName string `json:"host-name"`
+	}
+
+	err := c.agentRequest(ctx, node, vmID, "get-host-name", &hostname)
+	if err != nil {
+		return "", fmt.Errorf("failed to get guest hostname: %w", err)
+	}
+
+	return hostname.HostName, nil
+}
+
+// GetGuestInfo returns the cached guest agent information of a VM, querying the agent when the cache is stale.
+// Agent failures are reported in the status rather than returned, since most of them just mean the guest is still booting.
+func (c *PVEClient) GetGuestInfo(ctx context.Context, node string, vmID string) *platform.GuestInfo {
+	if info, ok := c.agents.get(vmID); ok {
+		return info
+	}
+
+	info := &platform.GuestInfo{Status: platform.AgentStatusOK}
+
+	hostname, err := c.GetGuestHostname(ctx, node, vmID)
+	if err != nil {
+		info.Status = agentStatus(err)
+		// Do not cache failures caused by the caller going away
+		if ctx.Err() == nil {
+			c.agents.put(vmID, info)
+		}
+		return info
+	}
+	info.Hostname = hostname
+
+	// The hostname answered, so the remaining calls only fail on real errors which leave their fields empty
+	if ips, err := c.GetGuestIPs(ctx, node, vmID); err == nil {
+		info.IPs = ips
+	}
+	if osName, err := c.GetGuestOS(ctx, node, vmID); err == nil {
+		info.OS = osName
+	}
+
+	if ctx.Err() == nil {
+		c.agents.put(vmID, info)
+	}
+	return info
+}
+
+// PingAgent checks whether the guest agent of a running VM responds and returns its agent status
+func (c *PVEClient) PingAgent(ctx context.Context, node string, vmID string) string {
+	_, err := c.makeRequest(ctx, "POST", fmt.Sprintf("/nodes/%s/qemu/%s/agent/ping", node, vmID), nil)
+	if err != nil {
+		return agentStatus(err)
+	}
+	return platform.AgentStatusOK
+}
+
+// AddGuestInfo fills the guest agent fields of the given VMs, querying running VMs concurrently
+func (c *PVEClient) AddGuestInfo(ctx context.Context, vms []platform.VMInfo) {
+	var wg sync.WaitGroup
+	for i := range vms {
+		if vms[i].Status != "running" {
+			vms[i].AgentStatus = platform.AgentStatusVMStopped
+			continue
+		}
+		wg.Add(1)
+		go func(vm *platform.VMInfo) {
+			defer wg.Done()
+			info := c.GetGuestInfo(ctx, vm.Node, vm.ID)
+			vm.AgentStatus = info.Status
+			vm.Hostname = info.Hostname
+			vm.OS = info.OS
+			vm.IPs = info.IPs
+		}(&vms[i])
+	}
+	wg.Wait()
+}
+
+// agentStatus maps a guest agent error to an agent status
+func agentStatus(err error) string {
+	var upstreamErr *upstream.Error
+	if errors.As(err, &upstreamErr) && upstreamErr.StatusCode != 0 {
+		detail := strings.ToLower(upstreamErr.Detail)
+		switch {
+		case strings.Contains(detail, "not running"):
+			return platform.AgentStatusNotRunning
+		case strings.Contains(detail, "no qemu guest agent configured"):
+			return platform.AgentStatusNotConfigured
+		}
+	}
+	return platform.AgentStatusError
+}
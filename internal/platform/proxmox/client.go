@@ -2,16 +2,23 @@ package proxmox
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform/upstream"
 )
 
 // PVEClient represents a client for the Proxmox VE API
@@ -19,97 +26,102 @@ type PVEClient struct {
 	BaseURL   string
 	AuthToken string
 	client    *http.Client
+	tlsConfig *tls.Config
+	policy    upstream.Policy
 	lastReset uint64 // Unix 时间戳，使用原子操作访问
-}
 
-// VMInfo contains information about a virtual machine
-type VMInfo struct {
-	ID        string  `json:"id"`
-	Name      string  `json:"name"`
-	Status    string  `json:"status"`
-	CPU       float64 `json:"cpu"`       // 当前 CPU 使用率
-	CPUs      float64 `json:"cpus"`      // 最大可用 CPU 数量
-	Memory    float64 `json:"mem"`       // 当前内存使用
-	MaxMem    int64   `json:"maxmem"`    // 最大内存 (字节)
-	Disk      float64 `json:"disk"`      // 当前磁盘使用率
-	MaxDisk   int64   `json:"maxdisk"`   // 根磁盘大小 (字节)
-	DiskRead  int64   `json:"diskread"`  // 总磁盘读取量 (字节)
-	DiskWrite int64   `json:"diskwrite"` // 总磁盘写入量 (字节)
-	NetIn     int64   `json:"netin"`     // 总网络流入量 (字节)
-	NetOut    int64   `json:"netout"`    // 总网络流出量 (字节)
-	Uptime    int     `json:"uptime"`    // 运行时间 (秒)
-	Node      string  `json:"node"`
-}
+	macMu    sync.Mutex
+	macCache map[string]macCacheEntry // 以 VMID 为键
+
+	agents *agentCache
+
+	storageWarningPercent int
 
-// SnapshotInfo contains information about a snapshot
-type SnapshotInfo struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	SnapTime    int64  `json:"snaptime"`
+	pool string // 共享实验环境所在的资源池, 为空表示所有虚拟机
 }
 
-// VMOperationResult contains the result of an operation
-type VMOperationResult struct {
-	VMID    string `json:"vmid"`
-	Success bool   `json:"success"`
-	Message string `json:"message"`
+var _ platform.Hypervisor = (*PVEClient)(nil)
+
+// macCacheTTL is how long the MAC addresses read from a VM config are reused
+const macCacheTTL = 5 * time.Minute
+
+type macCacheEntry struct {
+	macs    []string
+	fetched time.Time
 }
 
 // NewPVEClientFromConfig creates a new Proxmox VE client using the application config
-func NewPVEClientFromConfig(config *config.Config) *PVEClient {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+func NewPVEClientFromConfig(config *config.Config) (*PVEClient, error) {
+	client, tlsConfig, err := upstream.NewHTTPClient(upstream.TLSOptions{
+		CAFile:      config.GetProxmoxCAFile(),
+		Fingerprint: config.GetProxmoxFingerprint(),
+		Insecure:    config.GetProxmoxInsecure(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure Proxmox TLS: %w", err)
 	}
-	client := &http.Client{Transport: tr}
 
 	return &PVEClient{
 		BaseURL:   config.GetProxmoxURL(),
 		AuthToken: config.GetProxmoxAuthToken(),
 		client:    client,
+		tlsConfig: tlsConfig,
+		policy: upstream.Policy{
+			Timeout: config.GetProxmoxTimeout(),
+			Retries: config.GetUpstreamRetries(),
+			Backoff: config.GetUpstreamRetryBackoff(),
+		},
 		lastReset: 0,
-	}
+		macCache:  map[string]macCacheEntry{},
+		agents: &agentCache{
+			ttl:     config.GetGuestAgentCacheTTL(),
+			entries: map[string]agentCacheEntry{},
+		},
+		storageWarningPercent: config.GetStorageWarningPercent(),
+		pool:                  config.GetProxmoxPool(),
+	}, nil
 }
 
-func (c *PVEClient) makeRequest(method, path string, body interface{}) ([]byte, error) {
-	var reqBody io.Reader
+func (c *PVEClient) makeRequest(ctx context.Context, method, path string, body interface{}) ([]byte, error) {
+	var jsonData []byte
 	if body != nil {
-		jsonData, err := json.Marshal(body)
+		var err error
+		jsonData, err = json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
-		reqBody = bytes.NewBuffer(jsonData)
-	}
-
-	req, err := http.NewRequest(method, fmt.Sprintf("%s/api2/json%s", c.BaseURL, path), reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	if body != nil {
-		req.Header.Add("Content-Type", "application/json")
-	}
-	req.Header.Add("Authorization", fmt.Sprintf("PVEAPIToken=%s", c.AuthToken))
+	resp, err := c.policy.Do(ctx, c.client, func(ctx context.Context) (*http.Request, error) {
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewReader(jsonData)
+		}
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
+		req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s/api2/json%s", c.BaseURL, path), reqBody)
+		if err != nil {
+			return nil, err
+		}
 
-	respBody, err := io.ReadAll(resp.Body)
+		if body != nil {
+			req.Header.Add("Content-Type", "application/json")
+		}
+		req.Header.Add("Authorization", fmt.Sprintf("PVEAPIToken=%s", c.AuthToken))
+		return req, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, &upstream.Error{Source: upstream.SourceProxmox, Err: err}
 	}
 
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(respBody))
+		return nil, &upstream.Error{Source: upstream.SourceProxmox, StatusCode: resp.StatusCode, Detail: string(resp.Body)}
 	}
 
-	return respBody, nil
+	return resp.Body, nil
 }
 
-func (c *PVEClient) GetNodes() ([]string, error) {
-	respBody, err := c.makeRequest("GET", "/nodes", nil)
+func (c *PVEClient) GetNodes(ctx context.Context) ([]string, error) {
+	respBody, err := c.makeRequest(ctx, "GET", "/nodes", nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get nodes: %w", err)
 	}
@@ -133,17 +145,38 @@ func (c *PVEClient) GetNodes() ([]string, error) {
 	return nodes, nil
 }
 
-// Make sure the API token's scope is limited to GOAD pool
-func (c *PVEClient) GetVMs() ([]VMInfo, error) {
-	nodes, err := c.GetNodes()
+// GetVMs returns the VMs of the shared lab: every VM but templates or, when PROXMOX_POOL is set, the VMs of that pool.
+// Make sure the API token's scope is limited to the GOAD pools.
+func (c *PVEClient) GetVMs(ctx context.Context) ([]platform.VMInfo, error) {
+	if c.pool != "" {
+		return c.GetPoolVMs(ctx, c.pool)
+	}
+
+	vms, err := c.listVMs(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	var allVMs []VMInfo
+	var labVMs []platform.VMInfo
+	for _, vm := range vms {
+		if !vm.Template {
+			labVMs = append(labVMs, vm)
+		}
+	}
+	return labVMs, nil
+}
+
+// listVMs returns every VM on every node, templates included
+func (c *PVEClient) listVMs(ctx context.Context) ([]platform.VMInfo, error) {
+	nodes, err := c.GetNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var allVMs []platform.VMInfo
 
 	for _, node := range nodes {
-		respBody, err := c.makeRequest("GET", fmt.Sprintf("/nodes/%s/qemu", node), nil)
+		respBody, err := c.makeRequest(ctx, "GET", fmt.Sprintf("/nodes/%s/qemu", node), nil)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get VMs for node %s: %w", node, err)
 		}
@@ -164,6 +197,7 @@ func (c *PVEClient) GetVMs() ([]VMInfo, error) {
 				NetIn     int64   `json:"netin"`
 				NetOut    int64   `json:"netout"`
 				Uptime    int     `json:"uptime"`
+				Template  int     `json:"template"`
 			} `json:"data"`
 		}
 
@@ -173,7 +207,7 @@ func (c *PVEClient) GetVMs() ([]VMInfo, error) {
 		}
 
 		for _, vm := range result.Data {
-			allVMs = append(allVMs, VMInfo{
+			allVMs = append(allVMs, platform.VMInfo{
 				ID:        fmt.Sprintf("%d", vm.VMID),
 				Name:      vm.Name,
 				Status:    vm.Status,
@@ -189,6 +223,7 @@ func (c *PVEClient) GetVMs() ([]VMInfo, error) {
 				NetOut:    vm.NetOut,
 				Uptime:    vm.Uptime,
 				Node:      node,
+				Template:  vm.Template == 1,
 			})
 		}
 	}
@@ -196,8 +231,8 @@ func (c *PVEClient) GetVMs() ([]VMInfo, error) {
 	return allVMs, nil
 }
 
-func (c *PVEClient) GetSnapshots(node string, vmID string) ([]SnapshotInfo, error) {
-	respBody, err := c.makeRequest("GET", fmt.Sprintf("/nodes/%s/qemu/%s/snapshot", node, vmID), nil)
+func (c *PVEClient) GetSnapshots(ctx context.Context, node string, vmID string) ([]platform.SnapshotInfo, error) {
+	respBody, err := c.makeRequest(ctx, "GET", fmt.Sprintf("/nodes/%s/qemu/%s/snapshot", node, vmID), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get snapshots: %w", err)
 	}
@@ -215,9 +250,9 @@ func (c *PVEClient) GetSnapshots(node string, vmID string) ([]SnapshotInfo, erro
 		return nil, fmt.Errorf("failed to decode snapshots response: %w", err)
 	}
 
-	snapshots := make([]SnapshotInfo, len(result.Data))
+	snapshots := make([]platform.SnapshotInfo, len(result.Data))
 	for i, snapshot := range result.Data {
-		snapshots[i] = SnapshotInfo{
+		snapshots[i] = platform.SnapshotInfo{
 			Name:        snapshot.Name,
 			Description: snapshot.Description,
 			SnapTime:    snapshot.SnapTime,
@@ -227,158 +262,278 @@ func (c *PVEClient) GetSnapshots(node string, vmID string) ([]SnapshotInfo, erro
 	return snapshots, nil
 }
 
-func (c *PVEClient) RestoreSnapshot(node string, vmID string, snapshotName string) error {
+func (c *PVEClient) RestoreSnapshot(ctx context.Context, node string, vmID string, snapshotName string) (string, error) {
 	path := fmt.Sprintf("/nodes/%s/qemu/%s/snapshot/%s/rollback", node, vmID, snapshotName)
 
-	_, err := c.makeRequest("POST", path, nil)
+	upid, err := c.postTask(ctx, path, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to restore snapshot: %w", err)
+	}
+
+	return upid, nil
+}
+
+// GetVMConfig returns the raw configuration of a VM
+func (c *PVEClient) GetVMConfig(ctx context.Context, node string, vmID string) (map[string]interface{}, error) {
+	respBody, err := c.makeRequest(ctx, "GET", fmt.Sprintf("/nodes/%s/qemu/%s/config", node, vmID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VM config: %w", err)
+	}
+
+	var result struct {
+		Data map[string]interface{} `json:"data"`
+	}
+
+	err = json.Unmarshal(respBody, &result)
 	if err != nil {
-		return fmt.Errorf("failed to restore snapshot: %w", err)
+		return nil, fmt.Errorf("failed to decode VM config response: %w", err)
 	}
 
-	return nil
+	return result.Data, nil
+}
+
+// GetVMMACs returns the lower-case MAC addresses of the network devices of a VM, ordered by device index
+func (c *PVEClient) GetVMMACs(ctx context.Context, node string, vmID string) ([]string, error) {
+	c.macMu.Lock()
+	entry, ok := c.macCache[vmID]
+	c.macMu.Unlock()
+	if ok && time.Since(entry.fetched) < macCacheTTL {
+		return entry.macs, nil
+	}
+
+	vmConfig, err := c.GetVMConfig(ctx, node, vmID)
+	if err != nil {
+		return nil, err
+	}
+
+	// netX 按数字排序, net10 在 net2 之后
+	var indexes []int
+	for key := range vmConfig {
+		if index, err := strconv.Atoi(strings.TrimPrefix(key, "net")); strings.HasPrefix(key, "net") && err == nil {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Ints(indexes)
+
+	macs := []string{}
+	for _, index := range indexes {
+		value, ok := vmConfig[fmt.Sprintf("net%d", index)].(string)
+		if !ok {
+			continue
+		}
+		if mac := parseNetMAC(value); mac != "" {
+			macs = append(macs, mac)
+		}
+	}
+
+	c.macMu.Lock()
+	c.macCache[vmID] = macCacheEntry{macs: macs, fetched: time.Now()}
+	c.macMu.Unlock()
+
+	return macs, nil
+}
+
+// parseNetMAC extracts the MAC address from a netX option such as "virtio=BC:24:11:00:00:01,bridge=vmbr1"
+func parseNetMAC(value string) string {
+	for _, option := range strings.Split(value, ",") {
+		key, mac, ok := strings.Cut(option, "=")
+		if !ok {
+			continue
+		}
+		if key == "macaddr" || strings.Count(mac, ":") == 5 {
+			return strings.ToLower(mac)
+		}
+	}
+	return ""
 }
 
 func (c *PVEClient) GetLastReset() (uint64, error) {
 	return atomic.LoadUint64(&c.lastReset), nil
 }
 
-func (c *PVEClient) ResetLab() error {
+// ResetLab rolls every VM back to its latest snapshot and waits for the rollback tasks to finish
+func (c *PVEClient) ResetLab(ctx context.Context) ([]platform.VMOperationResult, error) {
 	atomic.StoreUint64(&c.lastReset, uint64(time.Now().Unix()))
 
-	nodes, err := c.GetNodes()
+	vms, err := c.GetVMs(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get nodes: %w", err)
+		return nil, fmt.Errorf("failed to get VMs: %w", err)
 	}
 
-	for _, node := range nodes {
-		vms, err := c.GetVMs()
+	results := make([]platform.VMOperationResult, len(vms))
+	var wg sync.WaitGroup
+
+	for i, vm := range vms {
+		results[i] = platform.VMOperationResult{VMID: vm.ID}
+
+		snapshots, err := c.GetSnapshots(ctx, vm.Node, vm.ID)
 		if err != nil {
-			return fmt.Errorf("failed to get VMs for node %s: %w", node, err)
+			slog.WarnContext(ctx, "failed to get snapshots", "vmid", vm.ID, "node", vm.Node, "error", err)
+			results[i].Message = err.Error()
+			continue
 		}
 
-		for _, vm := range vms {
-			snapshots, err := c.GetSnapshots(node, vm.ID)
-			if err != nil {
-				log.Printf("Warning: failed to get snapshots for VM %s on node %s: %v", vm.ID, node, err)
-				continue
-			}
+		if len(snapshots) == 0 {
+			slog.WarnContext(ctx, "no snapshots found", "vmid", vm.ID, "node", vm.Node)
+			results[i].Message = "no snapshots found"
+			continue
+		}
 
-			if len(snapshots) == 0 {
-				log.Printf("Warning: no snapshots found for VM %s on node %s", vm.ID, node)
-				continue
+		var latestSnapshot platform.SnapshotInfo
+		for _, snapshot := range snapshots {
+			if snapshot.SnapTime > latestSnapshot.SnapTime {
+				latestSnapshot = snapshot
 			}
+		}
 
-			var latestSnapshot SnapshotInfo
-			for _, snapshot := range snapshots {
-				if snapshot.SnapTime > latestSnapshot.SnapTime {
-					latestSnapshot = snapshot
-				}
-			}
+		upid, err := c.RestoreSnapshot(ctx, vm.Node, vm.ID, latestSnapshot.Name)
+		if err != nil {
+			slog.WarnContext(ctx, "failed to restore snapshot", "vmid", vm.ID, "node", vm.Node, "snapshot", latestSnapshot.Name, "error", err)
+			results[i].Message = err.Error()
+			continue
+		}
 
-			err = c.RestoreSnapshot(node, vm.ID, latestSnapshot.Name)
-			if err != nil {
-				log.Printf("Warning: failed to restore snapshot %s for VM %s on node %s: %v",
-					latestSnapshot.Name, vm.ID, node, err)
-				continue
+		wg.Add(1)
+		go func(i int, vm platform.VMInfo, snapshot string) {
+			defer wg.Done()
+			if err := c.WaitForTask(ctx, vm.Node, upid); err != nil {
+				slog.WarnContext(ctx, "rollback failed", "vmid", vm.ID, "node", vm.Node, "snapshot", snapshot, "error", err)
+				results[i].Message = err.Error()
+				return
 			}
-
-			log.Printf("Successfully restored VM %s on node %s to snapshot %s",
-				vm.ID, node, latestSnapshot.Name)
-		}
+			slog.InfoContext(ctx, "restored VM to snapshot", "vmid", vm.ID, "node", vm.Node, "snapshot", snapshot)
+			results[i].Success = true
+		}(i, vm, latestSnapshot.Name)
 	}
 
-	return nil
+	wg.Wait()
+
+	return results, nil
 }
 
-func (c *PVEClient) StartVM(node string, vmID string) error {
+func (c *PVEClient) StartVM(ctx context.Context, node string, vmID string) (string, error) {
 	path := fmt.Sprintf("/nodes/%s/qemu/%s/status/start", node, vmID)
 
-	_, err := c.makeRequest("POST", path, nil)
+	upid, err := c.postTask(ctx, path, nil)
 	if err != nil {
-		return fmt.Errorf("failed to start VM: %w", err)
+		return "", fmt.Errorf("failed to start VM: %w", err)
 	}
 
-	return nil
+	return upid, nil
 }
 
-func (c *PVEClient) StopVM(node string, vmID string) error {
+func (c *PVEClient) StopVM(ctx context.Context, node string, vmID string) (string, error) {
 	path := fmt.Sprintf("/nodes/%s/qemu/%s/status/stop", node, vmID)
 
-	_, err := c.makeRequest("POST", path, nil)
+	upid, err := c.postTask(ctx, path, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to stop VM: %w", err)
+	}
+
+	return upid, nil
+}
+
+// ShutdownVM asks the guest OS to shut down through ACPI. With forceStop, Proxmox stops the VM once the timeout expires.
+func (c *PVEClient) ShutdownVM(ctx context.Context, node string, vmID string, timeout time.Duration, forceStop bool) (string, error) {
+	path := fmt.Sprintf("/nodes/%s/qemu/%s/status/shutdown", node, vmID)
+
+	body := map[string]interface{}{
+		"timeout":   int(timeout.Seconds()),
+		"forceStop": forceStop,
+	}
+
+	upid, err := c.postTask(ctx, path, body)
 	if err != nil {
-		return fmt.Errorf("failed to stop VM: %w", err)
+		return "", fmt.Errorf("failed to shut down VM: %w", err)
 	}
 
-	return nil
+	return upid, nil
 }
 
-func (c *PVEClient) ResetVM(node string, vmID string) error {
+func (c *PVEClient) ResetVM(ctx context.Context, node string, vmID string) (string, error) {
 	path := fmt.Sprintf("/nodes/%s/qemu/%s/status/reset", node, vmID)
 
-	_, err := c.makeRequest("POST", path, nil)
+	upid, err := c.postTask(ctx, path, nil)
 	if err != nil {
-		return fmt.Errorf("failed to reset VM: %w", err)
+		return "", fmt.Errorf("failed to reset VM: %w", err)
 	}
 
-	return nil
+	return upid, nil
 }
 
-func (c *PVEClient) StartAllVMs() ([]VMOperationResult, error) {
-	vms, err := c.GetVMs()
+// SuspendVM pauses a running VM, keeping its memory in place
+func (c *PVEClient) SuspendVM(ctx context.Context, node string, vmID string) (string, error) {
+	path := fmt.Sprintf("/nodes/%s/qemu/%s/status/suspend", node, vmID)
+
+	upid, err := c.postTask(ctx, path, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get VMs: %w", err)
+		return "", fmt.Errorf("failed to suspend VM: %w", err)
 	}
 
-	results := make([]VMOperationResult, len(vms))
+	return upid, nil
+}
 
-	for i, vm := range vms {
-		err := c.StartVM(vm.Node, vm.ID)
-		if err != nil {
-			results[i] = VMOperationResult{VMID: vm.ID, Success: false, Message: err.Error()}
-		} else {
-			results[i] = VMOperationResult{VMID: vm.ID, Success: true}
-		}
+// ResumeVM resumes a suspended VM
+func (c *PVEClient) ResumeVM(ctx context.Context, node string, vmID string) (string, error) {
+	path := fmt.Sprintf("/nodes/%s/qemu/%s/status/resume", node, vmID)
+
+	upid, err := c.postTask(ctx, path, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to resume VM: %w", err)
 	}
 
-	return results, nil
+	return upid, nil
 }
 
-func (c *PVEClient) StopAllVMs() ([]VMOperationResult, error) {
-	vms, err := c.GetVMs()
+// forAllVMs runs op on every VM, one after another, and collects the outcomes
+func (c *PVEClient) forAllVMs(ctx context.Context, op func(node string, vmID string) (string, error)) ([]platform.VMOperationResult, error) {
+	vms, err := c.GetVMs(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get VMs: %w", err)
 	}
 
-	results := make([]VMOperationResult, len(vms))
+	results := make([]platform.VMOperationResult, len(vms))
 
 	for i, vm := range vms {
-		err := c.StopVM(vm.Node, vm.ID)
+		_, err := op(vm.Node, vm.ID)
 		if err != nil {
-			results[i] = VMOperationResult{VMID: vm.ID, Success: false, Message: err.Error()}
+			results[i] = platform.VMOperationResult{VMID: vm.ID, Success: false, Message: err.Error()}
 		} else {
-			results[i] = VMOperationResult{VMID: vm.ID, Success: true}
+			results[i] = platform.VMOperationResult{VMID: vm.ID, Success: true}
 		}
 	}
 
 	return results, nil
 }
 
-func (c *PVEClient) ResetAllVMs() ([]VMOperationResult, error) {
-	vms, err := c.GetVMs()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get VMs: %w", err)
-	}
+func (c *PVEClient) StartAllVMs(ctx context.Context) ([]platform.VMOperationResult, error) {
+	return c.forAllVMs(ctx, func(node string, vmID string) (string, error) {
+		return c.StartVM(ctx, node, vmID)
+	})
+}
 
-	results := make([]VMOperationResult, len(vms))
+func (c *PVEClient) StopAllVMs(ctx context.Context) ([]platform.VMOperationResult, error) {
+	return c.forAllVMs(ctx, func(node string, vmID string) (string, error) {
+		return c.StopVM(ctx, node, vmID)
+	})
+}
 
-	for i, vm := range vms {
-		err := c.ResetVM(vm.Node, vm.ID)
-		if err != nil {
-			results[i] = VMOperationResult{VMID: vm.ID, Success: false, Message: err.Error()}
-		} else {
-			results[i] = VMOperationResult{VMID: vm.ID, Success: true}
-		}
-	}
+func (c *PVEClient) ResetAllVMs(ctx context.Context) ([]platform.VMOperationResult, error) {
+	return c.forAllVMs(ctx, func(node string, vmID string) (string, error) {
+		return c.ResetVM(ctx, node, vmID)
+	})
+}
 
-	return results, nil
+// SuspendAllVMs suspends every VM
+func (c *PVEClient) SuspendAllVMs(ctx context.Context) ([]platform.VMOperationResult, error) {
+	return c.forAllVMs(ctx, func(node string, vmID string) (string, error) {
+		return c.SuspendVM(ctx, node, vmID)
+	})
+}
+
+// ResumeAllVMs resumes every VM
+func (c *PVEClient) ResumeAllVMs(ctx context.Context) ([]platform.VMOperationResult, error) {
+	return c.forAllVMs(ctx, func(node string, vmID string) (string, error) {
+		return c.ResumeVM(ctx, node, vmID)
+	})
 }
@@ -0,0 +1,76 @@
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform/upstream"
+	"golang.org/x/net/websocket"
+)
+
+// OpenVNCProxy asks Proxmox to open a websocket capable VNC proxy for a VM.
+// The proxy only waits a few seconds for the websocket connection.
+func (c *PVEClient) OpenVNCProxy(ctx context.Context, node string, vmID string) (*platform.VNCTicket, error) {
+	body := map[string]interface{}{
+		"websocket": 1,
+	}
+
+	respBody, err := c.makeRequest(ctx, "POST", fmt.Sprintf("/nodes/%s/qemu/%s/vncproxy", node, vmID), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open VNC proxy: %w", err)
+	}
+
+	var result struct {
+		Data struct {
+			Port   json.Number `json:"port"`
+			Ticket string      `json:"ticket"`
+		} `json:"data"`
+	}
+
+	err = json.Unmarshal(respBody, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode VNC proxy response: %w", err)
+	}
+
+	return &platform.VNCTicket{Port: result.Data.Port.String(), Ticket: result.Data.Ticket}, nil
+}
+
+// DialVNCWebSocket connects to the websocket of a VNC proxy opened by OpenVNCProxy
+func (c *PVEClient) DialVNCWebSocket(ctx context.Context, node string, vmID string, ticket *platform.VNCTicket) (io.ReadWriteCloser, error) {
+	base, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Proxmox URL: %w", err)
+	}
+
+	query := url.Values{}
+	query.Set("port", ticket.Port)
+	query.Set("vncticket", ticket.Ticket)
+
+	location := *base
+	location.Scheme = strings.Replace(base.Scheme, "http", "ws", 1)
+	location.Path = strings.TrimSuffix(base.Path, "/") + fmt.Sprintf("/api2/json/nodes/%s/qemu/%s/vncwebsocket", node, vmID)
+	location.RawQuery = query.Encode()
+
+	config, err := websocket.NewConfig(location.String(), base.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid VNC websocket URL: %w", err)
+	}
+	config.Protocol = []string{"binary"}
+	config.Header.Set("Authorization", fmt.Sprintf("PVEAPIToken=%s", c.AuthToken))
+	if c.tlsConfig != nil {
+		config.TlsConfig = c.tlsConfig.Clone()
+	}
+
+	conn, err := config.DialContext(ctx)
+	if err != nil {
+		return nil, &upstream.Error{Source: upstream.SourceProxmox, Err: err}
+	}
+	conn.PayloadType = websocket.BinaryFrame
+
+	return conn, nil
+}
@@ -0,0 +1,61 @@
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/errdefs"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+)
+
+// FindVM returns the VM with the given ID, wrapping errdefs.ErrNotFound if there is none
+func (c *PVEClient) FindVM(ctx context.Context, vmID string) (*platform.VMInfo, error) {
+	vms, err := c.GetVMs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range vms {
+		if vms[i].ID == vmID {
+			return &vms[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("VM %s: %w", vmID, errdefs.ErrNotFound)
+}
+
+// GetVMMetrics returns the RRD data of a VM for a timeframe, consolidated with AVERAGE or MAX
+func (c *PVEClient) GetVMMetrics(ctx context.Context, node string, vmID string, timeframe string, consolidation string) (*platform.VMMetrics, error) {
+	query := url.Values{}
+	query.Set("timeframe", timeframe)
+	query.Set("cf", consolidation)
+
+	respBody, err := c.makeRequest(ctx, "GET", fmt.Sprintf("/nodes/%s/qemu/%s/rrddata?%s", node, vmID, query.Encode()), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metrics: %w", err)
+	}
+
+	var result struct {
+		Data []platform.MetricPoint `json:"data"`
+	}
+
+	err = json.Unmarshal(respBody, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode metrics response: %w", err)
+	}
+
+	points := result.Data
+	if points == nil {
+		points = []platform.MetricPoint{}
+	}
+
+	return &platform.VMMetrics{
+		VMID:          vmID,
+		Node:          node,
+		Timeframe:     timeframe,
+		Consolidation: consolidation,
+		Points:        points,
+	}, nil
+}
@@ -0,0 +1,188 @@
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+)
+
+// GetNodeStatuses returns the status of every node. Nodes whose status cannot be read are reported as unreachable.
+func (c *PVEClient) GetNodeStatuses(ctx context.Context) ([]platform.NodeStatus, error) {
+	nodes, err := c.GetNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]platform.NodeStatus, len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node string) {
+			defer wg.Done()
+			status, err := c.GetNodeStatus(ctx, node)
+			if err != nil {
+				slog.WarnContext(ctx, "failed to get node status", "node", node, "error", err)
+				statuses[i] = platform.NodeStatus{Node: node, Status: platform.NodeUnreachable}
+				return
+			}
+			statuses[i] = *status
+		}(i, node)
+	}
+	wg.Wait()
+
+	return statuses, nil
+}
+
+// GetNodeStatus returns the status of a node
+func (c *PVEClient) GetNodeStatus(ctx context.Context, node string) (*platform.NodeStatus, error) {
+	respBody, err := c.makeRequest(ctx, "GET", fmt.Sprintf("/nodes/%s/status", node), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node status: %w", err)
+	}
+
+	var result struct {
+		Data struct {
+			CPU     float64 `json:"cpu"`
+			CPUInfo struct {
+				CPUs int `json:"cpus"`
+			} `json:"cpuinfo"`
+			Memory struct {
+				Used  int64 `json:"used"`
+				Total int64 `json:"total"`
+			} `json:"memory"`
+			Uptime     int64    `json:"uptime"`
+			LoadAvg    []string `json:"loadavg"`
+			PVEVersion string   `json:"pveversion"`
+			KVersion   string   `json:"kversion"`
+		} `json:"data"`
+	}
+
+	err = json.Unmarshal(respBody, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode node status response: %w", err)
+	}
+
+	loadAvg := make([]float64, 0, len(result.Data.LoadAvg))
+	for _, value := range result.Data.LoadAvg {
+		load, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode node load average %q: %w", value, err)
+		}
+		loadAvg = append(loadAvg, load)
+	}
+
+	return &platform.NodeStatus{
+		Node:       node,
+		Status:     platform.NodeOnline,
+		CPU:        result.Data.CPU,
+		CPUs:       result.Data.CPUInfo.CPUs,
+		Memory:     result.Data.Memory.Used,
+		MaxMem:     result.Data.Memory.Total,
+		Uptime:     result.Data.Uptime,
+		LoadAvg:    loadAvg,
+		PVEVersion: result.Data.PVEVersion,
+		Kernel:     result.Data.KVersion,
+	}, nil
+}
+
+// GetStorage returns the storage usage of every node. Shared storage is listed once.
+// Storage holding VM disks, and therefore snapshot deltas, gets a warning once its usage crosses STORAGE_WARNING_PERCENT.
+func (c *PVEClient) GetStorage(ctx context.Context) ([]platform.StorageInfo, error) {
+	nodes, err := c.GetNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	storages := []platform.StorageInfo{}
+	shared := map[string]bool{}
+
+	for _, node := range nodes {
+		respBody, err := c.makeRequest(ctx, "GET", fmt.Sprintf("/nodes/%s/storage", node), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get storage for node %s: %w", node, err)
+		}
+
+		var result struct {
+			Data []struct {
+				Storage      string  `json:"storage"`
+				Type         string  `json:"type"`
+				Content      string  `json:"content"`
+				Shared       int     `json:"shared"`
+				Active       int     `json:"active"`
+				Used         int64   `json:"used"`
+				Total        int64   `json:"total"`
+				Avail        int64   `json:"avail"`
+				UsedFraction float64 `json:"used_fraction"`
+			} `json:"data"`
+		}
+
+		err = json.Unmarshal(respBody, &result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode storage response: %w", err)
+		}
+
+		for _, s := range result.Data {
+			if s.Shared == 1 {
+				if shared[s.Storage] {
+					continue
+				}
+				shared[s.Storage] = true
+			}
+
+			storage := platform.StorageInfo{
+				Node:         node,
+				Storage:      s.Storage,
+				Type:         s.Type,
+				Content:      splitContent(s.Content),
+				Shared:       s.Shared == 1,
+				Active:       s.Active == 1,
+				Used:         s.Used,
+				Total:        s.Total,
+				Avail:        s.Avail,
+				UsedFraction: s.UsedFraction,
+			}
+			if storage.UsedFraction == 0 && storage.Total > 0 {
+				storage.UsedFraction = float64(storage.Used) / float64(storage.Total)
+			}
+			storage.Warning = c.storageWarning(storage)
+			if storage.Warning != "" {
+				slog.WarnContext(ctx, "storage warning", "storage", storage.Storage, "node", node, "warning", storage.Warning)
+			}
+
+			storages = append(storages, storage)
+		}
+	}
+
+	return storages, nil
+}
+
+// storageWarning returns a warning if a storage holding VM disks is fuller than the configured threshold
+func (c *PVEClient) storageWarning(storage platform.StorageInfo) string {
+	holdsDisks := false
+	for _, content := range storage.Content {
+		if content == "images" {
+			holdsDisks = true
+		}
+	}
+	if !holdsDisks || storage.UsedFraction*100 < float64(c.storageWarningPercent) {
+		return ""
+	}
+	return fmt.Sprintf("%.0f%% used, above the %d%% threshold; snapshot rollbacks may fail", storage.UsedFraction*100, c.storageWarningPercent)
+}
+
+// splitContent splits the comma separated content types of a storage
+func splitContent(value string) []string {
+	content := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			content = append(content, item)
+		}
+	}
+	return content
+}
@@ -0,0 +1,223 @@
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/errdefs"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+)
+
+// GetPools returns every pool visible to the API token
+func (c *PVEClient) GetPools(ctx context.Context) ([]platform.Pool, error) {
+	respBody, err := c.makeRequest(ctx, "GET", "/pools", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pools: %w", err)
+	}
+
+	var result struct {
+		Data []platform.Pool `json:"data"`
+	}
+
+	err = json.Unmarshal(respBody, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode pools response: %w", err)
+	}
+
+	return result.Data, nil
+}
+
+// GetPoolVMs returns the VMs of a pool, templates excluded
+func (c *PVEClient) GetPoolVMs(ctx context.Context, pool string) ([]platform.VMInfo, error) {
+	respBody, err := c.makeRequest(ctx, "GET", fmt.Sprintf("/pools/%s", url.PathEscape(pool)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pool %s: %w", pool, err)
+	}
+
+	var result struct {
+		Data struct {
+			Members []struct {
+				Type string `json:"type"`
+				VMID int    `json:"vmid"`
+			} `json:"members"`
+		} `json:"data"`
+	}
+
+	err = json.Unmarshal(respBody, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode pool response: %w", err)
+	}
+
+	members := map[string]bool{}
+	for _, member := range result.Data.Members {
+		if member.Type == "qemu" {
+			members[fmt.Sprintf("%d", member.VMID)] = true
+		}
+	}
+
+	vms, err := c.listVMs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var poolVMs []platform.VMInfo
+	for _, vm := range vms {
+		if members[vm.ID] && !vm.Template {
+			poolVMs = append(poolVMs, vm)
+		}
+	}
+	return poolVMs, nil
+}
+
+// CreatePool creates a pool
+func (c *PVEClient) CreatePool(ctx context.Context, pool string, comment string) error {
+	body := map[string]interface{}{
+		"poolid":  pool,
+		"comment": comment,
+	}
+
+	_, err := c.makeRequest(ctx, "POST", "/pools", body)
+	if err != nil {
+		return fmt.Errorf("failed to create pool %s: %w", pool, err)
+	}
+	return nil
+}
+
+// SetPoolComment replaces the comment of a pool
+func (c *PVEClient) SetPoolComment(ctx context.Context, pool string, comment string) error {
+	body := map[string]interface{}{
+		"comment": comment,
+	}
+
+	_, err := c.makeRequest(ctx, "PUT", fmt.Sprintf("/pools/%s", url.PathEscape(pool)), body)
+	if err != nil {
+		return fmt.Errorf("failed to update pool %s: %w", pool, err)
+	}
+	return nil
+}
+
+// DeletePool deletes an empty pool
+func (c *PVEClient) DeletePool(ctx context.Context, pool string) error {
+	_, err := c.makeRequest(ctx, "DELETE", fmt.Sprintf("/pools/%s", url.PathEscape(pool)), nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete pool %s: %w", pool, err)
+	}
+	return nil
+}
+
+// FindTemplate returns the template VM with the given ID, wrapping errdefs.ErrNotFound if there is none
+func (c *PVEClient) FindTemplate(ctx context.Context, vmID string) (*platform.VMInfo, error) {
+	vms, err := c.listVMs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range vms {
+		if vms[i].ID == vmID && vms[i].Template {
+			return &vms[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("template %s: %w", vmID, errdefs.ErrNotFound)
+}
+
+// NextVMID returns a free VM ID
+func (c *PVEClient) NextVMID(ctx context.Context) (string, error) {
+	respBody, err := c.makeRequest(ctx, "GET", "/cluster/nextid", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to get next VM ID: %w", err)
+	}
+
+	var result struct {
+		Data json.Number `json:"data"`
+	}
+
+	err = json.Unmarshal(respBody, &result)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode next VM ID response: %w", err)
+	}
+
+	return result.Data.String(), nil
+}
+
+// CloneVM creates a linked clone of a template in a pool
+func (c *PVEClient) CloneVM(ctx context.Context, node string, templateID string, newID string, name string, pool string) (string, error) {
+	body := map[string]interface{}{
+		"newid": newID,
+		"name":  name,
+		"pool":  pool,
+		"full":  0,
+	}
+
+	upid, err := c.postTask(ctx, fmt.Sprintf("/nodes/%s/qemu/%s/clone", node, templateID), body)
+	if err != nil {
+		return "", fmt.Errorf("failed to clone VM: %w", err)
+	}
+
+	return upid, nil
+}
+
+// UpdateVMConfig sets VM options such as "net0"
+func (c *PVEClient) UpdateVMConfig(ctx context.Context, node string, vmID string, options map[string]interface{}) error {
+	_, err := c.makeRequest(ctx, "PUT", fmt.Sprintf("/nodes/%s/qemu/%s/config", node, vmID), options)
+	if err != nil {
+		return fmt.Errorf("failed to update VM config: %w", err)
+	}
+
+	c.macMu.Lock()
+	delete(c.macCache, vmID)
+	c.macMu.Unlock()
+
+	return nil
+}
+
+// SetVMNetwork moves every network device of a VM to bridge, tagged with vlan
+func (c *PVEClient) SetVMNetwork(ctx context.Context, node string, vmID string, bridge string, vlan int) error {
+	vmConfig, err := c.GetVMConfig(ctx, node, vmID)
+	if err != nil {
+		return err
+	}
+
+	options := map[string]interface{}{}
+	for key, value := range vmConfig {
+		if net, ok := value.(string); ok && netKeyPattern.MatchString(key) {
+			options[key] = setNetBridge(net, bridge, vlan)
+		}
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return c.UpdateVMConfig(ctx, node, vmID, options)
+}
+
+// DeleteVM destroys a stopped VM and its disks, removing it from jobs and pools
+func (c *PVEClient) DeleteVM(ctx context.Context, node string, vmID string) (string, error) {
+	upid, err := c.makeTask(ctx, "DELETE", fmt.Sprintf("/nodes/%s/qemu/%s?purge=1&destroy-unreferenced-disks=1", node, vmID), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to delete VM: %w", err)
+	}
+
+	return upid, nil
+}
+
+// netKeyPattern matches the VM options of network devices such as "net0"
+var netKeyPattern = regexp.MustCompile(`^net\d+$`)
+
+// setNetBridge rewrites a netX option such as "virtio=BC:24:11:00:00:01,bridge=vmbr1,firewall=1"
+// to use the given bridge and VLAN tag, keeping the model, MAC address and other options
+func setNetBridge(value string, bridge string, tag int) string {
+	options := []string{}
+	for _, option := range strings.Split(value, ",") {
+		key, _, _ := strings.Cut(option, "=")
+		if key == "bridge" || key == "tag" || option == "" {
+			continue
+		}
+		options = append(options, option)
+	}
+	options = append(options, "bridge="+bridge, fmt.Sprintf("tag=%d", tag))
+	return strings.Join(options, ",")
+}
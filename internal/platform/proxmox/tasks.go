@@ -0,0 +1,91 @@
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// taskPollInterval is how often the status of a running task is polled
+const taskPollInterval = 2 * time.Second
+
+// TaskStatus is the status of a Proxmox task
+type TaskStatus struct {
+	Status     string `json:"status"`     // running 或 stopped
+	ExitStatus string `json:"exitstatus"` // 任务结束后为 OK 或错误信息
+}
+
+// postTask sends a POST request that starts a task and returns its UPID
+func (c *PVEClient) postTask(ctx context.Context, path string, body interface{}) (string, error) {
+	return c.makeTask(ctx, "POST", path, body)
+}
+
+// makeTask sends a request that starts a task and returns its UPID
+func (c *PVEClient) makeTask(ctx context.Context, method string, path string, body interface{}) (string, error) {
+	respBody, err := c.makeRequest(ctx, method, path, body)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Data string `json:"data"`
+	}
+
+	err = json.Unmarshal(respBody, &result)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode task response: %w", err)
+	}
+
+	return result.Data, nil
+}
+
+// GetTaskStatus returns the status of a task
+func (c *PVEClient) GetTaskStatus(ctx context.Context, node string, upid string) (*TaskStatus, error) {
+	respBody, err := c.makeRequest(ctx, "GET", fmt.Sprintf("/nodes/%s/tasks/%s/status", node, url.PathEscape(upid)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task status: %w", err)
+	}
+
+	var result struct {
+		Data TaskStatus `json:"data"`
+	}
+
+	err = json.Unmarshal(respBody, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode task status response: %w", err)
+	}
+
+	return &result.Data, nil
+}
+
+// WaitForTask polls a task until it stops and returns an error unless it finished with OK.
+// An empty UPID, returned by some synchronous calls, is treated as already finished.
+func (c *PVEClient) WaitForTask(ctx context.Context, node string, upid string) error {
+	if upid == "" {
+		return nil
+	}
+
+	ticker := time.NewTicker(taskPollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := c.GetTaskStatus(ctx, node, upid)
+		if err != nil {
+			return err
+		}
+		if status.Status == "stopped" {
+			if status.ExitStatus != "OK" {
+				return fmt.Errorf("task failed: %s", status.ExitStatus)
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
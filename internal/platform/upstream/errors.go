@@ -0,0 +1,61 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/errdefs"
+)
+
+// Upstream sources reported in errors
+const (
+	SourceProxmox = "proxmox"
+	SourcePfsense = "pfsense"
+)
+
+// Error is a failed request to an upstream API
+type Error struct {
+	// Source is the upstream that failed, SourceProxmox or SourcePfsense
+	Source string
+	// StatusCode is the status reported by the upstream, zero when no response was received
+	StatusCode int
+	// Detail is the raw upstream message. It is meant for logs and is never sent to API clients.
+	Detail string
+	// Err is the transport error when no response was received
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s request failed: %v", e.Source, e.Err)
+	}
+	return fmt.Sprintf("%s request failed with status %d: %s", e.Source, e.StatusCode, e.Detail)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports a 404 from the upstream as errdefs.ErrNotFound
+func (e *Error) Is(target error) bool {
+	return target == errdefs.ErrNotFound && e.StatusCode == http.StatusNotFound
+}
+
+// Timeout reports whether the request failed because it took too long
+func (e *Error) Timeout() bool {
+	return errors.Is(e.Err, context.DeadlineExceeded)
+}
+
+// PublicMessage describes the failure without exposing upstream internals
+func (e *Error) PublicMessage() string {
+	switch {
+	case e.Timeout():
+		return fmt.Sprintf("%s did not respond in time", e.Source)
+	case e.Err != nil:
+		return fmt.Sprintf("%s is unreachable", e.Source)
+	default:
+		return fmt.Sprintf("%s request failed with status %d", e.Source, e.StatusCode)
+	}
+}
@@ -0,0 +1,117 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Policy controls the timeout and retry behaviour of requests to an upstream API
+type Policy struct {
+	// Timeout bounds a single attempt, including reading the response body
+	Timeout time.Duration
+	// Retries is the number of additional attempts made for idempotent requests
+	Retries int
+	// Backoff is the delay before the first retry, doubled for every further retry
+	Backoff time.Duration
+}
+
+// Response is a fully read upstream response
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Do sends the request built by newRequest and reads the response.
+// GET requests are retried with exponential backoff on network errors, attempt timeouts and 429/502/503/504 responses.
+// newRequest is called once per attempt so that request bodies can be rebuilt.
+func (p Policy) Do(ctx context.Context, client *http.Client, newRequest func(ctx context.Context) (*http.Request, error)) (*Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, method, err := p.attempt(ctx, client, newRequest)
+
+		transient := (err != nil && retryableError(err)) || (err == nil && retryableStatus(resp.StatusCode))
+		// Only idempotent requests are retried, and never once the caller gave up
+		if !transient || method != http.MethodGet || attempt >= p.Retries || ctx.Err() != nil {
+			return resp, err
+		}
+
+		slog.WarnContext(ctx, "retrying upstream request", "attempt", attempt+1, "retries", p.Retries, "error", describe(resp, err))
+		timer := time.NewTimer(p.Backoff << attempt)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, err
+		case <-timer.C:
+		}
+	}
+}
+
+func (p Policy) attempt(ctx context.Context, client *http.Client, newRequest func(ctx context.Context) (*http.Request, error)) (*Response, string, error) {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	req, err := newRequest(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	// 将请求 ID 传递给上游, 便于在 Proxmox 与 pfSense 的日志中关联
+	if id := middleware.GetReqID(ctx); id != "" {
+		req.Header.Set(middleware.RequestIDHeader, id)
+	}
+
+	started := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.DebugContext(ctx, "upstream request failed", "method", req.Method, "host", req.URL.Host, "path", req.URL.Path, "error", err)
+		return nil, req.Method, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, req.Method, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	slog.DebugContext(ctx, "upstream request", "method", req.Method, "host", req.URL.Host, "path", req.URL.Path, "status", resp.StatusCode, "duration_ms", time.Since(started).Milliseconds())
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, req.Method, nil
+}
+
+// describe summarises a failed attempt for the logs
+func describe(resp *Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("status %d", resp.StatusCode)
+}
+
+// retryableStatus reports whether a status code indicates a transient upstream failure
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryableError reports whether a transport error is worth retrying
+func retryableError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
@@ -0,0 +1,34 @@
+package reservation
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/api/response"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/auth"
+)
+
+// RequireHolder rejects requests that are neither made by an admin nor by the user holding the current reservation
+func (s *Store) RequireHolder(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := auth.UserFromContext(r.Context())
+		switch {
+		case user == nil:
+			response.Unauthorized(w, r, "authentication required")
+			return
+		case !s.IsHolder(user):
+			response.Forbidden(w, r, "the lab is not reserved by you right now")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// IsHolder reports whether user is an admin or holds the current reservation
+func (s *Store) IsHolder(user *auth.User) bool {
+	if user.IsAdmin() {
+		return true
+	}
+	current := s.Current(time.Now())
+	return current != nil && current.User == user.Name
+}
@@ -0,0 +1,67 @@
+package reservation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// icalTime is the UTC date-time format of iCalendar
+const icalTime = "20060102T150405Z"
+
+// ICalendar renders the reservations as an iCalendar (RFC 5545) feed
+func ICalendar(reservations []Reservation, host string) string {
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		b.WriteString(foldLine(fmt.Sprintf(format, args...)))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//GOAD Dashboard//Reservations//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:GOAD lab reservations")
+	for _, r := range reservations {
+		summary := r.Title
+		if summary == "" {
+			summary = "GOAD lab"
+		}
+		line("BEGIN:VEVENT")
+		line("UID:%s@%s", r.ID, host)
+		line("DTSTAMP:%s", time.Unix(r.CreatedAt, 0).UTC().Format(icalTime))
+		line("DTSTART:%s", time.Unix(r.Start, 0).UTC().Format(icalTime))
+		line("DTEND:%s", time.Unix(r.End, 0).UTC().Format(icalTime))
+		line("SUMMARY:%s (%s)", escapeText(summary), escapeText(r.User))
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+
+	return b.String()
+}
+
+// escapeText escapes a TEXT value
+func escapeText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", "").Replace(value)
+}
+
+// foldLine splits content lines longer than 75 octets, continuing them with a leading space
+func foldLine(line string) string {
+	const limit = 75
+	if len(line) <= limit {
+		return line
+	}
+
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
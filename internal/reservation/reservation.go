@@ -0,0 +1,329 @@
+package reservation
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/errdefs"
+)
+
+// pruneAfter is how long ended reservations are kept, so that calendars still show the recent ones
+const pruneAfter = 7 * 24 * time.Hour
+
+var (
+	// ErrOverlap is returned when a time slot overlaps an existing reservation
+	ErrOverlap = errors.New("the lab is already booked during this time")
+	// ErrInvalid is returned for reservations with an unusable time slot
+	ErrInvalid = errors.New("invalid reservation")
+	// ErrLimitReached is returned when a user already holds RESERVATION_MAX_PER_USER upcoming reservations
+	ErrLimitReached = errors.New("reservation limit reached, cancel a reservation first")
+)
+
+// Reservation is a time slot during which a user holds the lab
+type Reservation struct {
+	ID        string `json:"id"`
+	User      string `json:"user"`
+	Title     string `json:"title"`
+	Start     int64  `json:"start"` // Unix 时间戳
+	End       int64  `json:"end"`   // Unix 时间戳, 不含
+	AutoReset bool   `json:"auto_reset"`
+	ResetJob  string `json:"reset_job,omitempty"` // 预约开始时自动重置的任务 ID
+	CreatedAt int64  `json:"created_at"`
+}
+
+// Slot is the part of a reservation a user can choose
+type Slot struct {
+	Title     string `json:"title"`
+	Start     int64  `json:"start"`
+	End       int64  `json:"end"`
+	AutoReset *bool  `json:"auto_reset,omitempty"` // 为空时使用 RESERVATION_AUTO_RESET
+}
+
+// Store keeps the reservations in memory and, if configured, in a JSON file
+type Store struct {
+	path        string
+	autoReset   bool
+	maxDuration time.Duration
+	maxPerUser  int
+
+	mu           sync.Mutex
+	reservations []Reservation
+}
+
+// NewStore creates a reservation store using the application config and loads the reservations file if it exists
+func NewStore(config *config.Config) (*Store, error) {
+	s := &Store{
+		path:         config.GetReservationsFile(),
+		autoReset:    config.GetReservationAutoReset(),
+		maxDuration:  config.GetReservationMaxDuration(),
+		maxPerUser:   config.GetReservationMaxPerUser(),
+		reservations: []Reservation{},
+	}
+
+	if s.path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reservations: %w", err)
+	}
+	var reservations []Reservation
+	if err := json.Unmarshal(data, &reservations); err != nil {
+		return nil, fmt.Errorf("failed to decode reservations: %w", err)
+	}
+	s.reservations = prune(reservations, time.Now())
+	return s, nil
+}
+
+// List returns every reservation ordered by start time
+func (s *Store) List() []Reservation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reservations := make([]Reservation, len(s.reservations))
+	copy(reservations, s.reservations)
+	return reservations
+}
+
+// Get returns a reservation, wrapping errdefs.ErrNotFound if there is none
+func (s *Store) Get(id string) (*Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return nil, fmt.Errorf("reservation %s: %w", id, errdefs.ErrNotFound)
+	}
+	r := s.reservations[i]
+	return &r, nil
+}
+
+// Current returns the reservation holding the lab at t, or nil
+func (s *Store) Current(t time.Time) *Reservation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := t.Unix()
+	for _, r := range s.reservations {
+		if r.Start <= now && now < r.End {
+			return &r
+		}
+	}
+	return nil
+}
+
+// Create books a slot for user. With limited, the user may hold at most RESERVATION_MAX_PER_USER reservations that have not ended.
+func (s *Store) Create(user string, slot Slot, limited bool) (*Reservation, error) {
+	id, err := newReservationID()
+	if err != nil {
+		return nil, err
+	}
+
+	r := Reservation{
+		ID:        id,
+		User:      user,
+		Title:     slot.Title,
+		Start:     slot.Start,
+		End:       slot.End,
+		AutoReset: s.autoReset,
+		CreatedAt: time.Now().Unix(),
+	}
+	if slot.AutoReset != nil {
+		r.AutoReset = *slot.AutoReset
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.validate(r); err != nil {
+		return nil, err
+	}
+	if limited && s.held(user, time.Now()) >= s.maxPerUser {
+		return nil, ErrLimitReached
+	}
+	if err := s.commit(append(s.clone(), r)); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Update moves or renames a reservation
+func (s *Store) Update(id string, slot Slot) (*Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return nil, fmt.Errorf("reservation %s: %w", id, errdefs.ErrNotFound)
+	}
+
+	old := s.reservations[i]
+	r := old
+	r.Title = slot.Title
+	r.Start = slot.Start
+	r.End = slot.End
+	if slot.AutoReset != nil {
+		r.AutoReset = *slot.AutoReset
+	}
+	// Once a reservation has started its reset is kept, so that moving the start cannot trigger another one
+	if r.Start != old.Start && old.Start > time.Now().Unix() {
+		r.ResetJob = ""
+	}
+
+	if err := s.validate(r); err != nil {
+		return nil, err
+	}
+	next := s.clone()
+	next[i] = r
+	if err := s.commit(next); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Delete cancels a reservation
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return fmt.Errorf("reservation %s: %w", id, errdefs.ErrNotFound)
+	}
+
+	next := s.clone()
+	return s.commit(append(next[:i], next[i+1:]...))
+}
+
+// setResetJob records the job that reset the lab for a reservation
+func (s *Store) setResetJob(id string, jobID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return fmt.Errorf("reservation %s: %w", id, errdefs.ErrNotFound)
+	}
+	next := s.clone()
+	next[i].ResetJob = jobID
+	return s.commit(next)
+}
+
+// validate checks the time slot of r against the other reservations. The caller holds s.mu.
+func (s *Store) validate(r Reservation) error {
+	if r.End <= r.Start {
+		return fmt.Errorf("%w: end must be after start", ErrInvalid)
+	}
+	if time.Duration(r.End-r.Start)*time.Second > s.maxDuration {
+		return fmt.Errorf("%w: reservations may last at most %s", ErrInvalid, s.maxDuration)
+	}
+	if r.End <= time.Now().Unix() {
+		return fmt.Errorf("%w: the slot is in the past", ErrInvalid)
+	}
+	for _, other := range s.reservations {
+		if other.ID != r.ID && r.Start < other.End && other.Start < r.End {
+			return ErrOverlap
+		}
+	}
+	return nil
+}
+
+// held counts the reservations of user that have not ended at t. The caller holds s.mu.
+func (s *Store) held(user string, t time.Time) int {
+	held := 0
+	for _, r := range s.reservations {
+		if r.User == user && r.End > t.Unix() {
+			held++
+		}
+	}
+	return held
+}
+
+// clone returns a copy of the reservations to be changed and committed. The caller holds s.mu.
+func (s *Store) clone() []Reservation {
+	return append([]Reservation(nil), s.reservations...)
+}
+
+// index returns the position of a reservation or -1. The caller holds s.mu.
+func (s *Store) index(id string) int {
+	for i, r := range s.reservations {
+		if r.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// commit prunes and sorts the changed reservations, writes them to the reservations file and only then
+// replaces the reservations in memory, which are left untouched if the file cannot be written. The caller holds s.mu.
+func (s *Store) commit(reservations []Reservation) error {
+	reservations = prune(reservations, time.Now())
+	sort.Slice(reservations, func(i, j int) bool { return reservations[i].Start < reservations[j].Start })
+
+	if err := s.save(reservations); err != nil {
+		return err
+	}
+	s.reservations = reservations
+	return nil
+}
+
+// save writes the reservations to the reservations file
+func (s *Store) save(reservations []Reservation) error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(reservations, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Written to a temporary file first so that a crash never leaves a truncated file behind
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".reservations-*")
+	if err != nil {
+		return fmt.Errorf("failed to save reservations: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save reservations: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save reservations: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to save reservations: %w", err)
+	}
+	return nil
+}
+
+// prune drops the reservations that ended more than pruneAfter before t
+func prune(reservations []Reservation, t time.Time) []Reservation {
+	kept := reservations[:0]
+	for _, r := range reservations {
+		if r.End > t.Add(-pruneAfter).Unix() {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// newReservationID returns a random reservation ID
+func newReservationID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
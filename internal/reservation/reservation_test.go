@@ -0,0 +1,123 @@
+package reservation
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	return &Store{
+		path:         filepath.Join(t.TempDir(), "reservations.json"),
+		maxDuration:  8 * time.Hour,
+		maxPerUser:   2,
+		reservations: []Reservation{},
+	}
+}
+
+func TestFailedSaveKeepsReservations(t *testing.T) {
+	s := newTestStore(t)
+	now := time.Now().Unix()
+
+	later, err := s.Create("alice", Slot{Title: "later", Start: now + 7200, End: now + 10800}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create("bob", Slot{Title: "earlier", Start: now + 3600, End: now + 7200}, true); err != nil {
+		t.Fatal(err)
+	}
+	before := s.List()
+
+	// The directory of the reservations file is gone, so every write fails
+	s.path = filepath.Join(t.TempDir(), "missing", "reservations.json")
+
+	if _, err := s.Create("carol", Slot{Title: "latest", Start: now + 10800, End: now + 14400}, true); err == nil {
+		t.Error("create: expected an error")
+	}
+	if _, err := s.Update(later.ID, Slot{Title: "moved", Start: now, End: now + 1800}); err == nil {
+		t.Error("update: expected an error")
+	}
+	if err := s.Delete(later.ID); err == nil {
+		t.Error("delete: expected an error")
+	}
+
+	if after := s.List(); !reflect.DeepEqual(before, after) {
+		t.Errorf("reservations changed by failed writes:\n%+v\nwant\n%+v", after, before)
+	}
+}
+
+func TestUpdateKeepsResetJobOnceStarted(t *testing.T) {
+	s := newTestStore(t)
+	now := time.Now().Unix()
+
+	started, err := s.Create("alice", Slot{Start: now - 60, End: now + 3600}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upcoming, err := s.Create("alice", Slot{Start: now + 7200, End: now + 10800}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{started.ID, upcoming.ID} {
+		if err := s.setResetJob(id, "job-"+id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	moved, err := s.Update(started.ID, Slot{Start: now - 59, End: now + 3600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.ResetJob == "" {
+		t.Error("moving the start of a running reservation cleared its reset job")
+	}
+
+	moved, err = s.Update(upcoming.ID, Slot{Start: now + 5400, End: now + 10800})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.ResetJob != "" {
+		t.Error("moving an upcoming reservation kept its reset job")
+	}
+}
+
+func TestCreateLimit(t *testing.T) {
+	s := newTestStore(t)
+	now := time.Now().Unix()
+
+	for i := int64(0); i < 2; i++ {
+		if _, err := s.Create("alice", Slot{Start: now + 3600*(i+1), End: now + 3600*(i+2)}, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Create("alice", Slot{Start: now + 3600*4, End: now + 3600*5}, true); !errors.Is(err, ErrLimitReached) {
+		t.Errorf("third reservation: got %v, want %v", err, ErrLimitReached)
+	}
+	if _, err := s.Create("alice", Slot{Start: now + 3600*4, End: now + 3600*5}, false); err != nil {
+		t.Errorf("unlimited reservation: %v", err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	s := newTestStore(t)
+	now := time.Now()
+	s.reservations = []Reservation{
+		{ID: "old", Start: now.Add(-pruneAfter - 2*time.Hour).Unix(), End: now.Add(-pruneAfter - time.Hour).Unix()},
+		{ID: "recent", Start: now.Add(-2 * time.Hour).Unix(), End: now.Add(-time.Hour).Unix()},
+	}
+
+	if _, err := s.Create("alice", Slot{Start: now.Add(time.Hour).Unix(), End: now.Add(2 * time.Hour).Unix()}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, r := range s.List() {
+		ids = append(ids, r.ID)
+	}
+	if len(ids) != 2 || ids[0] != "recent" {
+		t.Errorf("reservations %v, want the recent one and the new one", ids)
+	}
+}
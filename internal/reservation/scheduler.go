@@ -0,0 +1,66 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/lab"
+)
+
+// schedulerInterval is how often the scheduler looks for reservations that have started
+const schedulerInterval = 30 * time.Second
+
+// Scheduler resets the lab when a reservation with auto reset starts
+type Scheduler struct {
+	store *Store
+	lab   *lab.Lab
+}
+
+// NewScheduler creates a scheduler for the reservations of store
+func NewScheduler(store *Store, lab *lab.Lab) *Scheduler {
+	return &Scheduler{
+		store: store,
+		lab:   lab,
+	}
+}
+
+// Run checks the current reservation at once, then every schedulerInterval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		s.tick(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick starts the reset of the current reservation if it has not been done yet.
+// A reset blocked by another running job is retried on the next tick.
+func (s *Scheduler) tick(ctx context.Context) {
+	current := s.store.Current(time.Now())
+	if current == nil || !current.AutoReset || current.ResetJob != "" {
+		return
+	}
+
+	// The reset outlives the scheduler, which stops as soon as the server drains
+	job, err := s.lab.Reset(context.WithoutCancel(ctx))
+	if errors.Is(err, lab.ErrJobInProgress) {
+		return
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "failed to reset the lab for reservation", "reservation", current.ID, "error", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "resetting the lab for reservation", "reservation", current.ID, "user", current.User, "job_id", job.ID)
+	if err := s.store.setResetJob(current.ID, job.ID); err != nil {
+		slog.WarnContext(ctx, "failed to record the reset of reservation", "reservation", current.ID, "error", err)
+	}
+}
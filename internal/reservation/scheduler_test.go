@@ -0,0 +1,99 @@
+package reservation
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/fake"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/health"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/lab"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform/proxmox"
+)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// newTestLab returns a lab of one running VM with a snapshot on a fake Proxmox
+func newTestLab(t *testing.T) *lab.Lab {
+	t.Helper()
+
+	pve := fake.NewProxmox("pve1")
+	pve.AddVM(fake.VM{
+		ID:        101,
+		Name:      "DC01",
+		Node:      "pve1",
+		Status:    "running",
+		Agent:     true,
+		Snapshots: []fake.Snapshot{{Name: "provisioned", SnapTime: 1700000000}},
+	})
+	server := httptest.NewServer(pve)
+	t.Cleanup(server.Close)
+
+	for name, value := range map[string]string{
+		"PROXMOX_URL":            server.URL,
+		"PROXMOX_USERNAME":       "dashboard",
+		"PROXMOX_REALM":          "pve",
+		"PROXMOX_API_TOKEN_NAME": "test",
+		"PROXMOX_API_TOKEN":      "secret",
+		"PFSENSE_URL":            "http://127.0.0.1:1",
+		"PFSENSE_USERNAME":       "admin",
+		"PFSENSE_PASSWORD":       "pfsense",
+		"UPSTREAM_RETRIES":       "0",
+	} {
+		t.Setenv(name, value)
+	}
+	config, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("failed to load configuration: %v", err)
+	}
+	client, err := proxmox.NewPVEClientFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := lab.NewLab(config, client, health.NewChecker(config))
+	t.Cleanup(l.Jobs().Wait)
+	return l
+}
+
+func TestSchedulerResetsOnStart(t *testing.T) {
+	s := newTestStore(t)
+	l := newTestLab(t)
+	scheduler := NewScheduler(s, l)
+	ctx := context.Background()
+
+	now := time.Now().Unix()
+	autoReset := true
+	r, err := s.Create("alice", Slot{Start: now - 60, End: now + 3600, AutoReset: &autoReset}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheduler.tick(ctx)
+	jobs := l.Jobs().Jobs()
+	if len(jobs) != 1 || jobs[0].Kind != lab.JobReset {
+		t.Fatalf("jobs %+v, want one reset", jobs)
+	}
+	if r, _ = s.Get(r.ID); r.ResetJob != jobs[0].ID {
+		t.Errorf("reset job %q recorded, want %q", r.ResetJob, jobs[0].ID)
+	}
+
+	l.Jobs().Wait()
+	if job, _ := l.Jobs().Get(jobs[0].ID); job.Status != lab.JobSucceeded {
+		t.Errorf("reset %s: %s", job.Status, job.Message)
+	}
+
+	// The reservation has been reset, later ticks leave the lab alone
+	scheduler.tick(ctx)
+	if jobs := l.Jobs().Jobs(); len(jobs) != 1 {
+		t.Errorf("%d jobs after the second tick, want the reset only", len(jobs))
+	}
+}
@@ -0,0 +1,423 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/api/controllers"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/api/response"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/auth"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/console"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/demo"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/health"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/instance"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/lab"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/logging"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/notify"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform/pfsense"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/platform/proxmox"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/reservation"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/httprate"
+	httpSwagger "github.com/swaggo/http-swagger"
+)
+
+// Server is the dashboard: the router serving the API and the probes, and the managers and background tasks behind it
+type Server struct {
+	config        *config.Config
+	hypervisor    platform.Hypervisor
+	gateway       platform.VPNGateway
+	services      health.Prober
+	labManager    *lab.Lab
+	reservations  *reservation.Store
+	notifications *notify.Hub
+	instances     *instance.Manager
+	consoles      *console.Manager
+	authenticator *auth.Authenticator
+	probes        *controllers.ProbeController
+	router        chi.Router
+	demo          *demo.Lab // 演示模式下模拟的实验室
+
+	// Background loops stop on Drain, the notification hub only once the lab jobs have drained in Shutdown
+	background        context.Context
+	stopBackground    context.CancelFunc
+	workers           sync.WaitGroup
+	stopNotifications context.CancelFunc
+	notificationsDone chan struct{}
+}
+
+// Backends are the systems the dashboard drives
+type Backends struct {
+	Hypervisor platform.Hypervisor
+	Gateway    platform.VPNGateway
+	Services   health.Prober
+
+	demo *demo.Lab // 演示模式下模拟的实验室
+}
+
+// NewBackends creates the Proxmox and pfSense clients and the lab service checker or, in demo mode, a simulated lab
+// standing in for all three
+func NewBackends(config *config.Config) (Backends, error) {
+	if config.GetDemo() {
+		lab := demo.NewLab(config)
+		return Backends{Hypervisor: lab, Gateway: lab, Services: lab, demo: lab}, nil
+	}
+
+	pveClient, err := proxmox.NewPVEClientFromConfig(config)
+	if err != nil {
+		return Backends{}, fmt.Errorf("failed to create Proxmox client: %w", err)
+	}
+
+	pfsenseClient, err := pfsense.NewPfsenseClient(config)
+	if err != nil {
+		return Backends{}, fmt.Errorf("failed to create pfSense client: %w", err)
+	}
+
+	return Backends{Hypervisor: pveClient, Gateway: pfsenseClient, Services: health.NewChecker(config)}, nil
+}
+
+// NewServer creates the managers of the dashboard, driving the given backends, and its router.
+// Background tasks run once Start is called.
+func NewServer(config *config.Config, backends Backends) (*Server, error) {
+	reservations, err := reservation.NewStore(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reservations: %w", err)
+	}
+
+	emails, err := notify.NewEmailsFromConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up email notifications: %w", err)
+	}
+	notifications := notify.NewHub()
+	for _, webhook := range notify.NewWebhooksFromConfig(config) {
+		notifications.Add(webhook, config.GetWebhookRetries())
+	}
+	for _, email := range emails {
+		notifications.Add(email, config.GetSMTPRetries())
+	}
+
+	labManager := lab.NewLab(config, backends.Hypervisor, backends.Services)
+	labManager.Jobs().OnFinish(func(job lab.Job) {
+		if event, ok := notify.JobEvent(job); ok {
+			notifications.Publish(event)
+		}
+	})
+
+	background, stopBackground := context.WithCancel(context.Background())
+	s := &Server{
+		config:         config,
+		hypervisor:     backends.Hypervisor,
+		gateway:        backends.Gateway,
+		services:       backends.Services,
+		labManager:     labManager,
+		reservations:   reservations,
+		notifications:  notifications,
+		instances:      instance.NewManager(config, backends.Hypervisor),
+		consoles:       console.NewManager(backends.Hypervisor),
+		authenticator:  auth.NewAuthenticator(config),
+		probes:         controllers.NewProbeController(backends.Hypervisor, backends.Gateway),
+		demo:           backends.demo,
+		background:     background,
+		stopBackground: stopBackground,
+	}
+	s.router = s.routes()
+	return s, nil
+}
+
+// ServeHTTP serves a request through the router
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+// ServeUI serves the files of ui for every path no API route matches
+func (s *Server) ServeUI(ui fs.FS) {
+	s.router.NotFound(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.FileServer(http.FS(ui)).ServeHTTP(w, r)
+	}))
+}
+
+// ServeSwagger serves the OpenAPI document and the Swagger UI under /swagger
+func (s *Server) ServeSwagger(doc []byte) {
+	s.router.Get("/swagger/doc.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(doc)
+	})
+	scheme := "http"
+	if s.config.GetTLSCertFile() != "" {
+		scheme = "https"
+	}
+	s.router.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(fmt.Sprintf("%s://localhost:%s/swagger/doc.json", scheme, s.config.GetPort()))))
+}
+
+// Probes returns the controller of the liveness and readiness probes, to serve them on other listeners
+func (s *Server) Probes() *controllers.ProbeController {
+	return s.probes
+}
+
+// Authenticator returns the authenticator of the API users
+func (s *Server) Authenticator() *auth.Authenticator {
+	return s.authenticator
+}
+
+// Start starts the background tasks: the reservation scheduler, the notification hub and watcher, the instance reaper
+// and, in demo mode, the simulated lab activity
+func (s *Server) Start() {
+	if s.demo != nil {
+		s.Go(s.demo.Run)
+	}
+	s.Go(reservation.NewScheduler(s.reservations, s.labManager).Run)
+
+	notificationsCtx, stopNotifications := context.WithCancel(context.Background())
+	s.stopNotifications = stopNotifications
+	s.notificationsDone = make(chan struct{})
+	go func() {
+		defer close(s.notificationsDone)
+		s.notifications.Run(notificationsCtx)
+	}()
+	s.Go(notify.NewWatcher(s.config, s.hypervisor, s.gateway, s.reservations, s.labManager.Jobs(), s.notifications).Run)
+
+	s.Go(s.instances.Run)
+}
+
+// Go runs a background task until Drain is called
+func (s *Server) Go(run func(ctx context.Context)) {
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		run(s.background)
+	}()
+}
+
+// Drain fails the readiness probe and stops the background loops. Requests are still served.
+func (s *Server) Drain() {
+	s.probes.Drain()
+	s.stopBackground()
+}
+
+// Shutdown waits, until ctx is done, for the lab jobs and instance operations in flight,
+// then for the pending notifications and the background tasks. It is called once the HTTP server has stopped.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.Drain()
+
+	var errs []error
+	if err := wait(ctx, s.labManager.Jobs().Wait); err != nil {
+		errs = append(errs, fmt.Errorf("a lab job is still running and will be interrupted: %w", err))
+	}
+	if err := wait(ctx, s.instances.Wait); err != nil {
+		errs = append(errs, fmt.Errorf("instances are still being provisioned or torn down and will be interrupted: %w", err))
+	}
+
+	waits := []func(){s.workers.Wait}
+	if s.stopNotifications != nil {
+		s.stopNotifications()
+		waits = append(waits, func() { <-s.notificationsDone })
+	}
+	if err := wait(ctx, waits...); err != nil {
+		errs = append(errs, fmt.Errorf("failed to stop background tasks: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// routes builds the router serving the API and the probes
+func (s *Server) routes() chi.Router {
+	pveController := controllers.NewPVEController(s.hypervisor, s.gateway, s.labManager)
+	consoleController := controllers.NewConsoleController(s.hypervisor, s.consoles, s.instances, s.reservations)
+
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Use(middleware.RealIP)
+	router.Use(logging.Middleware)
+	router.Use(middleware.Recoverer)
+	router.Use(s.authenticator.Middleware)
+
+	// Console websockets live as long as the session, every other request is bounded
+	apiTimeout := middleware.Timeout(60 * time.Second)
+
+	// PVE API endpoints
+	router.Route("/api/pve", func(r chi.Router) {
+		r.Use(middleware.SetHeader("Content-Type", "application/json"))
+		r.NotFound(response.NotFoundHandler)
+		r.MethodNotAllowed(response.MethodNotAllowedHandler)
+
+		// GET group
+		r.Group(func(r chi.Router) {
+			r.Use(apiTimeout)
+			r.Use(limitByIP(2, 1*time.Second))
+			r.Get("/vms", pveController.GetVMs)
+			r.Get("/vms/{vmid}/metrics", pveController.GetVMMetrics)
+			r.Get("/nodes", pveController.GetNodes)
+			r.Get("/storage", pveController.GetStorage)
+			r.Get("/reset", pveController.GetLastReset)
+			r.Get("/jobs", pveController.GetJobs)
+			r.Get("/jobs/{id}", pveController.GetJob)
+		})
+
+		// POST group, for admins and the holder of the current reservation
+		r.Group(func(r chi.Router) {
+			r.Use(apiTimeout)
+			r.Use(s.reservations.RequireHolder)
+			r.Use(limitByIP(1, 10*time.Second))
+			r.Post("/vms/start", pveController.StartAllVMs)
+			r.Post("/vms/stop", pveController.StopAllVMs)
+			r.Post("/vms/shutdown", pveController.ShutdownAllVMs)
+			r.Post("/vms/suspend", pveController.SuspendAllVMs)
+			r.Post("/vms/resume", pveController.ResumeAllVMs)
+			r.Post("/vms/reset", pveController.ResetAllVMs)
+			r.Post("/reset", pveController.ResetLab)
+		})
+
+		// Console group
+		r.Group(func(r chi.Router) {
+			r.With(apiTimeout, auth.RequireUser, limitByIP(2, 10*time.Second)).Post("/vms/{vmid}/console", consoleController.OpenConsole)
+			r.Get("/vms/{vmid}/console", consoleController.ConnectConsole)
+		})
+	})
+
+	pfsenseController := controllers.NewPfsenseController(s.gateway)
+
+	// PFSENSE API endpoints
+	router.Route("/api/pfsense", func(r chi.Router) {
+		r.Use(apiTimeout)
+		r.Use(middleware.SetHeader("Content-Type", "application/json"))
+		r.NotFound(response.NotFoundHandler)
+		r.MethodNotAllowed(response.MethodNotAllowedHandler)
+
+		// GET group
+		r.Group(func(r chi.Router) {
+			r.Use(limitByIP(2, 1*time.Second))
+			r.Get("/openvpn/connections", pfsenseController.GetOpenVPNConnections)
+			r.Get("/modes", pfsenseController.GetNetworkModes)
+			r.Get("/health", pfsenseController.GetHealth)
+			r.Get("/leases", pfsenseController.GetLeases)
+		})
+
+		// POST group
+		r.Group(func(r chi.Router) {
+			r.Use(auth.RequireAdmin)
+			r.Use(limitByIP(1, 10*time.Second))
+			r.Post("/modes/{mode}", pfsenseController.SetNetworkMode)
+		})
+	})
+
+	instanceController := controllers.NewInstanceController(s.instances)
+
+	// Lab instance endpoints
+	router.Route("/api/instances", func(r chi.Router) {
+		r.Use(apiTimeout)
+		r.Use(middleware.SetHeader("Content-Type", "application/json"))
+		r.NotFound(response.NotFoundHandler)
+		r.MethodNotAllowed(response.MethodNotAllowedHandler)
+		r.Use(auth.RequireUser)
+
+		// GET group
+		r.Group(func(r chi.Router) {
+			r.Use(limitByIP(2, 1*time.Second))
+			r.Get("/", instanceController.GetInstances)
+			r.Get("/{id}", instanceController.GetInstance)
+		})
+
+		// POST group
+		r.Group(func(r chi.Router) {
+			r.Use(limitByIP(1, 10*time.Second))
+			r.Post("/", instanceController.CreateInstance)
+			r.Delete("/{id}", instanceController.DeleteInstance)
+		})
+	})
+
+	reservationController := controllers.NewReservationController(s.reservations, s.authenticator)
+
+	// Lab reservation endpoints
+	router.Route("/api/reservations", func(r chi.Router) {
+		r.Use(apiTimeout)
+		r.Use(middleware.SetHeader("Content-Type", "application/json"))
+		r.NotFound(response.NotFoundHandler)
+		r.MethodNotAllowed(response.MethodNotAllowedHandler)
+
+		// GET group
+		r.Group(func(r chi.Router) {
+			r.Use(limitByIP(2, 1*time.Second))
+			r.Get("/", reservationController.GetReservations)
+			r.Get("/current", reservationController.GetCurrentReservation)
+			r.Get("/calendar.ics", reservationController.GetCalendar)
+			r.Get("/{id}", reservationController.GetReservation)
+		})
+
+		// POST group
+		r.Group(func(r chi.Router) {
+			r.Use(auth.RequireUser)
+			r.Use(limitByIP(1, 2*time.Second))
+			r.Post("/", reservationController.CreateReservation)
+			r.Put("/{id}", reservationController.UpdateReservation)
+			r.Delete("/{id}", reservationController.DeleteReservation)
+		})
+	})
+
+	notificationController := controllers.NewNotificationController(s.notifications)
+
+	// Notification endpoints
+	router.Route("/api/notifications", func(r chi.Router) {
+		r.Use(apiTimeout)
+		r.Use(middleware.SetHeader("Content-Type", "application/json"))
+		r.NotFound(response.NotFoundHandler)
+		r.MethodNotAllowed(response.MethodNotAllowedHandler)
+		r.Use(auth.RequireAdmin)
+
+		r.With(limitByIP(2, 1*time.Second)).Get("/deliveries", notificationController.GetDeliveries)
+		r.With(limitByIP(1, 10*time.Second)).Post("/test", notificationController.SendTest)
+	})
+
+	healthController := controllers.NewHealthController(s.services)
+
+	// Lab health endpoints
+	router.Route("/api/health", func(r chi.Router) {
+		r.Use(apiTimeout)
+		r.Use(middleware.SetHeader("Content-Type", "application/json"))
+		r.NotFound(response.NotFoundHandler)
+		r.MethodNotAllowed(response.MethodNotAllowedHandler)
+
+		r.Use(limitByIP(1, 2*time.Second))
+		r.Get("/lab", healthController.GetLabHealth)
+	})
+
+	// Liveness and readiness probes
+	router.Group(func(r chi.Router) {
+		r.Use(apiTimeout)
+		r.Get("/healthz", s.probes.Healthz)
+		r.Get("/readyz", s.probes.Readyz)
+	})
+
+	return router
+}
+
+// wait runs every fn and returns once they have all returned or ctx is done
+func wait(ctx context.Context, fns ...func()) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, fn := range fns {
+			fn()
+		}
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// limitByIP rate limits requests per client IP, answering with a JSON error once the limit is hit
+func limitByIP(requestLimit int, windowLength time.Duration) func(next http.Handler) http.Handler {
+	return httprate.Limit(requestLimit, windowLength,
+		httprate.WithKeyFuncs(httprate.KeyByIP),
+		httprate.WithLimitHandler(response.RateLimited),
+	)
+}
@@ -0,0 +1,819 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/fake"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	adminToken   = "admin-token"
+	studentToken = "student-token"
+)
+
+// goadVMs are the VMs of the fake lab, all running with a guest agent and two snapshots
+var goadVMs = []string{"DC01", "DC02", "DC03", "SRV02", "SRV03"}
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// testLab is the real router wired to fake Proxmox and pfSense servers
+type testLab struct {
+	t         *testing.T
+	pve       *fake.Proxmox
+	pfsense   *fake.Pfsense
+	srv       *Server
+	server    *httptest.Server
+	requestID int
+}
+
+// newTestLab starts the fakes and the dashboard. env overrides the default configuration.
+func newTestLab(t *testing.T, env map[string]string) *testLab {
+	t.Helper()
+
+	pve := fake.NewProxmox("pve1")
+	for i, name := range goadVMs {
+		pve.AddVM(fake.VM{
+			ID:      101 + i,
+			Name:    name,
+			Node:    "pve1",
+			Status:  "running",
+			CPUs:    2,
+			CPU:     0.1,
+			MaxMem:  4 << 30,
+			Mem:     2 << 30,
+			MaxDisk: 40 << 30,
+			Uptime:  3600,
+			Agent:   true,
+			OS:      "Windows Server 2019",
+			IP:      fmt.Sprintf("192.168.56.%d", 10+i),
+			MAC:     fmt.Sprintf("bc:24:11:00:00:%02x", 1+i),
+			Snapshots: []fake.Snapshot{
+				{Name: "installed", SnapTime: 1700000000},
+				{Name: "provisioned", SnapTime: 1700003600},
+			},
+		})
+	}
+	pfsense := fake.NewPfsense()
+
+	pveServer := httptest.NewServer(pve)
+	t.Cleanup(pveServer.Close)
+	pfsenseServer := httptest.NewServer(pfsense)
+	t.Cleanup(pfsenseServer.Close)
+
+	settings := map[string]string{
+		"AUTH_USERS":             "root:admin:" + adminToken + ",alice:student:" + studentToken,
+		"PROXMOX_URL":            pveServer.URL,
+		"PROXMOX_USERNAME":       "dashboard",
+		"PROXMOX_REALM":          "pve",
+		"PROXMOX_API_TOKEN_NAME": "test",
+		"PROXMOX_API_TOKEN":      "secret",
+		"PROXMOX_TIMEOUT":        "500ms",
+		"PFSENSE_URL":            pfsenseServer.URL,
+		"PFSENSE_USERNAME":       "admin",
+		"PFSENSE_PASSWORD":       "pfsense",
+		"PFSENSE_TIMEOUT":        "500ms",
+		"UPSTREAM_RETRIES":       "0",
+		"LAB_READY_TIMEOUT":      "5s",
+	}
+	for name, value := range env {
+		settings[name] = value
+	}
+	for name, value := range settings {
+		t.Setenv(name, value)
+	}
+
+	config, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("failed to load configuration: %v", err)
+	}
+	backends, err := NewBackends(config)
+	if err != nil {
+		t.Fatalf("failed to create backends: %v", err)
+	}
+	srv, err := NewServer(config, backends)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+
+	server := httptest.NewServer(srv)
+	t.Cleanup(server.Close)
+	// Jobs outlive their requests, wait for them so that they do not talk to closed fakes
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			t.Errorf("failed to shut down: %v", err)
+		}
+	})
+
+	return &testLab{t: t, pve: pve, pfsense: pfsense, srv: srv, server: server}
+}
+
+// do sends a request to the dashboard and decodes the JSON response into out, if given.
+// Every request comes from its own client IP so that the rate limits do not interfere.
+func (l *testLab) do(method string, path string, token string, out interface{}) int {
+	l.t.Helper()
+	return l.send(method, path, token, nil, out)
+}
+
+// send is do with a JSON request body, if given
+func (l *testLab) send(method string, path string, token string, body interface{}, out interface{}) int {
+	l.t.Helper()
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			l.t.Fatal(err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, l.server.URL+path, reqBody)
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	l.requestID++
+	req.Header.Set("X-Real-IP", fmt.Sprintf("10.0.%d.%d", l.requestID/250, l.requestID%250+1))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		l.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			l.t.Fatalf("%s %s: failed to decode response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// waitJob polls a lab job until it finishes
+func (l *testLab) waitJob(id string) jobResponse {
+	l.t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		var job jobResponse
+		if status := l.do("GET", "/api/pve/jobs/"+id, studentToken, &job); status != http.StatusOK {
+			l.t.Fatalf("GET job %s: status %d", id, status)
+		}
+		if job.Status != "running" {
+			return job
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	l.t.Fatalf("job %s did not finish", id)
+	return jobResponse{}
+}
+
+type jobResponse struct {
+	ID      string `json:"id"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	VMs     []struct {
+		VMID     string `json:"vmid"`
+		Name     string `json:"name"`
+		Rollback string `json:"rollback"`
+		Power    string `json:"power"`
+		Agent    string `json:"agent"`
+	} `json:"vms"`
+}
+
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Source  string `json:"source"`
+}
+
+func TestGetVMs(t *testing.T) {
+	lab := newTestLab(t, nil)
+	lab.pve.AddVM(fake.VM{ID: 9000, Name: "WinServer2019-template", Node: "pve1", Template: true})
+	lab.pve.UpdateVM(105, func(vm *fake.VM) { vm.Status = "stopped" })
+	lab.pfsense.AddLease(fake.Lease{IP: "192.168.56.10", MAC: "BC:24:11:00:00:01", Hostname: "dc01"})
+
+	var vms []struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		Status      string `json:"status"`
+		LabIP       string `json:"lab_ip"`
+		Hostname    string `json:"hostname"`
+		AgentStatus string `json:"agent_status"`
+	}
+	if status := lab.do("GET", "/api/pve/vms", studentToken, &vms); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+
+	if len(vms) != len(goadVMs) {
+		t.Fatalf("got %d VMs, want %d without the template", len(vms), len(goadVMs))
+	}
+	if vms[0].Name != "DC01" || vms[0].LabIP != "192.168.56.10" || vms[0].Hostname != "dc01" || vms[0].AgentStatus != "ok" {
+		t.Errorf("unexpected DC01: %+v", vms[0])
+	}
+	if vms[4].Status != "stopped" || vms[4].AgentStatus != "vm_stopped" {
+		t.Errorf("unexpected SRV03: %+v", vms[4])
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	lab := newTestLab(t, nil)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{"missing token", "GET", "/api/instances/", "", http.StatusUnauthorized},
+		{"invalid token", "GET", "/api/pve/vms", "wrong", http.StatusUnauthorized},
+		{"student without reservation", "POST", "/api/pve/reset", studentToken, http.StatusForbidden},
+		{"student on admin endpoint", "GET", "/api/notifications/deliveries", studentToken, http.StatusForbidden},
+		{"student", "GET", "/api/pve/vms", studentToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if status := lab.do(tt.method, tt.path, tt.token, nil); status != tt.status {
+			t.Errorf("%s: %s %s: status %d, want %d", tt.name, tt.method, tt.path, status, tt.status)
+		}
+	}
+}
+
+func TestResetLab(t *testing.T) {
+	lab := newTestLab(t, nil)
+
+	var job jobResponse
+	if status := lab.do("POST", "/api/pve/reset", adminToken, &job); status != http.StatusAccepted {
+		t.Fatalf("status %d", status)
+	}
+	job = lab.waitJob(job.ID)
+
+	if job.Status != "succeeded" {
+		t.Fatalf("job %s: %s", job.Status, job.Message)
+	}
+	if len(job.VMs) != len(goadVMs) {
+		t.Fatalf("got %d VM results, want %d", len(job.VMs), len(goadVMs))
+	}
+	for _, vm := range job.VMs {
+		if vm.Rollback != "ok" || vm.Power != "started" || vm.Agent != "ok" {
+			t.Errorf("unexpected result for %s: %+v", vm.Name, vm)
+		}
+	}
+
+	for i := range goadVMs {
+		vm, _ := lab.pve.VM(101 + i)
+		if vm.Status != "running" {
+			t.Errorf("%s is %s after the reset", vm.Name, vm.Status)
+		}
+		rollback := fmt.Sprintf("POST /nodes/pve1/qemu/%d/snapshot/provisioned/rollback", vm.ID)
+		if !contains(lab.pve.Requests(), rollback) {
+			t.Errorf("%s was not rolled back to its latest snapshot", vm.Name)
+		}
+	}
+}
+
+func TestResetLabRollbackFailure(t *testing.T) {
+	lab := newTestLab(t, nil)
+	lab.pve.Inject(fake.Fault{Method: "POST", Path: "/nodes/*/qemu/102/snapshot/*/rollback", TaskError: "snapshot feature is not available"})
+
+	var job jobResponse
+	if status := lab.do("POST", "/api/pve/reset", adminToken, &job); status != http.StatusAccepted {
+		t.Fatalf("status %d", status)
+	}
+	job = lab.waitJob(job.ID)
+
+	if job.Status != "failed" || !strings.Contains(job.Message, "DC02") {
+		t.Fatalf("got %s %q, want a failed job naming DC02", job.Status, job.Message)
+	}
+
+	// The other VMs are still reset
+	for _, vm := range job.VMs {
+		want := "ok"
+		if vm.Name == "DC02" {
+			want = "failed"
+		}
+		if vm.Rollback != want {
+			t.Errorf("%s: rollback %q, want %q", vm.Name, vm.Rollback, want)
+		}
+	}
+}
+
+func TestResetLabConflict(t *testing.T) {
+	lab := newTestLab(t, nil)
+	lab.pve.SetTaskDuration(300 * time.Millisecond)
+
+	var job jobResponse
+	if status := lab.do("POST", "/api/pve/reset", adminToken, &job); status != http.StatusAccepted {
+		t.Fatalf("status %d", status)
+	}
+	for _, path := range []string{"/api/pve/vms/start", "/api/pve/vms/stop", "/api/pve/vms/suspend", "/api/pve/vms/resume", "/api/pve/vms/reset"} {
+		if status := lab.do("POST", path, adminToken, nil); status != http.StatusConflict {
+			t.Errorf("POST %s during a reset: status %d, want %d", path, status, http.StatusConflict)
+		}
+	}
+	lab.waitJob(job.ID)
+
+	if status := lab.do("POST", "/api/pve/vms/suspend", adminToken, nil); status != http.StatusOK {
+		t.Errorf("suspending the VMs after the reset: status %d", status)
+	}
+}
+
+func TestLabJobTimeout(t *testing.T) {
+	lab := newTestLab(t, map[string]string{
+		"LAB_READY_TIMEOUT":    "500ms",
+		"LAB_SHUTDOWN_TIMEOUT": "500ms",
+		"LAB_JOB_TIMEOUT":      "1s",
+	})
+	// The rollback tasks never finish
+	lab.pve.SetTaskDuration(time.Hour)
+
+	var job jobResponse
+	if status := lab.do("POST", "/api/pve/reset", adminToken, &job); status != http.StatusAccepted {
+		t.Fatalf("status %d", status)
+	}
+	job = lab.waitJob(job.ID)
+	if job.Status != "failed" || !strings.HasPrefix(job.Message, "timed out after 1s") {
+		t.Errorf("job %s: %s, want failed after the timeout", job.Status, job.Message)
+	}
+
+	lab.pve.SetTaskDuration(0)
+	if status := lab.do("POST", "/api/pve/vms/start", adminToken, &job); status != http.StatusAccepted {
+		t.Errorf("starting the VMs after the timed out job: status %d", status)
+	}
+	lab.waitJob(job.ID)
+
+	if status := lab.do("POST", "/api/pve/vms/shutdown?timeout=5s", adminToken, nil); status != http.StatusBadRequest {
+		t.Errorf("shutdown timeout beyond the job timeout: status %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestConsoleRequiresReservation(t *testing.T) {
+	lab := newTestLab(t, nil)
+
+	if status := lab.do("POST", "/api/pve/vms/101/console", studentToken, nil); status != http.StatusForbidden {
+		t.Errorf("console of a shared VM without the reservation: status %d, want %d", status, http.StatusForbidden)
+	}
+	if status := lab.do("POST", "/api/pve/vms/101/console", adminToken, nil); status == http.StatusForbidden {
+		t.Errorf("console of a shared VM for an admin: status %d", status)
+	}
+}
+
+func TestOpenVPNConnections(t *testing.T) {
+	lab := newTestLab(t, nil)
+	lab.pfsense.Connect("alice")
+	lab.pfsense.Connect("bob")
+	lab.pfsense.Disconnect("bob")
+
+	var connections []struct {
+		ID          int    `json:"id"`
+		Name        string `json:"common_name"`
+		ConnectTime uint64 `json:"connect_time_unix"`
+	}
+	if status := lab.do("GET", "/api/pfsense/openvpn/connections", studentToken, &connections); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+
+	if len(connections) != 1 || connections[0].Name != "alice" || connections[0].ConnectTime == 0 {
+		t.Errorf("got %+v, want alice only", connections)
+	}
+}
+
+func TestUpstreamFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		pve     *fake.Fault
+		pfsense *fake.Fault
+		path    string
+		status  int
+		code    string
+		source  string
+	}{
+		{
+			name:   "proxmox error",
+			pve:    &fake.Fault{Path: "/nodes", Status: http.StatusInternalServerError},
+			path:   "/api/pve/vms",
+			status: http.StatusBadGateway,
+			code:   "upstream_error",
+			source: "proxmox",
+		},
+		{
+			name:   "proxmox timeout",
+			pve:    &fake.Fault{Path: "/nodes/*/qemu", Delay: 2 * time.Second},
+			path:   "/api/pve/vms",
+			status: http.StatusGatewayTimeout,
+			code:   "upstream_timeout",
+			source: "proxmox",
+		},
+		{
+			name:   "proxmox not found",
+			pve:    &fake.Fault{Path: "/nodes/*/qemu/101/rrddata", Status: http.StatusNotFound},
+			path:   "/api/pve/vms/101/metrics",
+			status: http.StatusNotFound,
+			code:   "not_found",
+			source: "proxmox",
+		},
+		{
+			name:    "pfsense error",
+			pfsense: &fake.Fault{Path: "/api/v2/status/openvpn/servers", Status: http.StatusServiceUnavailable},
+			path:    "/api/pfsense/openvpn/connections",
+			status:  http.StatusBadGateway,
+			code:    "upstream_error",
+			source:  "pfsense",
+		},
+		{
+			name:    "pfsense timeout",
+			pfsense: &fake.Fault{Path: "/api/v2/status/openvpn/servers", Delay: 2 * time.Second},
+			path:    "/api/pfsense/openvpn/connections",
+			status:  http.StatusGatewayTimeout,
+			code:    "upstream_timeout",
+			source:  "pfsense",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lab := newTestLab(t, nil)
+			if tt.pve != nil {
+				lab.pve.Inject(*tt.pve)
+			}
+			if tt.pfsense != nil {
+				lab.pfsense.Inject(*tt.pfsense)
+			}
+
+			var body errorResponse
+			status := lab.do("GET", tt.path, studentToken, &body)
+			if status != tt.status || body.Code != tt.code || body.Source != tt.source {
+				t.Errorf("got %d %+v, want %d %s from %s", status, body, tt.status, tt.code, tt.source)
+			}
+			if strings.Contains(body.Message, "injected") {
+				t.Errorf("upstream detail leaked to the client: %q", body.Message)
+			}
+		})
+	}
+}
+
+func TestUpstreamRetry(t *testing.T) {
+	lab := newTestLab(t, map[string]string{"UPSTREAM_RETRIES": "2", "UPSTREAM_RETRY_BACKOFF": "10ms"})
+	lab.pve.Inject(fake.Fault{Method: "GET", Path: "/nodes", Status: http.StatusServiceUnavailable, Times: 2})
+
+	if status := lab.do("GET", "/api/pve/vms", studentToken, nil); status != http.StatusOK {
+		t.Errorf("status %d, want the request to succeed after two retries", status)
+	}
+}
+
+func TestProbes(t *testing.T) {
+	lab := newTestLab(t, nil)
+
+	if status := lab.do("GET", "/healthz", "", nil); status != http.StatusOK {
+		t.Errorf("healthz: status %d", status)
+	}
+
+	var ready struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+	if status := lab.do("GET", "/readyz", "", &ready); status != http.StatusOK || ready.Status != "ok" {
+		t.Errorf("readyz: status %d %+v", status, ready)
+	}
+
+	lab.pfsense.Inject(fake.Fault{Path: "/api/v2/status/system", Status: http.StatusInternalServerError})
+	if status := lab.do("GET", "/readyz", "", &ready); status != http.StatusServiceUnavailable || ready.Checks["pfsense"] == "ok" || ready.Checks["proxmox"] != "ok" {
+		t.Errorf("readyz with pfSense down: status %d %+v", status, ready)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDemo(t *testing.T) {
+	t.Setenv("DEMO", "1")
+	t.Setenv("AUTH_USERS", "root:admin:"+adminToken)
+	config, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("failed to load configuration without credentials: %v", err)
+	}
+	backends, err := NewBackends(config)
+	if err != nil {
+		t.Fatalf("failed to create backends: %v", err)
+	}
+	srv, err := NewServer(config, backends)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	lab := &testLab{t: t, server: httptest.NewServer(srv)}
+	defer func() {
+		lab.server.Close()
+		// The instance keeps being provisioned in the background for a while
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	var vms []struct {
+		Name        string `json:"name"`
+		Status      string `json:"status"`
+		AgentStatus string `json:"agent_status"`
+	}
+	if status := lab.do("GET", "/api/pve/vms", adminToken, &vms); status != http.StatusOK || len(vms) != len(goadVMs) {
+		t.Fatalf("status %d with %d VMs, want the %d GOAD VMs", status, len(vms), len(goadVMs))
+	}
+	for i, vm := range vms {
+		if vm.Name != goadVMs[i] || vm.Status != "running" || vm.AgentStatus != "ok" {
+			t.Errorf("unexpected VM: %+v", vm)
+		}
+	}
+
+	var connections []json.RawMessage
+	if status := lab.do("GET", "/api/pfsense/openvpn/connections", adminToken, &connections); status != http.StatusOK || len(connections) == 0 {
+		t.Errorf("status %d with %d VPN connections, want some", status, len(connections))
+	}
+
+	if status := lab.do("POST", "/api/pfsense/modes/exam", adminToken, nil); status != http.StatusOK {
+		t.Fatalf("POST exam mode: status %d", status)
+	}
+	var modes struct {
+		Current string `json:"current"`
+	}
+	if status := lab.do("GET", "/api/pfsense/modes", adminToken, &modes); status != http.StatusOK || modes.Current != "exam" {
+		t.Errorf("status %d in mode %q, want exam", status, modes.Current)
+	}
+
+	var labHealth struct {
+		State string `json:"state"`
+		Hosts []struct {
+			Name   string `json:"name"`
+			State  string `json:"state"`
+			Probes []struct {
+				Name   string `json:"name"`
+				Detail string `json:"detail"`
+			} `json:"probes"`
+		} `json:"hosts"`
+	}
+	if status := lab.do("GET", "/api/health/lab", adminToken, &labHealth); status != http.StatusOK || labHealth.State != "healthy" || len(labHealth.Hosts) != len(goadVMs) {
+		t.Fatalf("lab health: status %d %+v, want the %d GOAD hosts healthy", status, labHealth, len(goadVMs))
+	}
+	if probe := labHealth.Hosts[0].Probes[len(labHealth.Hosts[0].Probes)-2]; probe.Name != "ldap" || probe.Detail != "DC=sevenkingdoms,DC=local" {
+		t.Errorf("LDAP probe of DC01: %+v", probe)
+	}
+
+	// The console serves the RFB protocol through the websocket relay
+	var session struct {
+		URL string `json:"url"`
+	}
+	if status := lab.do("POST", "/api/pve/vms/101/console", adminToken, &session); status != http.StatusCreated {
+		t.Fatalf("POST console: status %d", status)
+	}
+	wsConfig, err := websocket.NewConfig("ws"+strings.TrimPrefix(lab.server.URL, "http")+session.URL, lab.server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wsConfig.Protocol = []string{"binary"}
+	conn, err := websocket.DialConfig(wsConfig)
+	if err != nil {
+		t.Fatalf("failed to connect to the console: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	banner := make([]byte, 12)
+	if _, err := io.ReadFull(conn, banner); err != nil || string(banner) != "RFB 003.008\n" {
+		t.Errorf("console sent %q, %v, want the RFB 3.8 banner", banner, err)
+	}
+
+	// Instances are cloned from the simulated templates
+	var inst struct {
+		ID    string `json:"id"`
+		State string `json:"state"`
+		VLAN  int    `json:"vlan"`
+		VMs   []struct {
+			Name string `json:"name"`
+		} `json:"vms"`
+	}
+	if status := lab.do("POST", "/api/instances", adminToken, &inst); status != http.StatusAccepted || inst.State != "provisioning" || inst.VLAN != 100 {
+		t.Fatalf("POST instance: status %d %+v", status, inst)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(inst.VMs) == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		if status := lab.do("GET", "/api/instances/"+inst.ID, adminToken, &inst); status != http.StatusOK {
+			t.Fatalf("GET instance: status %d", status)
+		}
+	}
+	if len(inst.VMs) == 0 || inst.VMs[0].Name != "DC01-"+inst.ID {
+		t.Errorf("instance VMs %+v, want a clone of DC01", inst.VMs)
+	}
+}
+
+func TestNetworkModes(t *testing.T) {
+	lab := newTestLab(t, map[string]string{
+		"PFSENSE_EXAM_RULES":     "exam",
+		"PFSENSE_ISOLATED_RULES": "isolated",
+	})
+	exam := lab.pfsense.AddRule("Block internet access from the lab [exam]", true)
+	isolated := lab.pfsense.AddRule("Block traffic between VPN clients [isolated]", true)
+
+	var modes struct {
+		Current string `json:"current"`
+	}
+	if status := lab.do("GET", "/api/pfsense/modes", adminToken, &modes); status != http.StatusOK || modes.Current != "normal" {
+		t.Errorf("status %d in mode %q, want normal", status, modes.Current)
+	}
+
+	if status := lab.do("POST", "/api/pfsense/modes/exam", studentToken, nil); status != http.StatusForbidden {
+		t.Errorf("POST exam mode as a student: status %d, want %d", status, http.StatusForbidden)
+	}
+	if status := lab.do("POST", "/api/pfsense/modes/exam", adminToken, nil); status != http.StatusOK {
+		t.Fatalf("POST exam mode: status %d", status)
+	}
+	if rules := lab.pfsense.Rules(); rules[exam].Disabled || !rules[isolated].Disabled {
+		t.Errorf("rules after switching to exam: %+v", rules)
+	}
+	if status := lab.do("GET", "/api/pfsense/modes", adminToken, &modes); status != http.StatusOK || modes.Current != "exam" {
+		t.Errorf("status %d in mode %q, want exam", status, modes.Current)
+	}
+}
+
+// powerOrder returns the positions of the given power action of each VM among the requests to the fake Proxmox
+func powerOrder(requests []string, action string) map[string]int {
+	order := map[string]int{}
+	for i, request := range requests {
+		for j, name := range goadVMs {
+			if request == fmt.Sprintf("POST /nodes/pve1/qemu/%d/status/%s", 101+j, action) {
+				order[name] = i
+			}
+		}
+	}
+	return order
+}
+
+func TestBootGroups(t *testing.T) {
+	lab := newTestLab(t, map[string]string{"LAB_BOOT_GROUPS": "dcs:DC01,DC02,DC03;servers:SRV02"})
+	for i := range goadVMs {
+		lab.pve.UpdateVM(101+i, func(vm *fake.VM) { vm.Status = "stopped" })
+	}
+
+	var job jobResponse
+	if status := lab.do("POST", "/api/pve/vms/start", adminToken, &job); status != http.StatusAccepted {
+		t.Fatalf("POST start: status %d", status)
+	}
+	if job = lab.waitJob(job.ID); job.Status != "succeeded" {
+		t.Fatalf("start job %s: %s", job.Status, job.Message)
+	}
+	// Groups start in order, SRV03 is in no group and starts last
+	started := powerOrder(lab.pve.Requests(), "start")
+	if len(started) != len(goadVMs) {
+		t.Fatalf("started %v, want every VM", started)
+	}
+	for _, dc := range []string{"DC01", "DC02", "DC03"} {
+		if started[dc] > started["SRV02"] {
+			t.Errorf("%s started after SRV02", dc)
+		}
+	}
+	if started["SRV02"] > started["SRV03"] {
+		t.Error("SRV02 started after SRV03, which is in no group")
+	}
+
+	if status := lab.do("POST", "/api/pve/vms/shutdown", adminToken, &job); status != http.StatusAccepted {
+		t.Fatalf("POST shutdown: status %d", status)
+	}
+	if job = lab.waitJob(job.ID); job.Status != "succeeded" {
+		t.Fatalf("shutdown job %s: %s", job.Status, job.Message)
+	}
+	// Groups shut down in reverse order
+	shutdown := powerOrder(lab.pve.Requests(), "shutdown")
+	if len(shutdown) != len(goadVMs) {
+		t.Fatalf("shut down %v, want every VM", shutdown)
+	}
+	if shutdown["SRV03"] > shutdown["SRV02"] {
+		t.Error("SRV03, which is in no group, shut down after SRV02")
+	}
+	for _, dc := range []string{"DC01", "DC02", "DC03"} {
+		if shutdown[dc] < shutdown["SRV02"] {
+			t.Errorf("%s shut down before SRV02", dc)
+		}
+	}
+}
+
+func TestShutdownForceStop(t *testing.T) {
+	tests := []struct {
+		force  string
+		status string
+		power  string
+		vm     string
+	}{
+		{"true", "succeeded", "stopped", "stopped"},
+		{"false", "degraded", "failed", "running"},
+	}
+	for _, tt := range tests {
+		t.Run("force="+tt.force, func(t *testing.T) {
+			lab := newTestLab(t, nil)
+			// The guest of DC03 ignores the ACPI request until the timeout
+			lab.pve.Inject(fake.Fault{Method: "POST", Path: "/nodes/*/qemu/103/status/shutdown", TaskError: "VM quit/powerdown failed - got timeout"})
+
+			var job jobResponse
+			if status := lab.do("POST", "/api/pve/vms/shutdown?timeout=1s&force="+tt.force, adminToken, &job); status != http.StatusAccepted {
+				t.Fatalf("POST shutdown: status %d", status)
+			}
+			job = lab.waitJob(job.ID)
+			if job.Status != tt.status {
+				t.Errorf("job %s: %s, want %s", job.Status, job.Message, tt.status)
+			}
+			for _, result := range job.VMs {
+				want := "shutdown"
+				if result.Name == "DC03" {
+					want = tt.power
+				}
+				if result.Power != want {
+					t.Errorf("%s: power %q, want %q", result.Name, result.Power, want)
+				}
+			}
+
+			if vm, _ := lab.pve.VM(103); vm.Status != tt.vm {
+				t.Errorf("DC03 is %s, want %s", vm.Status, tt.vm)
+			}
+			stopped := contains(lab.pve.Requests(), "POST /nodes/pve1/qemu/103/status/stop")
+			if stopped != (tt.force == "true") {
+				t.Errorf("DC03 stopped: %v with force=%s", stopped, tt.force)
+			}
+		})
+	}
+}
+
+func TestReservationForOtherUser(t *testing.T) {
+	lab := newTestLab(t, nil)
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name   string
+		token  string
+		user   string
+		start  int64
+		status int
+	}{
+		{"student for an admin", studentToken, "root", now + 3600, http.StatusForbidden},
+		{"admin for an unknown user", adminToken, "mallory", now + 3600, http.StatusBadRequest},
+		{"admin for a student", adminToken, "alice", now + 3600, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		var body errorResponse
+		slot := map[string]interface{}{"title": tt.name, "start": tt.start, "end": tt.start + 3600, "user": tt.user}
+		if status := lab.send("POST", "/api/reservations/", tt.token, slot, &body); status != tt.status {
+			t.Errorf("%s: status %d %+v, want %d", tt.name, status, body, tt.status)
+		}
+	}
+}
+
+func TestDrainKeepsReservationReset(t *testing.T) {
+	lab := newTestLab(t, nil)
+	lab.pve.SetTaskDuration(300 * time.Millisecond)
+
+	now := time.Now().Unix()
+	var booking struct {
+		ID       string `json:"id"`
+		ResetJob string `json:"reset_job"`
+	}
+	slot := map[string]interface{}{"start": now - 60, "end": now + 3600, "auto_reset": true}
+	if status := lab.send("POST", "/api/reservations/", studentToken, slot, &booking); status != http.StatusCreated {
+		t.Fatalf("POST reservation: status %d", status)
+	}
+
+	// The scheduler resets the lab for the reservation that has started
+	lab.srv.Start()
+	deadline := time.Now().Add(5 * time.Second)
+	for booking.ResetJob == "" && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		lab.do("GET", "/api/reservations/"+booking.ID, studentToken, &booking)
+	}
+	if booking.ResetJob == "" {
+		t.Fatal("the reservation was not reset")
+	}
+
+	// Draining stops the scheduler, not the reset it started
+	lab.srv.Drain()
+	if job := lab.waitJob(booking.ResetJob); job.Status != "succeeded" {
+		t.Errorf("reset %s after draining: %s", job.Status, job.Message)
+	}
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+)
+
+// CertReloader serves a certificate from files and reloads it when the files change,
+// so that renewed certificates are picked up without a restart
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	version string // 两个文件的修改时间与大小, 用于发现变化
+}
+
+// NewCertReloader loads the certificate and key
+func NewCertReloader(certFile string, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, for use as tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Run checks the files for changes every interval until ctx is cancelled.
+// A certificate that fails to load is logged and the previous one is kept.
+func (r *CertReloader) Run(ctx context.Context, interval time.Duration) {
+	if interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			version, err := r.fileVersion()
+			if err != nil {
+				slog.Warn("failed to check the TLS certificate", "file", r.certFile, "error", err)
+				continue
+			}
+			r.mu.RLock()
+			changed := version != r.version
+			r.mu.RUnlock()
+			if !changed {
+				continue
+			}
+
+			if err := r.reload(); err != nil {
+				slog.Warn("failed to reload the TLS certificate, keeping the previous one", "file", r.certFile, "error", err)
+				continue
+			}
+			slog.Info("reloaded the TLS certificate", "file", r.certFile)
+		}
+	}
+}
+
+// reload loads the certificate and key from their files
+func (r *CertReloader) reload() error {
+	version, err := r.fileVersion()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.version = version
+	return nil
+}
+
+// fileVersion identifies the current content of the certificate and key files
+func (r *CertReloader) fileVersion() (string, error) {
+	version := ""
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to stat TLS certificate: %w", err)
+		}
+		version += fmt.Sprintf("%d/%d;", info.ModTime().UnixNano(), info.Size())
+	}
+	return version, nil
+}
+
+// NewTLSConfig builds the TLS configuration of the HTTPS listener.
+// With TLS_CLIENT_CA_FILE, client certificates are verified and, depending on TLS_CLIENT_AUTH, required.
+func NewTLSConfig(config *config.Config, reloader *CertReloader) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if config.GetTLSMinVersion() == "1.3" {
+		tlsConfig.MinVersion = tls.VersionTLS13
+	}
+
+	if file := config.GetTLSClientCAFile(); file != "" {
+		pem, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("client CA bundle %s contains no certificates", file)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = clientAuthType(config.GetTLSClientAuth())
+	}
+
+	return tlsConfig, nil
+}
+
+// clientAuthType maps TLS_CLIENT_AUTH to the client certificate policy
+func clientAuthType(mode string) tls.ClientAuthType {
+	if mode == config.TLSClientRequire {
+		return tls.RequireAndVerifyClientCert
+	}
+	return tls.VerifyClientCertIfGiven
+}
+
+// RedirectHandler redirects plain HTTP requests to the HTTPS listener on port
+func RedirectHandler(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.TrimSuffix(net.JoinHostPort(host, port), ":443")
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
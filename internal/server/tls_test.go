@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
+)
+
+// writeCert writes a new self-signed certificate for name and its key as PEM files
+func writeCert(t *testing.T, certFile string, keyFile string, name string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// servedName returns the common name of the certificate the reloader serves
+func servedName(t *testing.T, reloader *CertReloader) string {
+	t.Helper()
+
+	cert, err := reloader.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCert(t, certFile, keyFile, "old.example.com")
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := servedName(t, reloader); name != "old.example.com" {
+		t.Fatalf("serving %s, want old.example.com", name)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Run(ctx, 10*time.Millisecond)
+
+	// A key that does not match the certificate is not picked up
+	writeCert(t, certFile, filepath.Join(dir, "other.key"), "broken.example.com")
+	time.Sleep(50 * time.Millisecond)
+	if name := servedName(t, reloader); name != "old.example.com" {
+		t.Fatalf("serving %s after a broken renewal, want the previous certificate", name)
+	}
+
+	// The files may be rewritten within the resolution of their modification times
+	writeCert(t, certFile, keyFile, "new.example.com")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for servedName(t, reloader) != "new.example.com" {
+		if time.Now().After(deadline) {
+			t.Fatal("the renewed certificate was not picked up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	if got := clientAuthType(config.TLSClientRequire); got != tls.RequireAndVerifyClientCert {
+		t.Errorf("clientAuthType(require) = %v, want RequireAndVerifyClientCert", got)
+	}
+	if got := clientAuthType(config.TLSClientOptional); got != tls.VerifyClientCertIfGiven {
+		t.Errorf("clientAuthType(optional) = %v, want VerifyClientCertIfGiven", got)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCert(t, certFile, keyFile, "dashboard.example.com")
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emptyCA := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(emptyCA, []byte("not a certificate\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("DEMO", "1")
+	t.Setenv("TLS_CERT_FILE", certFile)
+	t.Setenv("TLS_KEY_FILE", keyFile)
+	t.Setenv("TLS_CLIENT_AUTH", config.TLSClientRequire)
+
+	t.Setenv("TLS_CLIENT_CA_FILE", emptyCA)
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTLSConfig(cfg, reloader); err == nil {
+		t.Error("a client CA bundle without certificates was accepted")
+	}
+
+	t.Setenv("TLS_CLIENT_CA_FILE", certFile)
+	cfg, err = config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsConfig, err := NewTLSConfig(cfg, reloader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.ClientAuth != tls.RequireAndVerifyClientCert || tlsConfig.ClientCAs == nil {
+		t.Errorf("client auth %v with CAs %v, want client certificates required", tlsConfig.ClientAuth, tlsConfig.ClientCAs)
+	}
+}
@@ -1,23 +1,23 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"flag"
 	"fmt"
 	"io/fs"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	_ "github.com/chunzhennn/GOAD-Dashboard/docs"
-	"github.com/chunzhennn/GOAD-Dashboard/internal/api/controllers"
 	"github.com/chunzhennn/GOAD-Dashboard/internal/config"
-	"github.com/chunzhennn/GOAD-Dashboard/internal/platform/pfsense"
-	"github.com/chunzhennn/GOAD-Dashboard/internal/platform/proxmox"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/logging"
+	"github.com/chunzhennn/GOAD-Dashboard/internal/server"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/httprate"
-	httpSwagger "github.com/swaggo/http-swagger"
 )
 
 //go:embed ui/dist
@@ -29,65 +29,133 @@ var swaggerJSON []byte
 // @title GOAD Dashboard API
 // @version 1.0
 // @description GOAD Dashboard API
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
 func main() {
+	demo := flag.Bool("demo", false, "run against a simulated lab instead of Proxmox and pfSense, same as DEMO=1")
+	flag.Parse()
+	if *demo {
+		os.Setenv("DEMO", "1")
+	}
+
 	config, err := config.LoadConfig()
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fatal("failed to load configuration", err)
 	}
+	slog.SetDefault(logging.New(config, os.Stderr))
 
-	pveClient := proxmox.NewPVEClientFromConfig(config)
-	pveController := controllers.NewPVEController(pveClient)
-
-	router := chi.NewRouter()
-	router.Use(middleware.RequestID)
-	router.Use(middleware.RealIP)
-	router.Use(middleware.Logger)
-	router.Use(middleware.Recoverer)
-	router.Use(middleware.Timeout(60 * time.Second))
-
-	// PVE API endpoints
-	router.Route("/api/pve", func(r chi.Router) {
-		// GET group
-		r.Group(func(r chi.Router) {
-			r.Use(httprate.LimitByIP(2, 1*time.Second))
-			r.Get("/vms", pveController.GetVMs)
-			r.Get("/reset", pveController.GetLastReset)
-		})
+	backends, err := server.NewBackends(config)
+	if err != nil {
+		fatal("failed to start", err)
+	}
+	srv, err := server.NewServer(config, backends)
+	if err != nil {
+		fatal("failed to start", err)
+	}
+	if os.Getenv("ENABLE_SWAGGER") == "1" {
+		srv.ServeSwagger(swaggerJSON)
+	}
+	ui, _ := fs.Sub(uiFS, "ui/dist")
+	srv.ServeUI(ui)
+
+	srv.Start()
+	if config.GetDemo() {
+		slog.Warn("running in demo mode against a simulated lab")
+	}
+	if !srv.Authenticator().Enabled() {
+		slog.Warn("AUTH_USERS is not set, the API is open and consoles are disabled")
+	}
 
-		// POST group
-		r.Group(func(r chi.Router) {
-			r.Use(httprate.LimitByIP(1, 10*time.Second))
-			r.Post("/vms/start", pveController.StartAllVMs)
-			r.Post("/vms/stop", pveController.StopAllVMs)
-			r.Post("/vms/reset", pveController.ResetAllVMs)
-			r.Post("/reset", pveController.ResetLab)
+	httpServer := &http.Server{
+		Addr:              fmt.Sprintf(":%s", config.GetPort()),
+		Handler:           srv,
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+
+	// The redirect listener answers the probes itself, so that they work without client certificates
+	var redirectServer *http.Server
+	if config.GetTLSCertFile() != "" {
+		reloader, err := server.NewCertReloader(config.GetTLSCertFile(), config.GetTLSKeyFile())
+		if err != nil {
+			fatal("failed to load TLS certificate", err)
+		}
+		httpServer.TLSConfig, err = server.NewTLSConfig(config, reloader)
+		if err != nil {
+			fatal("failed to configure TLS", err)
+		}
+		srv.Go(func(ctx context.Context) {
+			reloader.Run(ctx, config.GetTLSReloadInterval())
 		})
-	})
 
-	pfsenseClient := pfsense.NewPfsenseClient(config)
-	pfsenseController := controllers.NewPfsenseController(pfsenseClient)
+		if port := config.GetHTTPRedirectPort(); port != "" {
+			redirect := chi.NewRouter()
+			redirect.Get("/healthz", srv.Probes().Healthz)
+			redirect.Get("/readyz", srv.Probes().Readyz)
+			redirect.NotFound(server.RedirectHandler(config.GetPort()).ServeHTTP)
+			redirect.MethodNotAllowed(server.RedirectHandler(config.GetPort()).ServeHTTP)
+			redirectServer = &http.Server{
+				Addr:              fmt.Sprintf(":%s", port),
+				Handler:           redirect,
+				ReadHeaderTimeout: 10 * time.Second,
+				ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+			}
+		}
+	}
 
-	// PFSENSE API endpoints
-	router.Route("/api/pfsense", func(r chi.Router) {
-		r.Use(httprate.LimitByIP(2, 1*time.Second))
-		r.Get("/openvpn/connections", pfsenseController.GetOpenVPNConnections)
-	})
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
 
-	swaggerEnabled := os.Getenv("ENABLE_SWAGGER")
-	if swaggerEnabled == "1" {
-		router.Get("/swagger/doc.json", func(w http.ResponseWriter, r *http.Request) {
-			w.Write(swaggerJSON)
-		})
-		router.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(fmt.Sprintf("http://localhost:%s/swagger/doc.json", config.GetPort()))))
+	serverErr := make(chan error, 2)
+	go func() {
+		if httpServer.TLSConfig != nil {
+			slog.Info("starting GOAD Dashboard API server", "port", config.GetPort(), "tls", true)
+			serverErr <- httpServer.ListenAndServeTLS("", "")
+			return
+		}
+		slog.Info("starting GOAD Dashboard API server", "port", config.GetPort(), "tls", false)
+		serverErr <- httpServer.ListenAndServe()
+	}()
+	if redirectServer != nil {
+		go func() {
+			slog.Info("redirecting HTTP to HTTPS", "port", config.GetHTTPRedirectPort())
+			serverErr <- redirectServer.ListenAndServe()
+		}()
 	}
 
-	ui, _ := fs.Sub(uiFS, "ui/dist")
-	router.NotFound(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		http.FileServer(http.FS(ui)).ServeHTTP(w, r)
-	}))
+	select {
+	case err := <-serverErr:
+		fatal("failed to start server", err)
+	case <-signals.Done():
+	}
+	stopSignals()
+
+	// Fail the readiness probe first and keep serving until load balancers have noticed
+	srv.Drain()
+	if delay := config.GetShutdownDrainDelay(); delay > 0 {
+		slog.Info("draining, readiness probe is failing", "delay", delay.String())
+		time.Sleep(delay)
+	}
+
+	slog.Info("shutting down, waiting for requests and lab jobs to finish", "timeout", config.GetShutdownTimeout().String())
+	ctx, cancel := context.WithTimeout(context.Background(), config.GetShutdownTimeout())
+	defer cancel()
 
-	log.Printf("Starting GOAD Dashboard API server...")
-	if err := http.ListenAndServe(fmt.Sprintf(":%s", config.GetPort()), router); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	if redirectServer != nil {
+		redirectServer.Shutdown(ctx)
+	}
+	if err := httpServer.Shutdown(ctx); err != nil {
+		slog.Warn("failed to drain requests", "error", err)
 	}
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("failed to shut down cleanly", "error", err)
+	}
+	slog.Info("GOAD Dashboard API server stopped")
+}
+
+// fatal logs an error that prevents the server from running and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
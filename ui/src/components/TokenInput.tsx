@@ -0,0 +1,52 @@
+import { useState } from 'react';
+import { useDispatch } from 'react-redux';
+import { api } from '../store/api';
+import { getToken, setToken } from '../lib/token';
+import { Button } from './ui/button';
+
+// Lets users listed in AUTH_USERS sign in with their bearer token to control the lab
+export function TokenInput() {
+  const dispatch = useDispatch();
+  const [signedIn, setSignedIn] = useState(() => getToken() !== undefined);
+  const [value, setValue] = useState('');
+
+  const update = (token: string | undefined) => {
+    setToken(token);
+    setSignedIn(token !== undefined);
+    setValue('');
+    // Refetch everything with the new credentials
+    dispatch(api.util.resetApiState());
+  };
+
+  if (signedIn) {
+    return (
+      <Button variant="outline" size="sm" onClick={() => { update(undefined); }}>
+        Sign out
+      </Button>
+    );
+  }
+
+  return (
+    <form
+      className="flex items-center gap-2"
+      onSubmit={(event) => {
+        event.preventDefault();
+        if (value.trim() !== '') {
+          update(value.trim());
+        }
+      }}
+    >
+      <input
+        type="password"
+        value={value}
+        onChange={(event) => { setValue(event.target.value); }}
+        placeholder="API token"
+        aria-label="API token"
+        className="h-8 w-40 rounded-md border bg-background px-2 text-sm"
+      />
+      <Button type="submit" variant="outline" size="sm" disabled={value.trim() === ''}>
+        Sign in
+      </Button>
+    </form>
+  );
+}
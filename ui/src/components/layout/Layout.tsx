@@ -1,5 +1,6 @@
 import React from 'react';
 import { ThemeToggle } from '../ThemeToggle';
+import { TokenInput } from '../TokenInput';
 
 interface LayoutProps {
   children: React.ReactNode;
@@ -14,6 +15,7 @@ export function Layout({ children }: LayoutProps) {
             <h1 className="text-xl font-bold ml-4">🚀 GOAD Dashboard</h1>
           </div>
           <div className="flex items-center gap-4 mr-4">
+            <TokenInput />
             <ThemeToggle />
           </div>
         </div>
@@ -0,0 +1,12 @@
+// The bearer token of the signed in user, kept in localStorage like the theme
+const tokenKey = 'token';
+
+export const getToken = () => localStorage.getItem(tokenKey) ?? undefined;
+
+export const setToken = (token: string | undefined) => {
+  if (token) {
+    localStorage.setItem(tokenKey, token);
+  } else {
+    localStorage.removeItem(tokenKey);
+  }
+};
@@ -2,13 +2,14 @@ import { useEffect, useState } from 'react';
 import { 
   useGetApiPveVmsQuery, 
   usePostApiPveVmsStartMutation, 
-  usePostApiPveVmsStopMutation, 
+  usePostApiPveVmsShutdownMutation, 
   usePostApiPveVmsResetMutation,
   useGetApiPfsenseOpenvpnConnectionsQuery,
   useGetApiPveResetQuery,
   usePostApiPveResetMutation,
-  ProxmoxVmInfo,
-  PfsensePfsenseOpenVpnConnection
+  useGetApiPveJobsByIdQuery,
+  PlatformVmInfo,
+  PlatformVpnConnection
 } from '../store/api';
 import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "../components/ui/card";
 import { Button } from "../components/ui/button";
@@ -47,10 +48,29 @@ export function Dashboard() {
 
   // VM operation mutations
   const [startVM] = usePostApiPveVmsStartMutation();
-  const [stopVM] = usePostApiPveVmsStopMutation();
+  const [shutdownVM] = usePostApiPveVmsShutdownMutation();
   const [resetVM] = usePostApiPveVmsResetMutation();
   const [restoreSnapshots, { isLoading: isRestoringSnapshots }] = usePostApiPveResetMutation();
 
+  // Start, shutdown and reset run as jobs, poll the current one until it has finished
+  const [jobId, setJobId] = useState<string>();
+  const { data: job } = useGetApiPveJobsByIdQuery(
+    { id: jobId! },
+    { skip: !jobId, pollingInterval: 2000 }
+  );
+  const isJobRunning = jobId !== undefined;
+
+  useEffect(() => {
+    if (job?.status && job.status !== 'running') {
+      if (job.status !== 'succeeded') {
+        console.error(`Lab ${job.kind} ${job.status}:`, job.message);
+      }
+      setJobId(undefined);
+      refetchVms();
+      refetchResetHistory();
+    }
+  }, [job?.status, job?.kind, job?.message, refetchVms, refetchResetHistory]);
+
   // Refresh interval (3 seconds)
   const [refreshInterval, setRefreshInterval] = useState(3000);
   
@@ -68,8 +88,8 @@ export function Dashboard() {
   // Handle VM operations
   const handleStartAllVMs = async () => {
     try {
-      await startVM();
-      refetchVms();
+      const job = await startVM().unwrap();
+      setJobId(job.id);
     } catch (error) {
       console.error('Failed to start all VMs:', error);
     }
@@ -77,8 +97,8 @@ export function Dashboard() {
 
   const handleStopAllVMs = async () => {
     try {
-      await stopVM();
-      refetchVms();
+      const job = await shutdownVM({}).unwrap();
+      setJobId(job.id);
     } catch (error) {
       console.error('Failed to stop all VMs:', error);
     }
@@ -95,9 +115,8 @@ export function Dashboard() {
 
   const handleRestoreSnapshots = async () => {
     try {
-      await restoreSnapshots();
-      refetchVms();
-      refetchResetHistory();
+      const job = await restoreSnapshots().unwrap();
+      setJobId(job.id);
     } catch (error) {
       console.error('Failed to restore snapshots:', error);
     }
@@ -221,7 +240,7 @@ export function Dashboard() {
                     variant="default" 
                     size="sm"
                     onClick={handleStartAllVMs}
-                    disabled={runningVMs.length === vms.length}
+                    disabled={isJobRunning || runningVMs.length === vms.length}
                   >
                     <Play className="h-4 w-4 mr-2" />
                     Start
@@ -230,7 +249,7 @@ export function Dashboard() {
                     variant="destructive" 
                     size="sm"
                     onClick={handleStopAllVMs}
-                    disabled={stoppedVMs.length === vms.length}
+                    disabled={isJobRunning || stoppedVMs.length === vms.length}
                   >
                     <Square className="h-4 w-4 mr-2" />
                     Stop
@@ -239,7 +258,7 @@ export function Dashboard() {
                     variant="secondary" 
                     size="sm"
                     onClick={handleResetAllVMs}
-                    disabled={vms.length === 0}
+                    disabled={isJobRunning || vms.length === 0}
                   >
                     <RotateCw className="h-4 w-4 mr-2" />
                     Reset
@@ -248,10 +267,10 @@ export function Dashboard() {
                     variant="outline" 
                     size="sm"
                     onClick={handleRestoreSnapshots}
-                    disabled={isRestoringSnapshots || vms.length === 0}
+                    disabled={isRestoringSnapshots || isJobRunning || vms.length === 0}
                   >
-                    <RotateCw className="h-4 w-4 mr-2" />
-                    Restore
+                    <RotateCw className={cn("h-4 w-4 mr-2", isJobRunning ? "animate-spin" : "")} />
+                    {isJobRunning ? `Running ${job?.kind ?? 'job'}${job?.step ? ` (${job.step})` : ''}` : 'Restore'}
                   </Button>
                 </div>
               </div>
@@ -267,7 +286,7 @@ export function Dashboard() {
                 </Card>
               ) : (
                 <div className="grid grid-cols-1 md:grid-cols-2 lg:grid-cols-3 gap-4">
-                  {[...vms].sort((a, b) => a.id!.localeCompare(b.id!)).map((vm: ProxmoxVmInfo) => {
+                  {[...vms].sort((a, b) => a.id!.localeCompare(b.id!)).map((vm: PlatformVmInfo) => {
                     const isRunning = vm.status === 'running';
                     
                     return (
@@ -378,7 +397,7 @@ export function Dashboard() {
                     </div>
                     <Separator />
                     <div className="space-y-2">
-                      {vpnConnections.map((connection: PfsensePfsenseOpenVpnConnection) => (
+                      {vpnConnections.map((connection: PlatformVpnConnection) => (
                         <div 
                           key={connection.id} 
                           className="grid grid-cols-12 py-2"
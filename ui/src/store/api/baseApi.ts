@@ -1,8 +1,19 @@
 // Or from '@reduxjs/toolkit/query' if not using the auto-generated hooks
 import { createApi, fetchBaseQuery } from '@reduxjs/toolkit/query/react'
+import { getToken } from '@/lib/token'
 
 // initialize an empty api service that we'll inject endpoints into later as needed
 export const baseApi = createApi({
-  baseQuery: fetchBaseQuery({ baseUrl: '/' }),
+  baseQuery: fetchBaseQuery({
+    baseUrl: '/',
+    // Send the token of the signed in user, the lab controls answer 401 without it once AUTH_USERS is set
+    prepareHeaders: (headers) => {
+      const token = getToken();
+      if (token) {
+        headers.set('Authorization', `Bearer ${token}`);
+      }
+      return headers;
+    },
+  }),
   endpoints: () => ({}),
-})
\ No newline at end of file
+})
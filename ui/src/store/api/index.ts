@@ -19,6 +19,12 @@ const injectedRtkApi = api.injectEndpoints({
     >({
       query: () => ({ url: `/api/pve/reset`, method: "POST" }),
     }),
+    getApiPveJobsById: build.query<
+      GetApiPveJobsByIdApiResponse,
+      GetApiPveJobsByIdApiArg
+    >({
+      query: (queryArg) => ({ url: `/api/pve/jobs/${queryArg.id}` }),
+    }),
     getApiPveVms: build.query<GetApiPveVmsApiResponse, GetApiPveVmsApiArg>({
       query: () => ({ url: `/api/pve/vms` }),
     }),
@@ -34,6 +40,19 @@ const injectedRtkApi = api.injectEndpoints({
     >({
       query: () => ({ url: `/api/pve/vms/start`, method: "POST" }),
     }),
+    postApiPveVmsShutdown: build.mutation<
+      PostApiPveVmsShutdownApiResponse,
+      PostApiPveVmsShutdownApiArg
+    >({
+      query: (queryArg) => ({
+        url: `/api/pve/vms/shutdown`,
+        method: "POST",
+        params: {
+          timeout: queryArg.timeout,
+          force: queryArg.force,
+        },
+      }),
+    }),
     postApiPveVmsStop: build.mutation<
       PostApiPveVmsStopApiResponse,
       PostApiPveVmsStopApiArg
@@ -45,26 +64,62 @@ const injectedRtkApi = api.injectEndpoints({
 });
 export { injectedRtkApi as api };
 export type GetApiPfsenseOpenvpnConnectionsApiResponse =
-  /** status 200 OK */ PfsensePfsenseOpenVpnConnection[];
+  /** status 200 OK */ PlatformVpnConnection[];
 export type GetApiPfsenseOpenvpnConnectionsApiArg = void;
 export type GetApiPveResetApiResponse = { last_reset: number };
 export type GetApiPveResetApiArg = void;
-export type PostApiPveResetApiResponse = Record<string, string>;
+export type PostApiPveResetApiResponse = /** status 202 Accepted */ LabJob;
 export type PostApiPveResetApiArg = void;
-export type GetApiPveVmsApiResponse = /** status 200 OK */ ProxmoxVmInfo[];
+export type GetApiPveJobsByIdApiResponse = /** status 200 OK */ LabJob;
+export type GetApiPveJobsByIdApiArg = {
+  /** Job ID */
+  id: string;
+};
+export type GetApiPveVmsApiResponse = /** status 200 OK */ PlatformVmInfo[];
 export type GetApiPveVmsApiArg = void;
 export type PostApiPveVmsResetApiResponse = Record<string, string>;
 export type PostApiPveVmsResetApiArg = void;
-export type PostApiPveVmsStartApiResponse = Record<string, string>;
+export type PostApiPveVmsShutdownApiResponse =
+  /** status 202 Accepted */ LabJob;
+export type PostApiPveVmsShutdownApiArg = {
+  /** ACPI shutdown timeout such as 90s, defaults to LAB_SHUTDOWN_TIMEOUT and must be shorter than LAB_JOB_TIMEOUT */
+  timeout?: string;
+  /** Stop VMs that do not shut down in time (default true) */
+  force?: boolean;
+};
+export type PostApiPveVmsStartApiResponse = /** status 202 Accepted */ LabJob;
 export type PostApiPveVmsStartApiArg = void;
 export type PostApiPveVmsStopApiResponse = Record<string, string>;
 export type PostApiPveVmsStopApiArg = void;
-export interface PfsensePfsenseOpenVpnConnection {
+export interface PlatformVpnConnection {
   common_name?: string;
   connect_time_unix?: number;
   id?: number;
 }
-export interface ProxmoxVmInfo {
+export interface LabVmResult {
+  /** 最终的 guest agent 状态 */
+  agent?: string;
+  detail?: string;
+  group?: string;
+  name?: string;
+  power?: string;
+  /** ok 或 failed, 仅限重置任务 */
+  rollback?: string;
+  /** 服务健康状态, 仅限配置在 LAB_HOSTS 中的主机 */
+  services?: string;
+  vmid?: string;
+}
+export interface LabJob {
+  finished_at?: number;
+  id?: string;
+  kind?: string;
+  message?: string;
+  started_at?: number;
+  status?: string;
+  step?: string;
+  vms?: LabVmResult[];
+}
+export interface PlatformVmInfo {
   /** Current CPU usage */
   cpu?: number;
   /** Maximum available CPU count */
@@ -96,8 +151,10 @@ export const {
   useGetApiPfsenseOpenvpnConnectionsQuery,
   useGetApiPveResetQuery,
   usePostApiPveResetMutation,
+  useGetApiPveJobsByIdQuery,
   useGetApiPveVmsQuery,
   usePostApiPveVmsResetMutation,
+  usePostApiPveVmsShutdownMutation,
   usePostApiPveVmsStartMutation,
   usePostApiPveVmsStopMutation,
 } = injectedRtkApi;